@@ -0,0 +1,268 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+
+	"zaiko/internal/config"
+	"zaiko/internal/database"
+	"zaiko/internal/handlers"
+	"zaiko/internal/middleware"
+	"zaiko/internal/models"
+	"zaiko/internal/repository"
+	"zaiko/internal/service"
+)
+
+// newRouter wires the repositories, services and handlers to db and
+// registers the API routes.
+func newRouter(cfg *config.Config, db *database.DB) *gin.Engine {
+	// Initialize Gin router
+	router := gin.Default()
+
+	// CORS configuration
+	router.Use(cors.New(cors.Config{
+		AllowOrigins:     []string{"http://localhost:5173", "http://localhost:3000"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
+		ExposeHeaders:    []string{"Content-Length"},
+		AllowCredentials: true,
+	}))
+
+	// Repositories
+	userRepo := repository.NewUserRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	twoFactorRepo := repository.NewTwoFactorRepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	categoryRepo := repository.NewCategoryRepository(db)
+	productRepo := repository.NewProductRepository(db)
+	warehouseRepo := repository.NewWarehouseRepository(db)
+	supplierRepo := repository.NewSupplierRepository(db)
+	customerRepo := repository.NewCustomerRepository(db)
+	adjustmentReasonRepo := repository.NewAdjustmentReasonRepository(db)
+	stockRepo := repository.NewStockRepository(db)
+	transactionRepo := repository.NewTransactionRepository(db)
+	reorderRepo := repository.NewReorderRepository(db)
+	lotRepo := repository.NewLotRepository(db)
+	serialRepo := repository.NewSerialRepository(db)
+	dashboardRepo := repository.NewDashboardRepository(db)
+
+	// Services
+	loginGuard := service.NewLoginGuard(
+		db,
+		cfg.LoginMaxFailures,
+		cfg.LoginMaxFailuresPerIP,
+		time.Duration(cfg.LoginLockoutMinutes)*time.Minute,
+	)
+	sessionService := service.NewSessionService(
+		db,
+		time.Duration(cfg.AccessTokenMinutes)*time.Minute,
+		time.Duration(cfg.RefreshTokenDays)*24*time.Hour,
+	)
+	twoFactorService := service.NewTwoFactorService(db, sessionService, loginGuard)
+	apiKeyService := service.NewAPIKeyService(db)
+	stockService := service.NewStockService(db, models.CostingMethod(cfg.CostingMethod))
+	countService := service.NewCountService(db, stockService)
+	purchaseOrderService := service.NewPurchaseOrderService(db, stockService)
+	salesOrderService := service.NewSalesOrderService(db, stockService)
+	reservationService := service.NewReservationService(db)
+
+	// Handlers
+	authHandler := handlers.NewAuthHandler(db, userRepo, auditRepo, sessionService, twoFactorService, loginGuard)
+	twoFactorHandler := handlers.NewTwoFactorHandler(db, userRepo, auditRepo, twoFactorService)
+	categoryHandler := handlers.NewCategoryHandler(db, categoryRepo, auditRepo)
+	productHandler := handlers.NewProductHandler(db, productRepo, reorderRepo, auditRepo)
+	warehouseHandler := handlers.NewWarehouseHandler(db, warehouseRepo, auditRepo)
+	stockHandler := handlers.NewStockHandler(stockRepo, transactionRepo, reorderRepo, stockService, cfg.DefaultReorderPoint)
+	dashboardHandler := handlers.NewDashboardHandler(dashboardRepo, stockRepo, transactionRepo, reorderRepo, cfg.DefaultReorderPoint)
+	countHandler := handlers.NewCountHandler(countService)
+	lotHandler := handlers.NewLotHandler(lotRepo)
+	serialHandler := handlers.NewSerialHandler(serialRepo)
+	supplierHandler := handlers.NewSupplierHandler(db, supplierRepo, auditRepo)
+	purchaseOrderHandler := handlers.NewPurchaseOrderHandler(purchaseOrderService)
+	customerHandler := handlers.NewCustomerHandler(db, customerRepo, auditRepo)
+	adjustmentReasonHandler := handlers.NewAdjustmentReasonHandler(db, adjustmentReasonRepo, auditRepo)
+	salesOrderHandler := handlers.NewSalesOrderHandler(salesOrderService)
+	reservationHandler := handlers.NewReservationHandler(reservationService)
+	userHandler := handlers.NewUserHandler(db, userRepo, sessionRepo, twoFactorRepo, auditRepo, loginGuard)
+	auditHandler := handlers.NewAuditHandler(auditRepo)
+	apiKeyHandler := handlers.NewAPIKeyHandler(db, userRepo, auditRepo, apiKeyService)
+
+	// API routes
+	api := router.Group("/api")
+	{
+		// Auth routes (public)
+		auth := api.Group("/auth")
+		{
+			auth.POST("/login", authHandler.Login)
+			auth.POST("/refresh", authHandler.Refresh)
+			auth.POST("/2fa/verify", twoFactorHandler.Verify)
+		}
+
+		// Protected routes
+		protected := api.Group("")
+		protected.Use(middleware.AuthMiddleware(userRepo, sessionRepo, apiKeyRepo))
+		{
+			protected.GET("/auth/me", authHandler.Me)
+		}
+
+		// Account management (not with API keys)
+		account := protected.Group("")
+		account.Use(middleware.RequireSession())
+		{
+			account.PUT("/auth/password", authHandler.ChangePassword)
+			account.POST("/auth/logout", authHandler.Logout)
+			account.POST("/auth/2fa/setup", twoFactorHandler.Setup)
+			account.POST("/auth/2fa/enable", twoFactorHandler.Enable)
+			account.POST("/auth/2fa/disable", twoFactorHandler.Disable)
+			account.POST("/auth/2fa/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes)
+		}
+
+		// API keys (own keys; admins can see and revoke all)
+		apiKeys := account.Group("/api-keys")
+		apiKeys.Use(middleware.RequirePermission(models.PermissionRead))
+		{
+			apiKeys.GET("", apiKeyHandler.GetAll)
+			apiKeys.POST("", apiKeyHandler.Create)
+			apiKeys.DELETE("/:id", apiKeyHandler.Revoke)
+		}
+
+		// Read-only routes (all roles)
+		read := protected.Group("")
+		read.Use(middleware.RequirePermission(models.PermissionRead))
+		{
+			read.GET("/categories", categoryHandler.GetAll)
+			read.GET("/products", productHandler.GetAll)
+			read.GET("/products/:id", productHandler.GetByID)
+			read.GET("/products/:id/reorder", productHandler.GetReorderSettings)
+			read.GET("/warehouses", warehouseHandler.GetAll)
+			read.GET("/warehouses/:id", warehouseHandler.GetByID)
+			read.GET("/suppliers", supplierHandler.GetAll)
+			read.GET("/suppliers/:id", supplierHandler.GetByID)
+			read.GET("/customers", customerHandler.GetAll)
+			read.GET("/customers/:id", customerHandler.GetByID)
+			read.GET("/adjustment-reasons", adjustmentReasonHandler.GetAll)
+
+			read.GET("/stock", stockHandler.GetAll)
+			read.GET("/stock/low", stockHandler.GetLowStock)
+			read.GET("/stock/transactions", stockHandler.GetTransactions)
+			read.GET("/stock/ledger", stockHandler.GetLedger)
+			read.GET("/stock/valuation", stockHandler.GetValuation)
+			read.GET("/reservations", reservationHandler.GetAll)
+			read.GET("/reservations/:id", reservationHandler.GetByID)
+			read.GET("/lots", lotHandler.GetAll)
+			read.GET("/lots/expiring", lotHandler.GetExpiring)
+			read.GET("/serials/:serial", serialHandler.GetHistory)
+			read.GET("/counts", countHandler.GetAll)
+			read.GET("/counts/:id", countHandler.GetByID)
+			read.GET("/purchase-orders", purchaseOrderHandler.GetAll)
+			read.GET("/purchase-orders/:id", purchaseOrderHandler.GetByID)
+			read.GET("/sales-orders", salesOrderHandler.GetAll)
+			read.GET("/sales-orders/:id", salesOrderHandler.GetByID)
+
+			read.GET("/dashboard/summary", dashboardHandler.GetSummary)
+		}
+
+		// Stock operations (operator and above)
+		stock := protected.Group("")
+		stock.Use(middleware.RequirePermission(models.PermissionStockWrite))
+		{
+			// Stock movements
+			stock.POST("/stock/in", stockHandler.StockIn)
+			stock.POST("/stock/out", stockHandler.StockOut)
+			stock.POST("/stock/transfer", stockHandler.Transfer)
+			stock.POST("/stock/adjust", stockHandler.Adjust)
+			stock.POST("/stock/transactions/:id/reverse", stockHandler.ReverseTransaction)
+
+			// Reservations
+			stock.POST("/reservations", reservationHandler.Create)
+			stock.DELETE("/reservations/:id", reservationHandler.Release)
+
+			// Inventory counts
+			stock.POST("/counts", countHandler.Create)
+			stock.PUT("/counts/:id/lines", countHandler.Submit)
+			stock.POST("/counts/:id/finalize", countHandler.Finalize)
+			stock.POST("/counts/:id/cancel", countHandler.Cancel)
+
+			// Purchase orders
+			stock.POST("/purchase-orders", purchaseOrderHandler.Create)
+			stock.DELETE("/purchase-orders/:id", purchaseOrderHandler.Delete)
+			stock.POST("/purchase-orders/:id/order", purchaseOrderHandler.Order)
+			stock.POST("/purchase-orders/:id/receive", purchaseOrderHandler.Receive)
+			stock.POST("/purchase-orders/:id/close", purchaseOrderHandler.Close)
+
+			// Sales orders
+			stock.POST("/sales-orders", salesOrderHandler.Create)
+			stock.DELETE("/sales-orders/:id", salesOrderHandler.Delete)
+			stock.POST("/sales-orders/:id/confirm", salesOrderHandler.Confirm)
+			stock.POST("/sales-orders/:id/allocate", salesOrderHandler.Allocate)
+			stock.POST("/sales-orders/:id/ship", salesOrderHandler.Ship)
+			stock.POST("/sales-orders/:id/cancel", salesOrderHandler.Cancel)
+		}
+
+		// Master data maintenance (operator and above)
+		master := protected.Group("")
+		master.Use(middleware.RequirePermission(models.PermissionMasterWrite))
+		{
+			master.POST("/categories", categoryHandler.Create)
+			master.POST("/products", productHandler.Create)
+			master.PUT("/products/:id", productHandler.Update)
+			master.PUT("/products/:id/reorder", productHandler.SaveReorderSetting)
+			master.DELETE("/products/:id/reorder", productHandler.DeleteReorderSetting)
+			master.POST("/warehouses", warehouseHandler.Create)
+			master.PUT("/warehouses/:id", warehouseHandler.Update)
+			master.POST("/suppliers", supplierHandler.Create)
+			master.PUT("/suppliers/:id", supplierHandler.Update)
+			master.POST("/customers", customerHandler.Create)
+			master.PUT("/customers/:id", customerHandler.Update)
+			master.POST("/adjustment-reasons", adjustmentReasonHandler.Create)
+			master.PUT("/adjustment-reasons/:id", adjustmentReasonHandler.Update)
+		}
+
+		// Master data deletion (manager and above)
+		masterDelete := protected.Group("")
+		masterDelete.Use(middleware.RequirePermission(models.PermissionMasterDelete))
+		{
+			masterDelete.DELETE("/categories/:id", categoryHandler.Delete)
+			masterDelete.DELETE("/products/:id", productHandler.Delete)
+			masterDelete.DELETE("/warehouses/:id", warehouseHandler.Delete)
+			masterDelete.DELETE("/suppliers/:id", supplierHandler.Delete)
+			masterDelete.DELETE("/customers/:id", customerHandler.Delete)
+			masterDelete.DELETE("/adjustment-reasons/:id", adjustmentReasonHandler.Delete)
+		}
+
+		// User management (admin only)
+		users := account.Group("/users")
+		users.Use(middleware.RequirePermission(models.PermissionUserManage))
+		{
+			users.GET("", userHandler.GetAll)
+			users.GET("/lockout-events", userHandler.LockoutEvents)
+			users.POST("", userHandler.Create)
+			users.POST("/:id/disable", userHandler.Disable)
+			users.POST("/:id/enable", userHandler.Enable)
+			users.POST("/:id/password", userHandler.ResetPassword)
+			users.POST("/:id/revoke-sessions", userHandler.RevokeSessions)
+			users.POST("/:id/unlock", userHandler.Unlock)
+			users.POST("/:id/2fa/reset", userHandler.ResetTwoFactor)
+		}
+
+		// Stock consistency check against the ledger (admin only)
+		verify := account.Group("/admin/verify")
+		verify.Use(middleware.RequirePermission(models.PermissionUserManage))
+		{
+			verify.GET("", stockHandler.Verify)
+			verify.POST("/repair", stockHandler.Repair)
+		}
+
+		// Audit log (admin only)
+		audit := account.Group("/audit")
+		audit.Use(middleware.RequirePermission(models.PermissionUserManage))
+		{
+			audit.GET("", auditHandler.GetAll)
+		}
+	}
+
+	return router
+}
@@ -0,0 +1,631 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"zaiko/internal/config"
+	"zaiko/internal/database"
+	"zaiko/internal/database/dbtest"
+	"zaiko/internal/middleware"
+	"zaiko/internal/models"
+	"zaiko/internal/totp"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	middleware.SetJWTSecret("test-secret")
+}
+
+// testServer is the full API on a fresh in-memory database. It remembers
+// which routes its requests reached.
+type testServer struct {
+	t      *testing.T
+	db     *database.DB
+	router *gin.Engine
+	token  string
+	hit    map[string]bool
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	cfg := &config.Config{
+		AccessTokenMinutes:    15,
+		RefreshTokenDays:      30,
+		LoginMaxFailures:      5,
+		LoginMaxFailuresPerIP: 20,
+		LoginLockoutMinutes:   15,
+		DefaultReorderPoint:   10,
+		CostingMethod:         "moving_average",
+	}
+	db := dbtest.New(t)
+	return &testServer{
+		t:      t,
+		db:     db,
+		router: newRouter(cfg, db),
+		hit:    make(map[string]bool),
+	}
+}
+
+// loginAdmin logs in as the seeded admin and changes the initial password.
+func (s *testServer) loginAdmin() {
+	s.t.Helper()
+
+	var login struct{ Token string }
+	s.expect(http.MethodPost, "/api/auth/login", `{"username":"admin","password":"admin"}`, http.StatusOK, &login)
+	s.token = login.Token
+
+	var changed struct{ Token string }
+	s.expect(http.MethodPut, "/api/auth/password", `{"current_password":"admin","new_password":"password1"}`, http.StatusOK, &changed)
+	s.token = changed.Token
+}
+
+// do sends a request with the current token.
+func (s *testServer) do(method, path, body string) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.hit[s.route(method, req.URL.Path)] = true
+	return w
+}
+
+// expect sends a request, checks the status and decodes the response into
+// out if it is not nil.
+func (s *testServer) expect(method, path, body string, status int, out interface{}) {
+	s.t.Helper()
+
+	w := s.do(method, path, body)
+	if w.Code != status {
+		s.t.Fatalf("%s %s: status = %d, want %d: %s", method, path, w.Code, status, w.Body)
+	}
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			s.t.Fatalf("%s %s: decode response: %v", method, path, err)
+		}
+	}
+}
+
+// route returns the registered route that serves a request path, preferring
+// literal segments over parameters.
+func (s *testServer) route(method, path string) string {
+	best, bestParams := "", -1
+	for _, r := range s.router.Routes() {
+		if r.Method != method {
+			continue
+		}
+		pattern := regexp.MustCompile(`:[^/]+`).ReplaceAllString(regexp.QuoteMeta(r.Path), `[^/]+`)
+		if !regexp.MustCompile("^" + pattern + "$").MatchString(path) {
+			continue
+		}
+		if params := strings.Count(r.Path, ":"); bestParams < 0 || params < bestParams {
+			best, bestParams = r.Method+" "+r.Path, params
+		}
+	}
+	return best
+}
+
+func TestEveryRoute(t *testing.T) {
+	s := newTestServer(t)
+	s.loginAdmin()
+
+	expiresOn := time.Now().AddDate(0, 1, 0).Format(time.DateOnly)
+
+	steps := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"GET", "/api/auth/me", "", http.StatusOK},
+
+		// Master data
+		{"GET", "/api/categories", "", http.StatusOK},
+		{"POST", "/api/categories", `{"name":"Tools"}`, http.StatusCreated},
+		{"DELETE", "/api/categories/5", "", http.StatusOK},
+		{"POST", "/api/products", `{"code":"P-1","name":"Widget","unit":"pcs","category_id":1}`, http.StatusCreated},
+		{"POST", "/api/products", `{"code":"P-2","name":"Gadget","unit":"pcs"}`, http.StatusCreated},
+		{"POST", "/api/products", `{"code":"S-1","name":"Scanner","unit":"pcs","serialized":true}`, http.StatusCreated},
+		{"GET", "/api/products", "", http.StatusOK},
+		{"GET", "/api/products/1", "", http.StatusOK},
+		{"PUT", "/api/products/1", `{"name":"Widget XL"}`, http.StatusOK},
+		{"DELETE", "/api/products/2", "", http.StatusOK},
+		{"GET", "/api/products/2", "", http.StatusNotFound},
+		{"PUT", "/api/products/1/reorder", `{"reorder_point":50}`, http.StatusOK},
+		{"GET", "/api/products/1/reorder", "", http.StatusOK},
+		{"POST", "/api/warehouses", `{"name":"Main"}`, http.StatusCreated},
+		{"POST", "/api/warehouses", `{"name":"Sub"}`, http.StatusCreated},
+		{"POST", "/api/warehouses", `{"name":"Temporary"}`, http.StatusCreated},
+		{"GET", "/api/warehouses", "", http.StatusOK},
+		{"GET", "/api/warehouses/1", "", http.StatusOK},
+		{"PUT", "/api/warehouses/1", `{"location":"Tokyo"}`, http.StatusOK},
+		{"DELETE", "/api/warehouses/3", "", http.StatusOK},
+		{"POST", "/api/suppliers", `{"name":"Acme","lead_time_days":7}`, http.StatusCreated},
+		{"POST", "/api/suppliers", `{"name":"Spare"}`, http.StatusCreated},
+		{"GET", "/api/suppliers", "", http.StatusOK},
+		{"GET", "/api/suppliers/1", "", http.StatusOK},
+		{"PUT", "/api/suppliers/1", `{"contact":"sales@acme.example"}`, http.StatusOK},
+		{"DELETE", "/api/suppliers/2", "", http.StatusOK},
+		{"POST", "/api/customers", `{"name":"Shop"}`, http.StatusCreated},
+		{"POST", "/api/customers", `{"name":"Spare"}`, http.StatusCreated},
+		{"GET", "/api/customers", "", http.StatusOK},
+		{"GET", "/api/customers/1", "", http.StatusOK},
+		{"PUT", "/api/customers/1", `{"address":"Osaka"}`, http.StatusOK},
+		{"DELETE", "/api/customers/2", "", http.StatusOK},
+		{"POST", "/api/adjustment-reasons", `{"code":"THEFT","name":"盗難"}`, http.StatusCreated},
+		{"GET", "/api/adjustment-reasons", "", http.StatusOK},
+		{"PUT", "/api/adjustment-reasons/5", `{"name":"盗難・万引き"}`, http.StatusOK},
+		{"DELETE", "/api/adjustment-reasons/5", "", http.StatusOK},
+
+		// Stock movements, lots and serial numbers
+		{"POST", "/api/stock/in", `{"product_id":1,"warehouse_id":1,"quantity":20,"lot":{"lot_number":"L-1","expires_on":"` + expiresOn + `"}}`, http.StatusOK},
+		{"POST", "/api/stock/out", `{"product_id":1,"warehouse_id":1,"quantity":2}`, http.StatusOK},
+		{"POST", "/api/stock/out", `{"product_id":1,"warehouse_id":1,"quantity":100}`, http.StatusBadRequest},
+		{"POST", "/api/stock/transfer", `{"product_id":1,"from_warehouse_id":1,"to_warehouse_id":2,"quantity":3}`, http.StatusOK},
+		{"POST", "/api/stock/in", `{"product_id":3,"warehouse_id":1,"quantity":1,"serials":["SN-1"]}`, http.StatusOK},
+		{"POST", "/api/stock/adjust", `{"product_id":1,"warehouse_id":1,"quantity":-1,"reason_id":1}`, http.StatusOK},
+		{"POST", "/api/stock/adjust", `{"product_id":1,"warehouse_id":1,"reason_id":1}`, http.StatusBadRequest},
+		{"GET", "/api/stock", "", http.StatusOK},
+		{"GET", "/api/stock/low", "", http.StatusOK},
+		{"GET", "/api/stock/transactions", "", http.StatusOK},
+		{"GET", "/api/stock/transactions?type=adjustment&reason_id=1", "", http.StatusOK},
+		{"POST", "/api/stock/transactions/2/reverse", "", http.StatusOK},
+		{"POST", "/api/stock/transactions/2/reverse", "", http.StatusConflict},
+		{"GET", "/api/stock/ledger?product_id=1&warehouse_id=1", "", http.StatusOK},
+		{"GET", "/api/stock/valuation?as_of=" + time.Now().UTC().Format(time.DateOnly), "", http.StatusOK},
+		{"GET", "/api/lots", "", http.StatusOK},
+		{"GET", "/api/lots/expiring?days=60", "", http.StatusOK},
+		{"GET", "/api/serials/SN-1", "", http.StatusOK},
+		{"DELETE", "/api/products/1/reorder", "", http.StatusOK},
+
+		// Reservations
+		{"POST", "/api/reservations", `{"product_id":1,"warehouse_id":1,"quantity":1,"owner_ref":"web:1"}`, http.StatusCreated},
+		{"GET", "/api/reservations", "", http.StatusOK},
+		{"GET", "/api/reservations/1", "", http.StatusOK},
+		{"DELETE", "/api/reservations/1", "", http.StatusOK},
+
+		// Inventory counts
+		{"POST", "/api/counts", `{"warehouse_id":2}`, http.StatusCreated},
+		{"GET", "/api/counts", "", http.StatusOK},
+		{"GET", "/api/counts/1", "", http.StatusOK},
+		{"PUT", "/api/counts/1/lines", `{"lines":[{"product_id":1,"warehouse_id":2,"counted_quantity":2}]}`, http.StatusOK},
+		{"POST", "/api/counts/1/finalize", "", http.StatusOK},
+		{"POST", "/api/counts", `{"warehouse_id":2}`, http.StatusCreated},
+		{"POST", "/api/counts/2/cancel", "", http.StatusOK},
+
+		// Purchase orders
+		{"POST", "/api/purchase-orders", `{"supplier_id":1,"warehouse_id":1,"lines":[{"product_id":1,"quantity":5}]}`, http.StatusCreated},
+		{"POST", "/api/purchase-orders", `{"supplier_id":1,"warehouse_id":1,"lines":[{"product_id":1,"quantity":1}]}`, http.StatusCreated},
+		{"GET", "/api/purchase-orders", "", http.StatusOK},
+		{"GET", "/api/purchase-orders/1", "", http.StatusOK},
+		{"POST", "/api/purchase-orders/1/order", "", http.StatusOK},
+		{"POST", "/api/purchase-orders/1/receive", `{"lines":[{"line_id":1,"quantity":2}]}`, http.StatusOK},
+		{"POST", "/api/purchase-orders/1/close", "", http.StatusOK},
+		{"DELETE", "/api/purchase-orders/2", "", http.StatusOK},
+
+		// Sales orders
+		{"POST", "/api/sales-orders", `{"customer_id":1,"warehouse_id":1,"lines":[{"product_id":1,"quantity":2}]}`, http.StatusCreated},
+		{"POST", "/api/sales-orders", `{"customer_id":1,"warehouse_id":1,"lines":[{"product_id":1,"quantity":1}]}`, http.StatusCreated},
+		{"GET", "/api/sales-orders", "", http.StatusOK},
+		{"GET", "/api/sales-orders/1", "", http.StatusOK},
+		{"POST", "/api/sales-orders/1/confirm", "", http.StatusOK},
+		{"POST", "/api/sales-orders/1/allocate", "", http.StatusOK},
+		{"POST", "/api/sales-orders/1/ship", `{"lines":[{"line_id":1,"quantity":1}]}`, http.StatusOK},
+		{"POST", "/api/sales-orders/1/cancel", "", http.StatusOK},
+		{"DELETE", "/api/sales-orders/2", "", http.StatusOK},
+
+		{"GET", "/api/dashboard/summary", "", http.StatusOK},
+		{"GET", "/api/admin/verify", "", http.StatusOK},
+		{"POST", "/api/admin/verify/repair", "", http.StatusOK},
+
+		// Users, API keys and the audit log
+		{"POST", "/api/users", `{"username":"clerk","password":"password1","role":"operator"}`, http.StatusCreated},
+		{"GET", "/api/users", "", http.StatusOK},
+		{"POST", "/api/users/2/disable", "", http.StatusOK},
+		{"POST", "/api/users/2/enable", "", http.StatusOK},
+		{"POST", "/api/users/2/password", `{"password":"password2"}`, http.StatusOK},
+		{"POST", "/api/users/2/revoke-sessions", "", http.StatusOK},
+		{"POST", "/api/users/2/unlock", "", http.StatusOK},
+		{"POST", "/api/users/2/2fa/reset", "", http.StatusOK},
+		{"GET", "/api/users/lockout-events", "", http.StatusOK},
+		{"POST", "/api/api-keys", `{"name":"scanner","permissions":["read"]}`, http.StatusCreated},
+		{"GET", "/api/api-keys", "", http.StatusOK},
+		{"DELETE", "/api/api-keys/1", "", http.StatusOK},
+		{"GET", "/api/audit?entity_type=product", "", http.StatusOK},
+	}
+
+	for _, step := range steps {
+		s.expect(step.method, step.path, step.body, step.status, nil)
+	}
+
+	// Refresh, two-factor authentication and logout
+	var login struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	s.expect("POST", "/api/auth/login", `{"username":"admin","password":"password1"}`, http.StatusOK, &login)
+	s.expect("POST", "/api/auth/refresh", `{"refresh_token":"`+login.RefreshToken+`"}`, http.StatusOK, nil)
+
+	var setup struct{ Secret string }
+	s.expect("POST", "/api/auth/2fa/setup", "", http.StatusOK, &setup)
+	code, err := totp.Code(setup.Secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatalf("totp code: %v", err)
+	}
+	var recovery struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	s.expect("POST", "/api/auth/2fa/enable", `{"code":"`+code+`"}`, http.StatusOK, &recovery)
+	s.expect("POST", "/api/auth/2fa/recovery-codes", `{"code":"`+recovery.RecoveryCodes[0]+`"}`, http.StatusOK, &recovery)
+
+	var challenge struct{ Challenge string }
+	s.expect("POST", "/api/auth/login", `{"username":"admin","password":"password1"}`, http.StatusOK, &challenge)
+	s.expect("POST", "/api/auth/2fa/verify", `{"challenge":"`+challenge.Challenge+`","code":"`+recovery.RecoveryCodes[0]+`"}`, http.StatusOK, nil)
+	s.expect("POST", "/api/auth/2fa/disable", `{"password":"password1","code":"`+recovery.RecoveryCodes[1]+`"}`, http.StatusOK, nil)
+
+	s.expect("POST", "/api/auth/logout", "", http.StatusOK, nil)
+	s.expect("GET", "/api/auth/me", "", http.StatusUnauthorized, nil)
+
+	for _, r := range s.router.Routes() {
+		if !s.hit[r.Method+" "+r.Path] {
+			t.Errorf("route %s %s is not exercised", r.Method, r.Path)
+		}
+	}
+}
+
+func TestAuthRequired(t *testing.T) {
+	s := newTestServer(t)
+
+	s.expect("GET", "/api/products", "", http.StatusUnauthorized, nil)
+	s.expect("POST", "/api/auth/login", `{"username":"admin","password":"wrong"}`, http.StatusUnauthorized, nil)
+}
+
+func TestPasswordChangeRequired(t *testing.T) {
+	s := newTestServer(t)
+
+	var login struct{ Token string }
+	s.expect("POST", "/api/auth/login", `{"username":"admin","password":"admin"}`, http.StatusOK, &login)
+	s.token = login.Token
+
+	s.expect("GET", "/api/products", "", http.StatusForbidden, nil)
+}
+
+func TestViewerCannotWrite(t *testing.T) {
+	s := newTestServer(t)
+	s.loginAdmin()
+
+	s.expect("POST", "/api/users", `{"username":"viewer","password":"password1","role":"viewer"}`, http.StatusCreated, nil)
+
+	var login struct{ Token string }
+	s.expect("POST", "/api/auth/login", `{"username":"viewer","password":"password1"}`, http.StatusOK, &login)
+	s.token = login.Token
+	s.expect("PUT", "/api/auth/password", `{"current_password":"password1","new_password":"password2"}`, http.StatusOK, &login)
+	s.token = login.Token
+
+	s.expect("GET", "/api/products", "", http.StatusOK, nil)
+	s.expect("POST", "/api/products", `{"code":"P-1","name":"Widget","unit":"pcs"}`, http.StatusForbidden, nil)
+	s.expect("GET", "/api/users", "", http.StatusForbidden, nil)
+}
+
+func TestRoleChangeTakesEffect(t *testing.T) {
+	s := newTestServer(t)
+	s.loginAdmin()
+
+	s.expect("POST", "/api/users", `{"username":"clerk","password":"password1","role":"manager"}`, http.StatusCreated, nil)
+
+	var login struct{ Token string }
+	s.expect("POST", "/api/auth/login", `{"username":"clerk","password":"password1"}`, http.StatusOK, &login)
+	s.token = login.Token
+	s.expect("PUT", "/api/auth/password", `{"current_password":"password1","new_password":"password2"}`, http.StatusOK, &login)
+	s.token = login.Token
+	s.expect("POST", "/api/products", `{"code":"P-1","name":"Widget","unit":"pcs"}`, http.StatusCreated, nil)
+
+	// Demoting the user applies to the access token already issued
+	dbtest.Exec(t, s.db, "UPDATE users SET role = 'viewer' WHERE username = 'clerk'")
+	s.expect("POST", "/api/products", `{"code":"P-2","name":"Gadget","unit":"pcs"}`, http.StatusForbidden, nil)
+}
+
+func TestTwoFactorRequiredForStock(t *testing.T) {
+	middleware.SetTwoFactorRequired(models.PermissionStockWrite, true)
+	t.Cleanup(func() { middleware.SetTwoFactorRequired(models.PermissionStockWrite, false) })
+
+	s := newTestServer(t)
+	s.loginAdmin()
+	s.expect("POST", "/api/products", `{"code":"P-1","name":"Widget","unit":"pcs"}`, http.StatusCreated, nil)
+	s.expect("POST", "/api/warehouses", `{"name":"Main"}`, http.StatusCreated, nil)
+	stockIn := `{"product_id":1,"warehouse_id":1,"quantity":1}`
+
+	// Keys for stock changes need a two-factor session to be created
+	s.expect("POST", "/api/api-keys", `{"name":"scanner","permissions":["stock:write"]}`, http.StatusForbidden, nil)
+	var other struct{ Token string }
+	s.expect("POST", "/api/auth/login", `{"username":"admin","password":"password1"}`, http.StatusOK, &other)
+
+	s.expect("POST", "/api/stock/in", stockIn, http.StatusForbidden, nil)
+
+	// Enabling two-factor authentication counts for the session it is done in
+	var setup struct{ Secret string }
+	s.expect("POST", "/api/auth/2fa/setup", "", http.StatusOK, &setup)
+	code, err := totp.Code(setup.Secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatalf("totp code: %v", err)
+	}
+	var recovery struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	s.expect("POST", "/api/auth/2fa/enable", `{"code":"`+code+`"}`, http.StatusOK, &recovery)
+	s.expect("POST", "/api/stock/in", stockIn, http.StatusOK, nil)
+	verified := s.token
+
+	// but not for sessions started before
+	s.token = other.Token
+	s.expect("POST", "/api/stock/in", stockIn, http.StatusForbidden, nil)
+
+	// API keys cannot enter a code, so keys created from a two-factor
+	// session are not asked for one
+	s.token = verified
+	var created struct{ Key string }
+	s.expect("POST", "/api/api-keys", `{"name":"scanner","permissions":["stock:write"]}`, http.StatusCreated, &created)
+	s.token = created.Key
+	s.expect("POST", "/api/stock/in", stockIn, http.StatusOK, nil)
+
+	// A two-factor login keeps counting after a refresh
+	var challenge struct{ Challenge string }
+	s.expect("POST", "/api/auth/login", `{"username":"admin","password":"password1"}`, http.StatusOK, &challenge)
+	var login struct {
+		Token        string
+		RefreshToken string `json:"refresh_token"`
+	}
+	s.expect("POST", "/api/auth/2fa/verify", `{"challenge":"`+challenge.Challenge+`","code":"`+recovery.RecoveryCodes[0]+`"}`, http.StatusOK, &login)
+	s.expect("POST", "/api/auth/refresh", `{"refresh_token":"`+login.RefreshToken+`"}`, http.StatusOK, &login)
+	s.token = login.Token
+	s.expect("POST", "/api/stock/in", stockIn, http.StatusOK, nil)
+
+	// Sessions stop counting once two-factor authentication is turned off
+	s.expect("POST", "/api/auth/2fa/disable", `{"password":"password1","code":"`+recovery.RecoveryCodes[1]+`"}`, http.StatusOK, nil)
+	s.token = verified
+	s.expect("POST", "/api/stock/in", stockIn, http.StatusForbidden, nil)
+
+	// Keys that were already created keep working
+	s.token = created.Key
+	s.expect("POST", "/api/stock/in", stockIn, http.StatusOK, nil)
+}
+
+func TestTwoFactorCodesCountAsFailedLogins(t *testing.T) {
+	s := newTestServer(t)
+	s.loginAdmin()
+
+	var setup struct{ Secret string }
+	s.expect("POST", "/api/auth/2fa/setup", "", http.StatusOK, &setup)
+	code, err := totp.Code(setup.Secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatalf("totp code: %v", err)
+	}
+	var recovery struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	s.expect("POST", "/api/auth/2fa/enable", `{"code":"`+code+`"}`, http.StatusOK, &recovery)
+	admin := s.token
+	s.token = ""
+
+	// Every wrong code is a failed login, and the correct password in
+	// between does not clear them
+	var challenge struct{ Challenge string }
+	for i := 0; i < 5; i++ {
+		if i > 0 {
+			// Skip the wait between attempts
+			dbtest.Exec(t, s.db, "UPDATE login_throttles SET locked_until = NULL")
+		}
+		s.expect("POST", "/api/auth/login", `{"username":"admin","password":"password1"}`, http.StatusOK, &challenge)
+		s.expect("POST", "/api/auth/2fa/verify", `{"challenge":"`+challenge.Challenge+`","code":"000000"}`, http.StatusUnauthorized, nil)
+	}
+
+	// Locked out, even with a valid code for a challenge that is still open
+	s.expect("POST", "/api/auth/login", `{"username":"admin","password":"password1"}`, http.StatusTooManyRequests, nil)
+	s.expect("POST", "/api/auth/2fa/verify", `{"challenge":"`+challenge.Challenge+`","code":"`+recovery.RecoveryCodes[0]+`"}`, http.StatusTooManyRequests, nil)
+
+	s.token = admin
+	var events []struct{ Key, Event string }
+	s.expect("GET", "/api/users/lockout-events", "", http.StatusOK, &events)
+	if len(events) != 1 || events[0].Key != "admin" || events[0].Event != "locked" {
+		t.Errorf("lockout events = %+v, want admin locked", events)
+	}
+}
+
+func TestAuditFailureRollsBackMasterData(t *testing.T) {
+	s := newTestServer(t)
+	s.loginAdmin()
+	s.expect("POST", "/api/products", `{"code":"P-1","name":"Widget","unit":"pcs"}`, http.StatusCreated, nil)
+
+	dbtest.Exec(t, s.db, "CREATE TRIGGER audit_unavailable BEFORE INSERT ON audit_log BEGIN SELECT RAISE(ABORT, 'audit unavailable'); END")
+	s.expect("POST", "/api/products", `{"code":"P-2","name":"Gadget","unit":"pcs"}`, http.StatusInternalServerError, nil)
+	s.expect("PUT", "/api/products/1", `{"name":"Widget XL"}`, http.StatusInternalServerError, nil)
+	s.expect("DELETE", "/api/products/1", "", http.StatusInternalServerError, nil)
+	dbtest.Exec(t, s.db, "DROP TRIGGER audit_unavailable")
+
+	var products []struct{ Name string }
+	s.expect("GET", "/api/products", "", http.StatusOK, &products)
+	if len(products) != 1 || products[0].Name != "Widget" {
+		t.Errorf("products = %+v, want only the unchanged Widget", products)
+	}
+}
+
+func TestAuditFailureRollsBackAccountChanges(t *testing.T) {
+	s := newTestServer(t)
+	s.loginAdmin()
+	s.expect("POST", "/api/users", `{"username":"clerk","password":"password1","role":"viewer"}`, http.StatusCreated, nil)
+	var created struct{ Key string }
+	s.expect("POST", "/api/api-keys", `{"name":"reader","permissions":["read"]}`, http.StatusCreated, &created)
+
+	var setup struct{ Secret string }
+	s.expect("POST", "/api/auth/2fa/setup", "", http.StatusOK, &setup)
+	code, err := totp.Code(setup.Secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatalf("totp code: %v", err)
+	}
+	var recovery struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	s.expect("POST", "/api/auth/2fa/enable", `{"code":"`+code+`"}`, http.StatusOK, &recovery)
+	s.expect("POST", "/api/auth/2fa/recovery-codes", `{"code":"`+recovery.RecoveryCodes[0]+`"}`, http.StatusOK, &recovery)
+
+	var entries []struct{ Action string }
+	s.expect("GET", "/api/audit?action=regenerate_recovery_codes", "", http.StatusOK, &entries)
+	if len(entries) != 1 {
+		t.Errorf("recovery code audit entries = %d, want 1", len(entries))
+	}
+
+	dbtest.Exec(t, s.db, "CREATE TRIGGER audit_unavailable BEFORE INSERT ON audit_log BEGIN SELECT RAISE(ABORT, 'audit unavailable'); END")
+	s.expect("POST", "/api/users", `{"username":"other","password":"password1","role":"viewer"}`, http.StatusInternalServerError, nil)
+	s.expect("POST", "/api/users/2/disable", "", http.StatusInternalServerError, nil)
+	s.expect("DELETE", "/api/api-keys/1", "", http.StatusInternalServerError, nil)
+	s.expect("POST", "/api/auth/2fa/recovery-codes", `{"code":"`+recovery.RecoveryCodes[0]+`"}`, http.StatusInternalServerError, nil)
+	s.expect("PUT", "/api/auth/password", `{"current_password":"password1","new_password":"password2"}`, http.StatusInternalServerError, nil)
+	dbtest.Exec(t, s.db, "DROP TRIGGER audit_unavailable")
+
+	var users []struct {
+		Username string
+		Disabled bool
+	}
+	s.expect("GET", "/api/users", "", http.StatusOK, &users)
+	if len(users) != 2 || users[0].Username != "admin" || users[1].Username != "clerk" || users[1].Disabled {
+		t.Errorf("users = %+v, want admin and the enabled clerk", users)
+	}
+
+	// The recovery code was not used up and the session was not revoked
+	s.expect("POST", "/api/auth/2fa/recovery-codes", `{"code":"`+recovery.RecoveryCodes[0]+`"}`, http.StatusOK, nil)
+	s.token = created.Key
+	s.expect("GET", "/api/products", "", http.StatusOK, nil)
+}
+
+func TestLowStockSuggestion(t *testing.T) {
+	s := newTestServer(t)
+	s.loginAdmin()
+	s.expect("POST", "/api/products", `{"code":"P-1","name":"Widget","unit":"pcs"}`, http.StatusCreated, nil)
+	s.expect("POST", "/api/products", `{"code":"P-2","name":"Gadget","unit":"pcs"}`, http.StatusCreated, nil)
+	s.expect("POST", "/api/warehouses", `{"name":"Main"}`, http.StatusCreated, nil)
+	s.expect("POST", "/api/stock/in", `{"product_id":1,"warehouse_id":1,"quantity":8}`, http.StatusOK, nil)
+	s.expect("POST", "/api/stock/in", `{"product_id":2,"warehouse_id":1,"quantity":8}`, http.StatusOK, nil)
+
+	// Refilling to the maximum needs 12, refilling to the reorder point 2,
+	// which is raised to the minimum order quantity of 5
+	s.expect("PUT", "/api/products/1/reorder", `{"reorder_point":10,"max_quantity":20,"min_quantity":5}`, http.StatusOK, nil)
+	s.expect("PUT", "/api/products/2/reorder", `{"reorder_point":10,"min_quantity":5}`, http.StatusOK, nil)
+
+	var low []struct {
+		ProductID int64 `json:"product_id"`
+		Suggested int   `json:"suggested_order_quantity"`
+	}
+	s.expect("GET", "/api/stock/low", "", http.StatusOK, &low)
+	suggested := map[int64]int{}
+	for _, item := range low {
+		suggested[item.ProductID] = item.Suggested
+	}
+	if suggested[1] != 12 || suggested[2] != 5 {
+		t.Errorf("suggested order quantities = %v, want 12 for product 1 and 5 for product 2", suggested)
+	}
+}
+
+func TestAPIKeyWarehouseScope(t *testing.T) {
+	s := newTestServer(t)
+	s.loginAdmin()
+
+	s.expect("POST", "/api/products", `{"code":"P-1","name":"Widget","unit":"pcs"}`, http.StatusCreated, nil)
+	s.expect("POST", "/api/products", `{"code":"S-1","name":"Scanner","unit":"pcs","serialized":true}`, http.StatusCreated, nil)
+	s.expect("POST", "/api/warehouses", `{"name":"Main"}`, http.StatusCreated, nil)
+	s.expect("POST", "/api/warehouses", `{"name":"Sub"}`, http.StatusCreated, nil)
+	expiresOn := time.Now().AddDate(0, 0, 10).Format(time.DateOnly)
+	s.expect("POST", "/api/stock/in", `{"product_id":1,"warehouse_id":2,"quantity":3,"lot":{"lot_number":"L-2","expires_on":"`+expiresOn+`"}}`, http.StatusOK, nil)
+	s.expect("POST", "/api/stock/in", `{"product_id":2,"warehouse_id":2,"quantity":1,"serials":["SN-2"]}`, http.StatusOK, nil)
+
+	var created struct{ Key string }
+	s.expect("POST", "/api/api-keys", `{"name":"scanner","permissions":["read","stock:write"],"warehouse_ids":[1]}`, http.StatusCreated, &created)
+	s.token = created.Key
+
+	s.expect("POST", "/api/stock/in", `{"product_id":1,"warehouse_id":1,"quantity":5}`, http.StatusOK, nil)
+	s.expect("POST", "/api/stock/in", `{"product_id":1,"warehouse_id":2,"quantity":5}`, http.StatusForbidden, nil)
+	s.expect("GET", "/api/lots?warehouse_id=2", "", http.StatusForbidden, nil)
+	s.expect("GET", "/api/lots/expiring?warehouse_id=2", "", http.StatusForbidden, nil)
+	s.expect("PUT", "/api/auth/password", `{"current_password":"password1","new_password":"password2"}`, http.StatusForbidden, nil)
+
+	var stock []struct {
+		WarehouseID int64 `json:"warehouse_id"`
+		Quantity    int   `json:"quantity"`
+	}
+	s.expect("GET", "/api/stock", "", http.StatusOK, &stock)
+	if len(stock) != 1 || stock[0].WarehouseID != 1 || stock[0].Quantity != 5 {
+		t.Errorf("stock = %+v, want 5 in warehouse 1", stock)
+	}
+
+	// Lots and serial numbers in warehouse 2 are hidden
+	s.expect("POST", "/api/stock/in", `{"product_id":1,"warehouse_id":1,"quantity":2,"lot":{"lot_number":"L-1","expires_on":"`+expiresOn+`"}}`, http.StatusOK, nil)
+	for _, path := range []string{"/api/lots", "/api/lots/expiring"} {
+		var lots []struct {
+			LotNumber string `json:"lot_number"`
+		}
+		s.expect("GET", path, "", http.StatusOK, &lots)
+		if len(lots) != 1 || lots[0].LotNumber != "L-1" {
+			t.Errorf("GET %s = %+v, want only L-1", path, lots)
+		}
+	}
+	s.expect("GET", "/api/serials/SN-2", "", http.StatusNotFound, nil)
+	s.expect("POST", "/api/stock/in", `{"product_id":2,"warehouse_id":1,"quantity":1,"serials":["SN-1"]}`, http.StatusOK, nil)
+	s.expect("GET", "/api/serials/SN-1", "", http.StatusOK, nil)
+}
+
+func TestDeleteInUse(t *testing.T) {
+	s := newTestServer(t)
+	s.loginAdmin()
+
+	s.expect("POST", "/api/products", `{"code":"P-1","name":"Widget","unit":"pcs","category_id":1}`, http.StatusCreated, nil)
+	s.expect("POST", "/api/products", `{"code":"P-2","name":"Gadget","unit":"pcs"}`, http.StatusCreated, nil)
+	s.expect("POST", "/api/warehouses", `{"name":"Main"}`, http.StatusCreated, nil)
+	s.expect("POST", "/api/stock/in", `{"product_id":1,"warehouse_id":1,"quantity":5}`, http.StatusOK, nil)
+	s.expect("PUT", "/api/products/2/reorder", `{"reorder_point":5}`, http.StatusOK, nil)
+
+	var conflict struct {
+		References map[string]int `json:"references"`
+	}
+	s.expect("DELETE", "/api/products/1", "", http.StatusConflict, &conflict)
+	if conflict.References["stock"] != 1 || conflict.References["transactions"] != 1 {
+		t.Errorf("product references = %v, want 1 stock row and 1 transaction", conflict.References)
+	}
+
+	conflict.References = nil
+	s.expect("DELETE", "/api/warehouses/1", "", http.StatusConflict, &conflict)
+	if conflict.References["stock"] != 1 || conflict.References["transactions"] != 1 {
+		t.Errorf("warehouse references = %v, want 1 stock row and 1 transaction", conflict.References)
+	}
+
+	conflict.References = nil
+	s.expect("POST", "/api/stock/adjust", `{"product_id":1,"warehouse_id":1,"quantity":-1,"reason_id":1}`, http.StatusOK, nil)
+	s.expect("DELETE", "/api/adjustment-reasons/1", "", http.StatusConflict, &conflict)
+	if conflict.References["transactions"] != 1 {
+		t.Errorf("adjustment reason references = %v, want 1 transaction", conflict.References)
+	}
+
+	conflict.References = nil
+	s.expect("DELETE", "/api/categories/1", "", http.StatusConflict, &conflict)
+	if conflict.References["products"] != 1 {
+		t.Errorf("category references = %v, want 1 product", conflict.References)
+	}
+
+	// Reorder settings belong to the product and go with it
+	s.expect("DELETE", "/api/products/2", "", http.StatusOK, nil)
+	s.expect("GET", "/api/products/1", "", http.StatusOK, nil)
+	s.expect("GET", "/api/warehouses/1", "", http.StatusOK, nil)
+}
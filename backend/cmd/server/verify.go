@@ -0,0 +1,83 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"zaiko/internal/config"
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+	"zaiko/internal/repository"
+	"zaiko/internal/service"
+)
+
+// errMismatches makes the verify subcommand exit non-zero while stock and
+// the ledger disagree on any row that was not repaired.
+var errMismatches = errors.New("stock does not match the transaction ledger")
+
+// runVerify runs the verify subcommand against db:
+// server verify [-repair] [-user name]
+func runVerify(cfg *config.Config, db *database.DB, args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	repair := flags.Bool("repair", false, "post adjustment transactions for the mismatches")
+	username := flags.String("user", "admin", "user the repair adjustments are recorded for")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	user, err := repository.NewUserRepository(db).FindByUsername(*username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("user %q not found", *username)
+	}
+	if err != nil {
+		return err
+	}
+
+	stockService := service.NewStockService(db, models.CostingMethod(cfg.CostingMethod))
+	report, err := stockService.Verify(*repair, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := printVerifyReport(os.Stdout, report); err != nil {
+		return err
+	}
+	if report.Unrepaired > 0 {
+		return errMismatches
+	}
+	return nil
+}
+
+func printVerifyReport(out io.Writer, report *models.VerifyReport) error {
+	if len(report.Mismatches) == 0 {
+		fmt.Fprintln(out, "Stock matches the transaction ledger")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PRODUCT\tWAREHOUSE\tSTOCK\tLEDGER\tDIFFERENCE\tREPAIR")
+	for _, m := range report.Mismatches {
+		product := fmt.Sprintf("%d %s", m.ProductID, m.ProductCode)
+		warehouse := fmt.Sprintf("%d %s", m.WarehouseID, m.WarehouseName)
+
+		repair := "-"
+		switch {
+		case m.TransactionID != nil:
+			repair = fmt.Sprintf("transaction %d", *m.TransactionID)
+		case m.Orphaned:
+			repair = "orphaned"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%+d\t%s\n", product, warehouse, m.StockQuantity, m.LedgerQuantity, m.Difference, repair)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(out, "%d repaired, %d unrepaired\n", report.Repaired, report.Unrepaired)
+	return err
+}
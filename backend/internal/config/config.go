@@ -2,21 +2,44 @@ package config
 
 import (
 	"os"
+	"strconv"
 )
 
 type Config struct {
-	ServerPort    string
-	DatabasePath  string
-	JWTSecret     string
-	JWTExpiration int // hours
+	ServerPort   string
+	DatabasePath string
+	// DatabaseURL selects PostgreSQL instead of the SQLite file at DatabasePath
+	DatabaseURL        string
+	JWTSecret          string
+	AccessTokenMinutes int
+	RefreshTokenDays   int
+	// Failed logins before a username or IP is locked out (0 disables)
+	LoginMaxFailures      int
+	LoginMaxFailuresPerIP int
+	LoginLockoutMinutes   int
+	// RequireTwoFactorForStock makes users enable two-factor authentication
+	// before they can move or adjust stock
+	RequireTwoFactorForStock bool
+	// DefaultReorderPoint applies to stock without reorder settings (0 disables)
+	DefaultReorderPoint int
+	// CostingMethod values stock issues: "moving_average" or "fifo"
+	CostingMethod string
 }
 
 func Load() *Config {
 	return &Config{
-		ServerPort:    getEnv("SERVER_PORT", "8080"),
-		DatabasePath:  getEnv("DATABASE_PATH", "./zaiko.db"),
-		JWTSecret:     getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
-		JWTExpiration: 24,
+		ServerPort:               getEnv("SERVER_PORT", "8080"),
+		DatabasePath:             getEnv("DATABASE_PATH", "./zaiko.db"),
+		DatabaseURL:              getEnv("DATABASE_URL", ""),
+		JWTSecret:                getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
+		AccessTokenMinutes:       getEnvInt("ACCESS_TOKEN_MINUTES", 15),
+		RefreshTokenDays:         getEnvInt("REFRESH_TOKEN_DAYS", 30),
+		LoginMaxFailures:         getEnvInt("LOGIN_MAX_FAILURES", 5),
+		LoginMaxFailuresPerIP:    getEnvInt("LOGIN_MAX_FAILURES_PER_IP", 20),
+		LoginLockoutMinutes:      getEnvInt("LOGIN_LOCKOUT_MINUTES", 15),
+		RequireTwoFactorForStock: getEnvBool("REQUIRE_2FA_FOR_STOCK", false),
+		DefaultReorderPoint:      getEnvInt("DEFAULT_REORDER_POINT", 10),
+		CostingMethod:            getEnv("COSTING_METHOD", "moving_average"),
 	}
 }
 
@@ -26,3 +49,17 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
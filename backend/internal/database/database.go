@@ -1,32 +1,150 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"log"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
-var DB *sql.DB
+// Dialect identifies the database engine behind a DB.
+type Dialect string
 
-func Connect(databasePath string) error {
-	var err error
-	DB, err = sql.Open("sqlite3", databasePath)
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+// DB is an open database connection pool. It is shared by the repositories,
+// which all run their queries through it or through a transaction it began.
+type DB struct {
+	*sql.DB
+	Dialect Dialect
+}
+
+// sqliteOptions are set on every SQLite connection unless dataSource
+// already sets them. Foreign keys are off by default in SQLite; WAL lets
+// readers run alongside the single writer, and the busy timeout makes a
+// writer wait for the lock instead of failing at once.
+var sqliteOptions = []string{"_foreign_keys=on", "_journal_mode=WAL", "_busy_timeout=5000"}
+
+// Connect opens the SQLite database at dataSource, a file path or a URI
+// such as file::memory:.
+func Connect(dataSource string) (*DB, error) {
+	sqlDB, err := sql.Open("sqlite3", sqliteDSN(dataSource))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
+	log.Println("Database connected successfully")
+	return &DB{DB: sqlDB, Dialect: SQLite}, nil
+}
+
+// sqliteDSN adds the sqliteOptions that dataSource does not set itself.
+func sqliteDSN(dataSource string) string {
+	dsn := dataSource
+	sep := "?"
+	if strings.Contains(dataSource, "?") {
+		sep = "&"
+	}
+
+	for _, option := range sqliteOptions {
+		key := option[:strings.IndexByte(option, '=')+1]
+		if strings.Contains(dataSource, "?"+key) || strings.Contains(dataSource, "&"+key) {
+			continue
+		}
+		dsn += sep + option
+		sep = "&"
+	}
+	return dsn
+}
+
+// ConnectPostgres opens the PostgreSQL database at databaseURL, a
+// postgres:// URL or a key=value connection string.
+func ConnectPostgres(databaseURL string) (*DB, error) {
+	sqlDB, err := openPostgres(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
+	log.Println("Database connected successfully (PostgreSQL)")
+	return &DB{DB: sqlDB, Dialect: Postgres}, nil
+}
+
+// InTx runs fn in a transaction. The transaction is committed if fn
+// returns nil and rolled back otherwise.
+func (db *DB) InTx(fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	if err = DB.Ping(); err != nil {
+	if err := fn(tx); err != nil {
 		return err
 	}
+	return tx.Commit()
+}
 
-	log.Println("Database connected successfully")
-	return nil
+// InStockTx runs fn like InTx, for work that reads stock, reservations or
+// count sessions and then writes based on what it read. SQLite runs one
+// writer at a time and the services rely on that; on PostgreSQL the same
+// guarantee comes from a transaction-level advisory lock that only these
+// transactions take, so reads and other writes are not held up.
+func (db *DB) InStockTx(fn func(tx *sql.Tx) error) error {
+	return db.InTx(func(tx *sql.Tx) error {
+		if db.Dialect == Postgres {
+			if _, err := tx.Exec("SELECT pg_advisory_xact_lock(?)", stockLockKey); err != nil {
+				return err
+			}
+		}
+		return fn(tx)
+	})
 }
 
-func Close() error {
-	if DB != nil {
-		return DB.Close()
+// beginSchemaChange starts a transaction for work that rebuilds tables.
+// SQLite checks foreign keys on DROP TABLE and cannot switch them off inside
+// a transaction, so the transaction runs on a connection of its own that has
+// them turned off until the returned release function is called, after the
+// transaction has ended. PostgreSQL constraints are deferred to commit and
+// need nothing special.
+func (db *DB) beginSchemaChange() (*sql.Tx, func(), error) {
+	if db.Dialect != SQLite {
+		tx, err := db.Begin()
+		return tx, func() {}, err
+	}
+
+	ctx := context.Background()
+	c, err := db.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := c.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+
+	release := func() {
+		c.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+		c.Close()
+	}
+
+	tx, err := c.BeginTx(ctx, nil)
+	if err != nil {
+		release()
+		return nil, nil, err
 	}
-	return nil
+	return tx, release, nil
 }
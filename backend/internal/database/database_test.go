@@ -0,0 +1,48 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteDSN(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"zaiko.db", "zaiko.db?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000"},
+		{"file:test?mode=memory&cache=shared", "file:test?mode=memory&cache=shared&_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000"},
+		{"zaiko.db?_busy_timeout=100", "zaiko.db?_busy_timeout=100&_foreign_keys=on&_journal_mode=WAL"},
+	}
+
+	for _, tt := range tests {
+		if got := sqliteDSN(tt.in); got != tt.want {
+			t.Errorf("sqliteDSN(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestForeignKeysEnforced(t *testing.T) {
+	db, err := Connect(filepath.Join(t.TempDir(), "zaiko.db"))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.MigrateUp(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	var mode string
+	if err := db.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil || mode != "wal" {
+		t.Errorf("journal_mode = %q (%v), want wal", mode, err)
+	}
+
+	if _, err := db.Exec("INSERT INTO stock (product_id, warehouse_id, quantity) VALUES (99, 99, 1)"); err == nil {
+		t.Error("insert of stock for a missing product succeeded")
+	}
+
+	// Rolling the schema back drops tables that others refer to
+	if _, err := db.MigrateDown(1); err != nil {
+		t.Fatalf("migrate down: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+// Package dbtest provides databases for tests.
+package dbtest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"zaiko/internal/database"
+)
+
+var databases atomic.Int64
+
+// New returns a fresh in-memory SQLite database with all migrations applied
+// and the default data seeded. Every call gets its own database, which is
+// closed when the test ends.
+func New(t testing.TB) *database.DB {
+	t.Helper()
+
+	// A named in-memory database with a shared cache is seen by every
+	// connection in the pool, unlike a plain :memory: database
+	name := fmt.Sprintf("file:zaiko_test_%d?mode=memory&cache=shared", databases.Add(1))
+	db, err := database.Connect(name)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.MigrateUp(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := db.SeedDefaultData(); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	return db
+}
+
+// postgresTestLockKey is the advisory lock that keeps test packages, which
+// go test runs in parallel, from using the PostgreSQL database at once.
+const postgresTestLockKey int64 = 0x7a61696b6f74
+
+// Postgres returns the PostgreSQL database at DATABASE_URL with a fresh
+// schema: every migration is reverted and applied again, and the default
+// data is seeded. The test is skipped when DATABASE_URL is not set. All data
+// in the database is lost, so point DATABASE_URL at a database kept for
+// tests. Tests that use it hold the database until they end.
+func Postgres(t testing.TB) *database.DB {
+	t.Helper()
+
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+
+	db, err := database.ConnectPostgres(databaseURL)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	// A session lock, held on a connection of its own until the test ends
+	ctx := context.Background()
+	lock, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("lock connection: %v", err)
+	}
+	if _, err := lock.ExecContext(ctx, "SELECT pg_advisory_lock(?)", postgresTestLockKey); err != nil {
+		lock.Close()
+		t.Fatalf("lock: %v", err)
+	}
+	t.Cleanup(func() {
+		lock.ExecContext(ctx, "SELECT pg_advisory_unlock(?)", postgresTestLockKey)
+		lock.Close()
+	})
+
+	if _, err := db.MigrateDown(math.MaxInt); err != nil {
+		t.Fatalf("reset schema: %v", err)
+	}
+	if _, err := db.MigrateUp(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := db.SeedDefaultData(); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	return db
+}
+
+// Exec runs fixture statements and fails the test on the first error.
+func Exec(t testing.TB, db *database.DB, statements ...string) {
+	t.Helper()
+
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("fixture %q: %v", stmt, err)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Databases created before versioned migrations have no schema_migrations
+// table. Their schema is brought up to the baseline (version 1) in place by
+// the upgrades below, which were run at every startup by older versions.
+
+// transactionTypes lists the values accepted by transactions.type in the
+// baseline schema.
+var transactionTypes = []string{"in", "out", "transfer", "adjustment"}
+
+// transactionsColumns lists the columns of the current transactions schema.
+var transactionsColumns = []string{
+	"id", "product_id", "warehouse_id", "type", "quantity", "note", "user_id",
+	"related_transaction_id", "created_at",
+}
+
+// userRoles lists the values accepted by users.role.
+var userRoles = []string{"admin", "manager", "operator", "viewer"}
+
+func userRoleCheck() string {
+	quoted := make([]string, len(userRoles))
+	for i, r := range userRoles {
+		quoted[i] = "'" + r + "'"
+	}
+	return fmt.Sprintf("CHECK(role IN (%s))", strings.Join(quoted, ", "))
+}
+
+func transactionTypeCheck() string {
+	quoted := make([]string, len(transactionTypes))
+	for i, t := range transactionTypes {
+		quoted[i] = "'" + t + "'"
+	}
+	return fmt.Sprintf("CHECK(type IN (%s))", strings.Join(quoted, ", "))
+}
+
+func transactionsTableSQL(name string) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			product_id INTEGER NOT NULL,
+			warehouse_id INTEGER NOT NULL,
+			type TEXT NOT NULL %s,
+			quantity INTEGER NOT NULL,
+			note TEXT,
+			user_id INTEGER NOT NULL,
+			related_transaction_id INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (product_id) REFERENCES products(id),
+			FOREIGN KEY (warehouse_id) REFERENCES warehouses(id),
+			FOREIGN KEY (user_id) REFERENCES users(id),
+			FOREIGN KEY (related_transaction_id) REFERENCES transactions(id)
+		)`, name, transactionTypeCheck())
+}
+
+// adoptLegacySchema brings a database created before versioned migrations
+// up to the baseline and records the baseline as applied. Tables added since
+// the database was created come from the baseline itself, which only
+// creates what is missing.
+func (db *DB) adoptLegacySchema(baseline Migration) error {
+	if _, err := db.Exec(baseline.Up); err != nil {
+		return err
+	}
+
+	if err := db.upgradeTransactionsTable(); err != nil {
+		return err
+	}
+	if err := db.addColumn("products", "serialized", "BOOLEAN NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := db.upgradeUsersTable(); err != nil {
+		return err
+	}
+	if err := db.addColumn("users", "disabled", "BOOLEAN NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := db.addPasswordChangeFlag(); err != nil {
+		return err
+	}
+	if err := db.addColumn("users", "totp_secret", "TEXT"); err != nil {
+		return err
+	}
+	if err := db.addColumn("users", "totp_enabled", "BOOLEAN NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := db.addColumn("users", "totp_last_step", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(
+		"INSERT INTO schema_migrations (version, name) VALUES (?, ?)",
+		baseline.Version, baseline.Name,
+	); err != nil {
+		return err
+	}
+
+	log.Printf("Existing database adopted at schema version %d", baseline.Version)
+	return nil
+}
+
+// upgradeTransactionsTable rebuilds the transactions table when it was
+// created by an older version (missing columns or a type CHECK constraint
+// that predates the current list of transaction types). SQLite cannot alter
+// a constraint in place, so the rows are copied into a new table.
+func (db *DB) upgradeTransactionsTable() error {
+	var tableSQL string
+	err := db.QueryRow(
+		"SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'transactions'",
+	).Scan(&tableSQL)
+	if err != nil {
+		return err
+	}
+
+	columns, err := db.tableColumns("transactions")
+	if err != nil {
+		return err
+	}
+
+	if strings.Contains(tableSQL, transactionTypeCheck()) && containsAll(columns, transactionsColumns) {
+		return nil
+	}
+	columnList := strings.Join(columns, ", ")
+
+	tx, release, err := db.beginSchemaChange()
+	if err != nil {
+		return err
+	}
+	defer release()
+	defer tx.Rollback()
+
+	statements := []string{
+		transactionsTableSQL("transactions_new"),
+		fmt.Sprintf("INSERT INTO transactions_new (%s) SELECT %s FROM transactions", columnList, columnList),
+		"DROP TABLE transactions",
+		"ALTER TABLE transactions_new RENAME TO transactions",
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Println("Transactions table upgraded")
+	return nil
+}
+
+// upgradeUsersTable adds the role column to a users table created by an
+// older version. Existing users become operators, except the default admin
+// account, which keeps full access.
+func (db *DB) upgradeUsersTable() error {
+	columns, err := db.tableColumns("users")
+	if err != nil {
+		return err
+	}
+	if containsAll(columns, []string{"role"}) {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		fmt.Sprintf("ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'operator' %s", userRoleCheck()),
+		"UPDATE users SET role = 'admin' WHERE username = 'admin'",
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Println("Users table upgraded")
+	return nil
+}
+
+// addPasswordChangeFlag adds the must_change_password column to a users
+// table created by an older version. The default admin account is flagged if
+// it still has the default password.
+func (db *DB) addPasswordChangeFlag() error {
+	columns, err := db.tableColumns("users")
+	if err != nil {
+		return err
+	}
+	if containsAll(columns, []string{"must_change_password"}) {
+		return nil
+	}
+
+	if _, err := db.Exec("ALTER TABLE users ADD COLUMN must_change_password BOOLEAN NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+
+	var hash string
+	err = db.QueryRow("SELECT password_hash FROM users WHERE username = ?", defaultAdminUsername).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(defaultAdminPassword)) == nil {
+		_, err = db.Exec("UPDATE users SET must_change_password = 1 WHERE username = ?", defaultAdminUsername)
+	}
+	return err
+}
+
+// addColumn adds a column to a table created by an older version.
+func (db *DB) addColumn(table, column, definition string) error {
+	columns, err := db.tableColumns(table)
+	if err != nil {
+		return err
+	}
+	if containsAll(columns, []string{column}) {
+		return nil
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+func (db *DB) tableColumns(table string) ([]string, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue *string
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+
+	return columns, rows.Err()
+}
+
+func containsAll(have, want []string) bool {
+	set := make(map[string]bool, len(have))
+	for _, h := range have {
+		set[h] = true
+	}
+	for _, w := range want {
+		if !set[w] {
+			return false
+		}
+	}
+	return true
+}
+
+func (db *DB) tableExists(table string) (bool, error) {
+	var count int
+	err := db.QueryRow(
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table,
+	).Scan(&count)
+	return count > 0, err
+}
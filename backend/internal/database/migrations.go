@@ -1,97 +1,306 @@
 package database
 
 import (
+	"embed"
+	"fmt"
+	"io/fs"
 	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
-func RunMigrations() error {
-	migrations := []string{
-		`CREATE TABLE IF NOT EXISTS users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			username TEXT NOT NULL UNIQUE,
-			password_hash TEXT NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS categories (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL
-		)`,
-		`CREATE TABLE IF NOT EXISTS products (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			code TEXT NOT NULL UNIQUE,
-			name TEXT NOT NULL,
-			description TEXT,
-			category_id INTEGER,
-			unit TEXT NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (category_id) REFERENCES categories(id)
-		)`,
-		`CREATE TABLE IF NOT EXISTS warehouses (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL,
-			location TEXT
-		)`,
-		`CREATE TABLE IF NOT EXISTS stock (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			product_id INTEGER NOT NULL,
-			warehouse_id INTEGER NOT NULL,
-			quantity INTEGER DEFAULT 0,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (product_id) REFERENCES products(id),
-			FOREIGN KEY (warehouse_id) REFERENCES warehouses(id),
-			UNIQUE(product_id, warehouse_id)
-		)`,
-		`CREATE TABLE IF NOT EXISTS transactions (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			product_id INTEGER NOT NULL,
-			warehouse_id INTEGER NOT NULL,
-			type TEXT NOT NULL CHECK(type IN ('in', 'out')),
-			quantity INTEGER NOT NULL,
-			note TEXT,
-			user_id INTEGER NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (product_id) REFERENCES products(id),
-			FOREIGN KEY (warehouse_id) REFERENCES warehouses(id),
-			FOREIGN KEY (user_id) REFERENCES users(id)
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_products_category ON products(category_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_stock_product ON stock(product_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_stock_warehouse ON stock(warehouse_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_transactions_product ON transactions(product_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_transactions_warehouse ON transactions(warehouse_id)`,
-	}
-
-	for _, migration := range migrations {
-		if _, err := DB.Exec(migration); err != nil {
-			return err
+// Schema changes are numbered SQL files in migrations/<dialect>/, named
+// NNNN_description.up.sql with a matching .down.sql that reverts them. Both
+// dialects carry the same versions. Each migration runs in a transaction and
+// is recorded in schema_migrations.
+//
+//go:embed migrations/*/*.sql
+var migrationFiles embed.FS
+
+const (
+	defaultAdminUsername = "admin"
+	defaultAdminPassword = "admin"
+)
+
+// Migration is one numbered schema change.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied. AppliedAt
+// is nil for pending migrations.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// LoadMigrations returns the embedded migrations of a dialect ordered by
+// version.
+func LoadMigrations(dialect Dialect) ([]Migration, error) {
+	files, err := fs.Glob(migrationFiles, path.Join("migrations", string(dialect), "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, file := range files {
+		base := path.Base(file)
+
+		var direction string
+		switch {
+		case strings.HasSuffix(base, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(base, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("migration %s: name must end in .up.sql or .down.sql", base)
+		}
+
+		prefix, name, ok := strings.Cut(strings.TrimSuffix(base, "."+direction+".sql"), "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: name must be NNNN_description", base)
 		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: invalid version %q", base, prefix)
+		}
+
+		content, err := migrationFiles.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %d: names %q and %q do not match", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d: missing up migration", m.Version)
+		}
+		migrations = append(migrations, *m)
 	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
 
+// RunMigrations applies all pending migrations. It runs at startup.
+func (db *DB) RunMigrations() error {
+	applied, err := db.MigrateUp()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range applied {
+		log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+	}
 	log.Println("Database migrations completed successfully")
 	return nil
 }
 
-func SeedDefaultData() error {
+// MigrateUp applies all pending migrations in order and returns them. A
+// SQLite database created before versioned migrations is adopted at the
+// baseline first.
+func (db *DB) MigrateUp() ([]Migration, error) {
+	migrations, err := LoadMigrations(db.Dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(applied) == 0 && len(migrations) > 0 && db.Dialect == SQLite {
+		legacy, err := db.tableExists("users")
+		if err != nil {
+			return nil, err
+		}
+		if legacy {
+			if err := db.adoptLegacySchema(migrations[0]); err != nil {
+				return nil, fmt.Errorf("adopt existing database: %w", err)
+			}
+			applied[migrations[0].Version] = time.Now()
+		}
+	}
+
+	var done []Migration
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := db.applyMigration(m.Up, "INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.Version, m.Name); err != nil {
+			return done, fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+
+	return done, nil
+}
+
+// MigrateDown reverts the last steps applied migrations, newest first, and
+// returns them.
+func (db *DB) MigrateDown(steps int) ([]Migration, error) {
+	migrations, err := LoadMigrations(db.Dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if m.Down == "" {
+			return done, fmt.Errorf("migration %04d_%s cannot be reverted", m.Version, m.Name)
+		}
+		if err := db.applyMigration(m.Down, "DELETE FROM schema_migrations WHERE version = ?", m.Version); err != nil {
+			return done, fmt.Errorf("revert migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+
+	return done, nil
+}
+
+// MigrationStatuses lists every known migration and when it was applied.
+func (db *DB) MigrationStatuses() ([]MigrationStatus, error) {
+	migrations, err := LoadMigrations(db.Dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		statuses[i] = MigrationStatus{Version: m.Version, Name: m.Name}
+		if at, ok := applied[m.Version]; ok {
+			statuses[i].AppliedAt = &at
+		}
+	}
+
+	return statuses, nil
+}
+
+func (db *DB) ensureMigrationsTable() error {
+	timestamp := "DATETIME"
+	if db.Dialect == Postgres {
+		timestamp = "TIMESTAMP"
+	}
+
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at ` + timestamp + ` DEFAULT CURRENT_TIMESTAMP
+	)`)
+	return err
+}
+
+func (db *DB) appliedMigrations() (map[int]time.Time, error) {
+	rows, err := db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// applyMigration runs a migration script and the bookkeeping statement in
+// one transaction.
+func (db *DB) applyMigration(script, record string, args ...interface{}) error {
+	tx, release, err := db.beginSchemaChange()
+	if err != nil {
+		return err
+	}
+	defer release()
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(script); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(record, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (db *DB) SeedDefaultData() error {
 	// Check if admin user exists
 	var count int
-	err := DB.QueryRow("SELECT COUNT(*) FROM users WHERE username = ?", "admin").Scan(&count)
+	err := db.QueryRow("SELECT COUNT(*) FROM users WHERE username = ?", defaultAdminUsername).Scan(&count)
 	if err != nil {
 		return err
 	}
 
 	if count == 0 {
-		// Create default admin user
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte("admin"), bcrypt.DefaultCost)
+		// Create default admin user; the password must be changed at first login
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(defaultAdminPassword), bcrypt.DefaultCost)
 		if err != nil {
 			return err
 		}
 
-		_, err = DB.Exec(
-			"INSERT INTO users (username, password_hash) VALUES (?, ?)",
-			"admin",
+		_, err = db.Exec(
+			"INSERT INTO users (username, password_hash, role, must_change_password) VALUES (?, ?, ?, TRUE)",
+			defaultAdminUsername,
 			string(hashedPassword),
+			"admin",
 		)
 		if err != nil {
 			return err
@@ -100,7 +309,7 @@ func SeedDefaultData() error {
 	}
 
 	// Add default categories if none exist
-	err = DB.QueryRow("SELECT COUNT(*) FROM categories").Scan(&count)
+	err = db.QueryRow("SELECT COUNT(*) FROM categories").Scan(&count)
 	if err != nil {
 		return err
 	}
@@ -108,7 +317,7 @@ func SeedDefaultData() error {
 	if count == 0 {
 		defaultCategories := []string{"電子機器", "事務用品", "消耗品", "その他"}
 		for _, name := range defaultCategories {
-			_, err = DB.Exec("INSERT INTO categories (name) VALUES (?)", name)
+			_, err = db.Exec("INSERT INTO categories (name) VALUES (?)", name)
 			if err != nil {
 				return err
 			}
@@ -0,0 +1,33 @@
+DROP TABLE IF EXISTS login_lockout_events;
+DROP TABLE IF EXISTS login_throttles;
+DROP TABLE IF EXISTS audit_log;
+DROP FUNCTION IF EXISTS audit_log_append_only();
+DROP TABLE IF EXISTS api_key_warehouses;
+DROP TABLE IF EXISTS api_key_permissions;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS two_factor_challenges;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS reservations;
+DROP TABLE IF EXISTS sales_order_shipments;
+DROP TABLE IF EXISTS sales_order_lines;
+DROP TABLE IF EXISTS sales_orders;
+DROP TABLE IF EXISTS customers;
+DROP TABLE IF EXISTS purchase_order_receipts;
+DROP TABLE IF EXISTS purchase_order_lines;
+DROP TABLE IF EXISTS purchase_orders;
+DROP TABLE IF EXISTS suppliers;
+DROP TABLE IF EXISTS reorder_settings;
+DROP TABLE IF EXISTS transaction_serials;
+DROP TABLE IF EXISTS serial_numbers;
+DROP TABLE IF EXISTS transaction_lots;
+DROP TABLE IF EXISTS lots;
+DROP TABLE IF EXISTS count_lines;
+DROP TABLE IF EXISTS count_sessions;
+DROP TABLE IF EXISTS transactions;
+DROP TABLE IF EXISTS stock;
+DROP TABLE IF EXISTS warehouses;
+DROP TABLE IF EXISTS products;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,399 @@
+CREATE TABLE IF NOT EXISTS users (
+	id BIGSERIAL PRIMARY KEY,
+	username TEXT NOT NULL UNIQUE,
+	password_hash TEXT NOT NULL,
+	role TEXT NOT NULL DEFAULT 'operator' CHECK(role IN ('admin', 'manager', 'operator', 'viewer')),
+	disabled BOOLEAN NOT NULL DEFAULT FALSE,
+	must_change_password BOOLEAN NOT NULL DEFAULT FALSE,
+	totp_secret TEXT,
+	totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+	totp_last_step INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS categories (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS products (
+	id BIGSERIAL PRIMARY KEY,
+	code TEXT NOT NULL UNIQUE,
+	name TEXT NOT NULL,
+	description TEXT,
+	category_id BIGINT,
+	unit TEXT NOT NULL,
+	serialized BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (category_id) REFERENCES categories(id) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE TABLE IF NOT EXISTS warehouses (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT NOT NULL,
+	location TEXT
+);
+
+CREATE TABLE IF NOT EXISTS stock (
+	id BIGSERIAL PRIMARY KEY,
+	product_id BIGINT NOT NULL,
+	warehouse_id BIGINT NOT NULL,
+	quantity INTEGER DEFAULT 0,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (product_id) REFERENCES products(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (warehouse_id) REFERENCES warehouses(id) DEFERRABLE INITIALLY DEFERRED,
+	UNIQUE(product_id, warehouse_id)
+);
+
+CREATE TABLE IF NOT EXISTS transactions (
+	id BIGSERIAL PRIMARY KEY,
+	product_id BIGINT NOT NULL,
+	warehouse_id BIGINT NOT NULL,
+	type TEXT NOT NULL CHECK(type IN ('in', 'out', 'transfer', 'adjustment')),
+	quantity INTEGER NOT NULL,
+	note TEXT,
+	user_id BIGINT NOT NULL,
+	related_transaction_id BIGINT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (product_id) REFERENCES products(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (warehouse_id) REFERENCES warehouses(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (user_id) REFERENCES users(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (related_transaction_id) REFERENCES transactions(id) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE TABLE IF NOT EXISTS count_sessions (
+	id BIGSERIAL PRIMARY KEY,
+	warehouse_id BIGINT,
+	category_id BIGINT,
+	status TEXT NOT NULL DEFAULT 'open' CHECK(status IN ('open', 'finalized', 'cancelled')),
+	note TEXT,
+	created_by BIGINT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	closed_at TIMESTAMP,
+	FOREIGN KEY (warehouse_id) REFERENCES warehouses(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (category_id) REFERENCES categories(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (created_by) REFERENCES users(id) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE TABLE IF NOT EXISTS count_lines (
+	id BIGSERIAL PRIMARY KEY,
+	session_id BIGINT NOT NULL,
+	product_id BIGINT NOT NULL,
+	warehouse_id BIGINT NOT NULL,
+	expected_quantity INTEGER NOT NULL,
+	counted_quantity INTEGER,
+	counted_by BIGINT,
+	counted_at TIMESTAMP,
+	adjustment_transaction_id BIGINT,
+	FOREIGN KEY (session_id) REFERENCES count_sessions(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (product_id) REFERENCES products(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (warehouse_id) REFERENCES warehouses(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (counted_by) REFERENCES users(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (adjustment_transaction_id) REFERENCES transactions(id) DEFERRABLE INITIALLY DEFERRED,
+	UNIQUE(session_id, product_id, warehouse_id)
+);
+
+CREATE TABLE IF NOT EXISTS lots (
+	id BIGSERIAL PRIMARY KEY,
+	product_id BIGINT NOT NULL,
+	warehouse_id BIGINT NOT NULL,
+	lot_number TEXT NOT NULL,
+	manufactured_on TEXT,
+	expires_on TEXT,
+	quantity INTEGER NOT NULL DEFAULT 0 CHECK(quantity >= 0),
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (product_id) REFERENCES products(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (warehouse_id) REFERENCES warehouses(id) DEFERRABLE INITIALLY DEFERRED,
+	UNIQUE(product_id, warehouse_id, lot_number)
+);
+
+CREATE TABLE IF NOT EXISTS transaction_lots (
+	transaction_id BIGINT NOT NULL,
+	lot_id BIGINT NOT NULL,
+	quantity INTEGER NOT NULL,
+	PRIMARY KEY (transaction_id, lot_id),
+	FOREIGN KEY (transaction_id) REFERENCES transactions(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (lot_id) REFERENCES lots(id) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE TABLE IF NOT EXISTS serial_numbers (
+	id BIGSERIAL PRIMARY KEY,
+	product_id BIGINT NOT NULL,
+	serial_number TEXT NOT NULL,
+	warehouse_id BIGINT,
+	status TEXT NOT NULL CHECK(status IN ('in_stock', 'shipped')),
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (product_id) REFERENCES products(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (warehouse_id) REFERENCES warehouses(id) DEFERRABLE INITIALLY DEFERRED,
+	UNIQUE(product_id, serial_number)
+);
+
+CREATE TABLE IF NOT EXISTS transaction_serials (
+	transaction_id BIGINT NOT NULL,
+	serial_id BIGINT NOT NULL,
+	PRIMARY KEY (transaction_id, serial_id),
+	FOREIGN KEY (transaction_id) REFERENCES transactions(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (serial_id) REFERENCES serial_numbers(id) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE TABLE IF NOT EXISTS reorder_settings (
+	id BIGSERIAL PRIMARY KEY,
+	product_id BIGINT NOT NULL,
+	warehouse_id BIGINT,
+	min_quantity INTEGER NOT NULL DEFAULT 0,
+	max_quantity INTEGER NOT NULL DEFAULT 0,
+	reorder_point INTEGER NOT NULL,
+	FOREIGN KEY (product_id) REFERENCES products(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (warehouse_id) REFERENCES warehouses(id) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE TABLE IF NOT EXISTS suppliers (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT NOT NULL,
+	contact TEXT,
+	lead_time_days INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS purchase_orders (
+	id BIGSERIAL PRIMARY KEY,
+	supplier_id BIGINT NOT NULL,
+	warehouse_id BIGINT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'draft' CHECK(status IN ('draft', 'ordered', 'partially_received', 'closed')),
+	expected_date TEXT,
+	note TEXT,
+	created_by BIGINT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	ordered_at TIMESTAMP,
+	closed_at TIMESTAMP,
+	FOREIGN KEY (supplier_id) REFERENCES suppliers(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (warehouse_id) REFERENCES warehouses(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (created_by) REFERENCES users(id) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE TABLE IF NOT EXISTS purchase_order_lines (
+	id BIGSERIAL PRIMARY KEY,
+	purchase_order_id BIGINT NOT NULL,
+	product_id BIGINT NOT NULL,
+	quantity INTEGER NOT NULL CHECK(quantity > 0),
+	received_quantity INTEGER NOT NULL DEFAULT 0,
+	unit_cost DOUBLE PRECISION NOT NULL DEFAULT 0,
+	FOREIGN KEY (purchase_order_id) REFERENCES purchase_orders(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (product_id) REFERENCES products(id) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE TABLE IF NOT EXISTS purchase_order_receipts (
+	id BIGSERIAL PRIMARY KEY,
+	line_id BIGINT NOT NULL,
+	transaction_id BIGINT NOT NULL,
+	quantity INTEGER NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (line_id) REFERENCES purchase_order_lines(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (transaction_id) REFERENCES transactions(id) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE TABLE IF NOT EXISTS customers (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT NOT NULL,
+	contact TEXT,
+	address TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS sales_orders (
+	id BIGSERIAL PRIMARY KEY,
+	customer_id BIGINT NOT NULL,
+	warehouse_id BIGINT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'draft' CHECK(status IN ('draft', 'confirmed', 'partially_shipped', 'shipped', 'cancelled')),
+	note TEXT,
+	created_by BIGINT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	confirmed_at TIMESTAMP,
+	closed_at TIMESTAMP,
+	FOREIGN KEY (customer_id) REFERENCES customers(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (warehouse_id) REFERENCES warehouses(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (created_by) REFERENCES users(id) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE TABLE IF NOT EXISTS sales_order_lines (
+	id BIGSERIAL PRIMARY KEY,
+	sales_order_id BIGINT NOT NULL,
+	product_id BIGINT NOT NULL,
+	quantity INTEGER NOT NULL CHECK(quantity > 0),
+	allocated_quantity INTEGER NOT NULL DEFAULT 0,
+	shipped_quantity INTEGER NOT NULL DEFAULT 0,
+	unit_price DOUBLE PRECISION NOT NULL DEFAULT 0,
+	FOREIGN KEY (sales_order_id) REFERENCES sales_orders(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (product_id) REFERENCES products(id) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE TABLE IF NOT EXISTS sales_order_shipments (
+	id BIGSERIAL PRIMARY KEY,
+	line_id BIGINT NOT NULL,
+	transaction_id BIGINT NOT NULL,
+	quantity INTEGER NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (line_id) REFERENCES sales_order_lines(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (transaction_id) REFERENCES transactions(id) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE TABLE IF NOT EXISTS reservations (
+	id BIGSERIAL PRIMARY KEY,
+	product_id BIGINT NOT NULL,
+	warehouse_id BIGINT NOT NULL,
+	quantity INTEGER NOT NULL CHECK(quantity >= 0),
+	owner_ref TEXT NOT NULL,
+	note TEXT,
+	expires_at TIMESTAMP,
+	created_by BIGINT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	released_at TIMESTAMP,
+	FOREIGN KEY (product_id) REFERENCES products(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (warehouse_id) REFERENCES warehouses(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (created_by) REFERENCES users(id) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL,
+	user_agent TEXT,
+	ip_address TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	last_used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	expires_at TIMESTAMP NOT NULL,
+	revoked_at TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+	id BIGSERIAL PRIMARY KEY,
+	session_id BIGINT NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	used_at TIMESTAMP,
+	FOREIGN KEY (session_id) REFERENCES sessions(id) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL,
+	code_hash TEXT NOT NULL,
+	used_at TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE TABLE IF NOT EXISTS two_factor_challenges (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	expires_at TIMESTAMP NOT NULL,
+	completed_at TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL,
+	name TEXT NOT NULL,
+	prefix TEXT NOT NULL,
+	key_hash TEXT NOT NULL UNIQUE,
+	expires_at TIMESTAMP,
+	last_used_at TIMESTAMP,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	revoked_at TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE TABLE IF NOT EXISTS api_key_permissions (
+	api_key_id BIGINT NOT NULL,
+	permission TEXT NOT NULL,
+	PRIMARY KEY (api_key_id, permission),
+	FOREIGN KEY (api_key_id) REFERENCES api_keys(id) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE TABLE IF NOT EXISTS api_key_warehouses (
+	api_key_id BIGINT NOT NULL,
+	warehouse_id BIGINT NOT NULL,
+	PRIMARY KEY (api_key_id, warehouse_id),
+	FOREIGN KEY (api_key_id) REFERENCES api_keys(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (warehouse_id) REFERENCES warehouses(id) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE TABLE IF NOT EXISTS audit_log (
+	id BIGSERIAL PRIMARY KEY,
+	actor_id BIGINT,
+	api_key_id BIGINT,
+	action TEXT NOT NULL,
+	entity_type TEXT NOT NULL,
+	entity_id BIGINT NOT NULL,
+	before_json TEXT,
+	after_json TEXT,
+	ip_address TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The audit log is append-only
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_no_update ON audit_log;
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log
+FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+DROP TRIGGER IF EXISTS audit_log_no_delete ON audit_log;
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log
+FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+CREATE TABLE IF NOT EXISTS login_throttles (
+	scope TEXT NOT NULL CHECK (scope IN ('username', 'ip')),
+	key TEXT NOT NULL,
+	failures INTEGER NOT NULL DEFAULT 0,
+	last_failed_at TIMESTAMP NOT NULL,
+	locked_until TIMESTAMP,
+	PRIMARY KEY (scope, key)
+);
+
+CREATE TABLE IF NOT EXISTS login_lockout_events (
+	id BIGSERIAL PRIMARY KEY,
+	scope TEXT NOT NULL CHECK (scope IN ('username', 'ip')),
+	key TEXT NOT NULL,
+	event TEXT NOT NULL CHECK (event IN ('locked', 'unlocked')),
+	failures INTEGER NOT NULL DEFAULT 0,
+	ip_address TEXT,
+	locked_until TIMESTAMP,
+	user_id BIGINT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE INDEX IF NOT EXISTS idx_products_category ON products(category_id);
+CREATE INDEX IF NOT EXISTS idx_stock_product ON stock(product_id);
+CREATE INDEX IF NOT EXISTS idx_stock_warehouse ON stock(warehouse_id);
+CREATE INDEX IF NOT EXISTS idx_transactions_product ON transactions(product_id);
+CREATE INDEX IF NOT EXISTS idx_transactions_warehouse ON transactions(warehouse_id);
+CREATE INDEX IF NOT EXISTS idx_count_sessions_status ON count_sessions(status);
+CREATE INDEX IF NOT EXISTS idx_count_lines_session ON count_lines(session_id);
+CREATE INDEX IF NOT EXISTS idx_lots_expires ON lots(expires_on);
+CREATE INDEX IF NOT EXISTS idx_serial_numbers_serial ON serial_numbers(serial_number);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reorder_settings_default ON reorder_settings(product_id) WHERE warehouse_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reorder_settings_warehouse ON reorder_settings(product_id, warehouse_id) WHERE warehouse_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_purchase_orders_status ON purchase_orders(status);
+CREATE INDEX IF NOT EXISTS idx_purchase_order_lines_order ON purchase_order_lines(purchase_order_id);
+CREATE INDEX IF NOT EXISTS idx_sales_orders_status ON sales_orders(status);
+CREATE INDEX IF NOT EXISTS idx_sales_order_lines_order ON sales_order_lines(sales_order_id);
+CREATE INDEX IF NOT EXISTS idx_sales_order_lines_product ON sales_order_lines(product_id);
+CREATE INDEX IF NOT EXISTS idx_reservations_stock ON reservations(product_id, warehouse_id);
+CREATE INDEX IF NOT EXISTS idx_reservations_owner ON reservations(owner_ref);
+CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_login_lockout_events_key ON login_lockout_events(scope, key);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user ON recovery_codes(user_id);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user ON api_keys(user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log(entity_type, entity_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created_at);
@@ -0,0 +1,24 @@
+ALTER TABLE transactions ADD COLUMN unit_cost DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD COLUMN total_cost DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE stock ADD COLUMN value DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS cost_layers (
+	id BIGSERIAL PRIMARY KEY,
+	product_id BIGINT NOT NULL,
+	warehouse_id BIGINT NOT NULL,
+	transaction_id BIGINT,
+	unit_cost DOUBLE PRECISION NOT NULL,
+	quantity INTEGER NOT NULL CHECK(quantity > 0),
+	remaining INTEGER NOT NULL CHECK(remaining >= 0),
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (product_id) REFERENCES products(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (warehouse_id) REFERENCES warehouses(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (transaction_id) REFERENCES transactions(id) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE INDEX IF NOT EXISTS idx_cost_layers_open ON cost_layers(product_id, warehouse_id, remaining);
+CREATE INDEX IF NOT EXISTS idx_transactions_created ON transactions(created_at);
+
+-- Stock on hand before costing has no known cost
+INSERT INTO cost_layers (product_id, warehouse_id, unit_cost, quantity, remaining)
+SELECT product_id, warehouse_id, 0, quantity, quantity FROM stock WHERE quantity > 0;
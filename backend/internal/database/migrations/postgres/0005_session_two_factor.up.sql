@@ -0,0 +1,2 @@
+-- Whether the login was completed with a two-factor code
+ALTER TABLE sessions ADD COLUMN two_factor BOOLEAN NOT NULL DEFAULT FALSE;
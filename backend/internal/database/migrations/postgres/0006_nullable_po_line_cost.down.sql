@@ -0,0 +1,4 @@
+UPDATE purchase_order_lines SET unit_cost = 0 WHERE unit_cost IS NULL;
+
+ALTER TABLE purchase_order_lines ALTER COLUMN unit_cost SET DEFAULT 0;
+ALTER TABLE purchase_order_lines ALTER COLUMN unit_cost SET NOT NULL;
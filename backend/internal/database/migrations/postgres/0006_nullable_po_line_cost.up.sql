@@ -0,0 +1,7 @@
+-- A line without a unit cost is received at the default unit cost, so an
+-- explicit zero has to be told apart from no cost at all
+ALTER TABLE purchase_order_lines ALTER COLUMN unit_cost DROP NOT NULL;
+ALTER TABLE purchase_order_lines ALTER COLUMN unit_cost DROP DEFAULT;
+
+-- Zero used to mean no cost
+UPDATE purchase_order_lines SET unit_cost = NULL WHERE unit_cost = 0;
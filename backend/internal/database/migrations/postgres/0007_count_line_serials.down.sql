@@ -0,0 +1 @@
+DROP TABLE IF EXISTS count_line_serials;
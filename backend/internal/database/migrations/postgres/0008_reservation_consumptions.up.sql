@@ -0,0 +1,10 @@
+-- Reserved quantity that a stock movement used up, so that reversing the
+-- movement can hold it for its owner again
+CREATE TABLE IF NOT EXISTS reservation_consumptions (
+	transaction_id BIGINT NOT NULL,
+	reservation_id BIGINT NOT NULL,
+	quantity INTEGER NOT NULL CHECK(quantity > 0),
+	PRIMARY KEY (transaction_id, reservation_id),
+	FOREIGN KEY (transaction_id) REFERENCES transactions(id) DEFERRABLE INITIALLY DEFERRED,
+	FOREIGN KEY (reservation_id) REFERENCES reservations(id) DEFERRABLE INITIALLY DEFERRED
+);
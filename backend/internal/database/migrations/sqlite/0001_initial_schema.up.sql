@@ -0,0 +1,395 @@
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username TEXT NOT NULL UNIQUE,
+	password_hash TEXT NOT NULL,
+	role TEXT NOT NULL DEFAULT 'operator' CHECK(role IN ('admin', 'manager', 'operator', 'viewer')),
+	disabled BOOLEAN NOT NULL DEFAULT 0,
+	must_change_password BOOLEAN NOT NULL DEFAULT 0,
+	totp_secret TEXT,
+	totp_enabled BOOLEAN NOT NULL DEFAULT 0,
+	totp_last_step INTEGER NOT NULL DEFAULT 0,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS categories (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS products (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	code TEXT NOT NULL UNIQUE,
+	name TEXT NOT NULL,
+	description TEXT,
+	category_id INTEGER,
+	unit TEXT NOT NULL,
+	serialized BOOLEAN NOT NULL DEFAULT 0,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (category_id) REFERENCES categories(id)
+);
+
+CREATE TABLE IF NOT EXISTS warehouses (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	location TEXT
+);
+
+CREATE TABLE IF NOT EXISTS stock (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	product_id INTEGER NOT NULL,
+	warehouse_id INTEGER NOT NULL,
+	quantity INTEGER DEFAULT 0,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (product_id) REFERENCES products(id),
+	FOREIGN KEY (warehouse_id) REFERENCES warehouses(id),
+	UNIQUE(product_id, warehouse_id)
+);
+
+CREATE TABLE IF NOT EXISTS transactions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	product_id INTEGER NOT NULL,
+	warehouse_id INTEGER NOT NULL,
+	type TEXT NOT NULL CHECK(type IN ('in', 'out', 'transfer', 'adjustment')),
+	quantity INTEGER NOT NULL,
+	note TEXT,
+	user_id INTEGER NOT NULL,
+	related_transaction_id INTEGER,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (product_id) REFERENCES products(id),
+	FOREIGN KEY (warehouse_id) REFERENCES warehouses(id),
+	FOREIGN KEY (user_id) REFERENCES users(id),
+	FOREIGN KEY (related_transaction_id) REFERENCES transactions(id)
+);
+
+CREATE TABLE IF NOT EXISTS count_sessions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	warehouse_id INTEGER,
+	category_id INTEGER,
+	status TEXT NOT NULL DEFAULT 'open' CHECK(status IN ('open', 'finalized', 'cancelled')),
+	note TEXT,
+	created_by INTEGER NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	closed_at DATETIME,
+	FOREIGN KEY (warehouse_id) REFERENCES warehouses(id),
+	FOREIGN KEY (category_id) REFERENCES categories(id),
+	FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS count_lines (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	session_id INTEGER NOT NULL,
+	product_id INTEGER NOT NULL,
+	warehouse_id INTEGER NOT NULL,
+	expected_quantity INTEGER NOT NULL,
+	counted_quantity INTEGER,
+	counted_by INTEGER,
+	counted_at DATETIME,
+	adjustment_transaction_id INTEGER,
+	FOREIGN KEY (session_id) REFERENCES count_sessions(id),
+	FOREIGN KEY (product_id) REFERENCES products(id),
+	FOREIGN KEY (warehouse_id) REFERENCES warehouses(id),
+	FOREIGN KEY (counted_by) REFERENCES users(id),
+	FOREIGN KEY (adjustment_transaction_id) REFERENCES transactions(id),
+	UNIQUE(session_id, product_id, warehouse_id)
+);
+
+CREATE TABLE IF NOT EXISTS lots (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	product_id INTEGER NOT NULL,
+	warehouse_id INTEGER NOT NULL,
+	lot_number TEXT NOT NULL,
+	manufactured_on TEXT,
+	expires_on TEXT,
+	quantity INTEGER NOT NULL DEFAULT 0 CHECK(quantity >= 0),
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (product_id) REFERENCES products(id),
+	FOREIGN KEY (warehouse_id) REFERENCES warehouses(id),
+	UNIQUE(product_id, warehouse_id, lot_number)
+);
+
+CREATE TABLE IF NOT EXISTS transaction_lots (
+	transaction_id INTEGER NOT NULL,
+	lot_id INTEGER NOT NULL,
+	quantity INTEGER NOT NULL,
+	PRIMARY KEY (transaction_id, lot_id),
+	FOREIGN KEY (transaction_id) REFERENCES transactions(id),
+	FOREIGN KEY (lot_id) REFERENCES lots(id)
+);
+
+CREATE TABLE IF NOT EXISTS serial_numbers (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	product_id INTEGER NOT NULL,
+	serial_number TEXT NOT NULL,
+	warehouse_id INTEGER,
+	status TEXT NOT NULL CHECK(status IN ('in_stock', 'shipped')),
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (product_id) REFERENCES products(id),
+	FOREIGN KEY (warehouse_id) REFERENCES warehouses(id),
+	UNIQUE(product_id, serial_number)
+);
+
+CREATE TABLE IF NOT EXISTS transaction_serials (
+	transaction_id INTEGER NOT NULL,
+	serial_id INTEGER NOT NULL,
+	PRIMARY KEY (transaction_id, serial_id),
+	FOREIGN KEY (transaction_id) REFERENCES transactions(id),
+	FOREIGN KEY (serial_id) REFERENCES serial_numbers(id)
+);
+
+CREATE TABLE IF NOT EXISTS reorder_settings (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	product_id INTEGER NOT NULL,
+	warehouse_id INTEGER,
+	min_quantity INTEGER NOT NULL DEFAULT 0,
+	max_quantity INTEGER NOT NULL DEFAULT 0,
+	reorder_point INTEGER NOT NULL,
+	FOREIGN KEY (product_id) REFERENCES products(id),
+	FOREIGN KEY (warehouse_id) REFERENCES warehouses(id)
+);
+
+CREATE TABLE IF NOT EXISTS suppliers (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	contact TEXT,
+	lead_time_days INTEGER NOT NULL DEFAULT 0,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS purchase_orders (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	supplier_id INTEGER NOT NULL,
+	warehouse_id INTEGER NOT NULL,
+	status TEXT NOT NULL DEFAULT 'draft' CHECK(status IN ('draft', 'ordered', 'partially_received', 'closed')),
+	expected_date TEXT,
+	note TEXT,
+	created_by INTEGER NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	ordered_at DATETIME,
+	closed_at DATETIME,
+	FOREIGN KEY (supplier_id) REFERENCES suppliers(id),
+	FOREIGN KEY (warehouse_id) REFERENCES warehouses(id),
+	FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS purchase_order_lines (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	purchase_order_id INTEGER NOT NULL,
+	product_id INTEGER NOT NULL,
+	quantity INTEGER NOT NULL CHECK(quantity > 0),
+	received_quantity INTEGER NOT NULL DEFAULT 0,
+	unit_cost REAL NOT NULL DEFAULT 0,
+	FOREIGN KEY (purchase_order_id) REFERENCES purchase_orders(id),
+	FOREIGN KEY (product_id) REFERENCES products(id)
+);
+
+CREATE TABLE IF NOT EXISTS purchase_order_receipts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	line_id INTEGER NOT NULL,
+	transaction_id INTEGER NOT NULL,
+	quantity INTEGER NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (line_id) REFERENCES purchase_order_lines(id),
+	FOREIGN KEY (transaction_id) REFERENCES transactions(id)
+);
+
+CREATE TABLE IF NOT EXISTS customers (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	contact TEXT,
+	address TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS sales_orders (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	customer_id INTEGER NOT NULL,
+	warehouse_id INTEGER NOT NULL,
+	status TEXT NOT NULL DEFAULT 'draft' CHECK(status IN ('draft', 'confirmed', 'partially_shipped', 'shipped', 'cancelled')),
+	note TEXT,
+	created_by INTEGER NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	confirmed_at DATETIME,
+	closed_at DATETIME,
+	FOREIGN KEY (customer_id) REFERENCES customers(id),
+	FOREIGN KEY (warehouse_id) REFERENCES warehouses(id),
+	FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS sales_order_lines (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	sales_order_id INTEGER NOT NULL,
+	product_id INTEGER NOT NULL,
+	quantity INTEGER NOT NULL CHECK(quantity > 0),
+	allocated_quantity INTEGER NOT NULL DEFAULT 0,
+	shipped_quantity INTEGER NOT NULL DEFAULT 0,
+	unit_price REAL NOT NULL DEFAULT 0,
+	FOREIGN KEY (sales_order_id) REFERENCES sales_orders(id),
+	FOREIGN KEY (product_id) REFERENCES products(id)
+);
+
+CREATE TABLE IF NOT EXISTS sales_order_shipments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	line_id INTEGER NOT NULL,
+	transaction_id INTEGER NOT NULL,
+	quantity INTEGER NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (line_id) REFERENCES sales_order_lines(id),
+	FOREIGN KEY (transaction_id) REFERENCES transactions(id)
+);
+
+CREATE TABLE IF NOT EXISTS reservations (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	product_id INTEGER NOT NULL,
+	warehouse_id INTEGER NOT NULL,
+	quantity INTEGER NOT NULL CHECK(quantity >= 0),
+	owner_ref TEXT NOT NULL,
+	note TEXT,
+	expires_at DATETIME,
+	created_by INTEGER NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	released_at DATETIME,
+	FOREIGN KEY (product_id) REFERENCES products(id),
+	FOREIGN KEY (warehouse_id) REFERENCES warehouses(id),
+	FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	user_agent TEXT,
+	ip_address TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	last_used_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	expires_at DATETIME NOT NULL,
+	revoked_at DATETIME,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	session_id INTEGER NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	used_at DATETIME,
+	FOREIGN KEY (session_id) REFERENCES sessions(id)
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	code_hash TEXT NOT NULL,
+	used_at DATETIME,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS two_factor_challenges (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	expires_at DATETIME NOT NULL,
+	completed_at DATETIME,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	prefix TEXT NOT NULL,
+	key_hash TEXT NOT NULL UNIQUE,
+	expires_at DATETIME,
+	last_used_at DATETIME,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	revoked_at DATETIME,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS api_key_permissions (
+	api_key_id INTEGER NOT NULL,
+	permission TEXT NOT NULL,
+	PRIMARY KEY (api_key_id, permission),
+	FOREIGN KEY (api_key_id) REFERENCES api_keys(id)
+);
+
+CREATE TABLE IF NOT EXISTS api_key_warehouses (
+	api_key_id INTEGER NOT NULL,
+	warehouse_id INTEGER NOT NULL,
+	PRIMARY KEY (api_key_id, warehouse_id),
+	FOREIGN KEY (api_key_id) REFERENCES api_keys(id),
+	FOREIGN KEY (warehouse_id) REFERENCES warehouses(id)
+);
+
+CREATE TABLE IF NOT EXISTS audit_log (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	actor_id INTEGER,
+	api_key_id INTEGER,
+	action TEXT NOT NULL,
+	entity_type TEXT NOT NULL,
+	entity_id INTEGER NOT NULL,
+	before_json TEXT,
+	after_json TEXT,
+	ip_address TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The audit log is append-only
+CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+	SELECT RAISE(ABORT, 'audit log is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+	SELECT RAISE(ABORT, 'audit log is append-only');
+END;
+
+CREATE TABLE IF NOT EXISTS login_throttles (
+	scope TEXT NOT NULL CHECK (scope IN ('username', 'ip')),
+	key TEXT NOT NULL,
+	failures INTEGER NOT NULL DEFAULT 0,
+	last_failed_at DATETIME NOT NULL,
+	locked_until DATETIME,
+	PRIMARY KEY (scope, key)
+);
+
+CREATE TABLE IF NOT EXISTS login_lockout_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	scope TEXT NOT NULL CHECK (scope IN ('username', 'ip')),
+	key TEXT NOT NULL,
+	event TEXT NOT NULL CHECK (event IN ('locked', 'unlocked')),
+	failures INTEGER NOT NULL DEFAULT 0,
+	ip_address TEXT,
+	locked_until DATETIME,
+	user_id INTEGER,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_products_category ON products(category_id);
+CREATE INDEX IF NOT EXISTS idx_stock_product ON stock(product_id);
+CREATE INDEX IF NOT EXISTS idx_stock_warehouse ON stock(warehouse_id);
+CREATE INDEX IF NOT EXISTS idx_transactions_product ON transactions(product_id);
+CREATE INDEX IF NOT EXISTS idx_transactions_warehouse ON transactions(warehouse_id);
+CREATE INDEX IF NOT EXISTS idx_count_sessions_status ON count_sessions(status);
+CREATE INDEX IF NOT EXISTS idx_count_lines_session ON count_lines(session_id);
+CREATE INDEX IF NOT EXISTS idx_lots_expires ON lots(expires_on);
+CREATE INDEX IF NOT EXISTS idx_serial_numbers_serial ON serial_numbers(serial_number);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reorder_settings_default ON reorder_settings(product_id) WHERE warehouse_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reorder_settings_warehouse ON reorder_settings(product_id, warehouse_id) WHERE warehouse_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_purchase_orders_status ON purchase_orders(status);
+CREATE INDEX IF NOT EXISTS idx_purchase_order_lines_order ON purchase_order_lines(purchase_order_id);
+CREATE INDEX IF NOT EXISTS idx_sales_orders_status ON sales_orders(status);
+CREATE INDEX IF NOT EXISTS idx_sales_order_lines_order ON sales_order_lines(sales_order_id);
+CREATE INDEX IF NOT EXISTS idx_sales_order_lines_product ON sales_order_lines(product_id);
+CREATE INDEX IF NOT EXISTS idx_reservations_stock ON reservations(product_id, warehouse_id);
+CREATE INDEX IF NOT EXISTS idx_reservations_owner ON reservations(owner_ref);
+CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_login_lockout_events_key ON login_lockout_events(scope, key);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user ON recovery_codes(user_id);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user ON api_keys(user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log(entity_type, entity_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created_at);
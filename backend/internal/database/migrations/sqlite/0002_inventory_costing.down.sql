@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_transactions_created;
+DROP TABLE IF EXISTS cost_layers;
+
+ALTER TABLE stock DROP COLUMN value;
+ALTER TABLE transactions DROP COLUMN total_cost;
+ALTER TABLE transactions DROP COLUMN unit_cost;
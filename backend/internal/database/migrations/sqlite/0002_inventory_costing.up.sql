@@ -0,0 +1,24 @@
+ALTER TABLE transactions ADD COLUMN unit_cost REAL NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD COLUMN total_cost REAL NOT NULL DEFAULT 0;
+ALTER TABLE stock ADD COLUMN value REAL NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS cost_layers (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	product_id INTEGER NOT NULL,
+	warehouse_id INTEGER NOT NULL,
+	transaction_id INTEGER,
+	unit_cost REAL NOT NULL,
+	quantity INTEGER NOT NULL CHECK(quantity > 0),
+	remaining INTEGER NOT NULL CHECK(remaining >= 0),
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (product_id) REFERENCES products(id),
+	FOREIGN KEY (warehouse_id) REFERENCES warehouses(id),
+	FOREIGN KEY (transaction_id) REFERENCES transactions(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_cost_layers_open ON cost_layers(product_id, warehouse_id, remaining);
+CREATE INDEX IF NOT EXISTS idx_transactions_created ON transactions(created_at);
+
+-- Stock on hand before costing has no known cost
+INSERT INTO cost_layers (product_id, warehouse_id, unit_cost, quantity, remaining)
+SELECT product_id, warehouse_id, 0, quantity, quantity FROM stock WHERE quantity > 0;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_transactions_reason;
+
+ALTER TABLE transactions DROP COLUMN reason_id;
+DROP TABLE IF EXISTS adjustment_reasons;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS adjustment_reasons (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	code TEXT NOT NULL UNIQUE,
+	name TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE transactions ADD COLUMN reason_id INTEGER REFERENCES adjustment_reasons(id);
+
+CREATE INDEX IF NOT EXISTS idx_transactions_reason ON transactions(reason_id);
+
+INSERT INTO adjustment_reasons (code, name) VALUES
+	('DAMAGE', '破損'),
+	('LOSS', '紛失'),
+	('FOUND', '発見'),
+	('SAMPLE', '見本');
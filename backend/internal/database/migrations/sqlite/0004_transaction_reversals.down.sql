@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_transactions_reversal;
+
+ALTER TABLE transactions DROP COLUMN reversal_of;
@@ -0,0 +1,4 @@
+ALTER TABLE transactions ADD COLUMN reversal_of INTEGER REFERENCES transactions(id);
+
+-- A transaction can be reversed only once
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_reversal ON transactions(reversal_of);
@@ -0,0 +1,18 @@
+CREATE TABLE purchase_order_lines_old (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	purchase_order_id INTEGER NOT NULL,
+	product_id INTEGER NOT NULL,
+	quantity INTEGER NOT NULL CHECK(quantity > 0),
+	received_quantity INTEGER NOT NULL DEFAULT 0,
+	unit_cost REAL NOT NULL DEFAULT 0,
+	FOREIGN KEY (purchase_order_id) REFERENCES purchase_orders(id),
+	FOREIGN KEY (product_id) REFERENCES products(id)
+);
+
+INSERT INTO purchase_order_lines_old (id, purchase_order_id, product_id, quantity, received_quantity, unit_cost)
+SELECT id, purchase_order_id, product_id, quantity, received_quantity, COALESCE(unit_cost, 0) FROM purchase_order_lines;
+
+DROP TABLE purchase_order_lines;
+ALTER TABLE purchase_order_lines_old RENAME TO purchase_order_lines;
+
+CREATE INDEX IF NOT EXISTS idx_purchase_order_lines_order ON purchase_order_lines(purchase_order_id);
@@ -0,0 +1,21 @@
+-- A line without a unit cost is received at the default unit cost, so an
+-- explicit zero has to be told apart from no cost at all
+CREATE TABLE purchase_order_lines_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	purchase_order_id INTEGER NOT NULL,
+	product_id INTEGER NOT NULL,
+	quantity INTEGER NOT NULL CHECK(quantity > 0),
+	received_quantity INTEGER NOT NULL DEFAULT 0,
+	unit_cost REAL,
+	FOREIGN KEY (purchase_order_id) REFERENCES purchase_orders(id),
+	FOREIGN KEY (product_id) REFERENCES products(id)
+);
+
+-- Zero used to mean no cost
+INSERT INTO purchase_order_lines_new (id, purchase_order_id, product_id, quantity, received_quantity, unit_cost)
+SELECT id, purchase_order_id, product_id, quantity, received_quantity, NULLIF(unit_cost, 0) FROM purchase_order_lines;
+
+DROP TABLE purchase_order_lines;
+ALTER TABLE purchase_order_lines_new RENAME TO purchase_order_lines;
+
+CREATE INDEX IF NOT EXISTS idx_purchase_order_lines_order ON purchase_order_lines(purchase_order_id);
@@ -0,0 +1,8 @@
+-- Serial numbers of a serialized product that a count found missing, or
+-- found in addition to the stock on hand
+CREATE TABLE IF NOT EXISTS count_line_serials (
+	count_line_id INTEGER NOT NULL,
+	serial_number TEXT NOT NULL,
+	PRIMARY KEY (count_line_id, serial_number),
+	FOREIGN KEY (count_line_id) REFERENCES count_lines(id)
+);
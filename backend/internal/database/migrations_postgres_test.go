@@ -0,0 +1,53 @@
+package database_test
+
+import (
+	"testing"
+
+	"zaiko/internal/database"
+	"zaiko/internal/database/dbtest"
+)
+
+func TestPostgresMigrations(t *testing.T) {
+	db := dbtest.Postgres(t)
+
+	migrations, err := database.LoadMigrations(database.Postgres)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+
+	statuses, err := db.MigrationStatuses()
+	if err != nil {
+		t.Fatalf("statuses: %v", err)
+	}
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			t.Errorf("migration %04d_%s is not applied", s.Version, s.Name)
+		}
+	}
+
+	// Deferred foreign keys are still checked when the statement commits
+	if _, err := db.Exec("INSERT INTO stock (product_id, warehouse_id, quantity) VALUES (99, 99, 1)"); err == nil {
+		t.Error("insert of stock for a missing product succeeded")
+	}
+
+	reverted, err := db.MigrateDown(len(migrations))
+	if err != nil {
+		t.Fatalf("migrate down: %v", err)
+	}
+	if len(reverted) != len(migrations) {
+		t.Errorf("reverted %d migrations, want %d", len(reverted), len(migrations))
+	}
+
+	var stock *string
+	if err := db.QueryRow("SELECT to_regclass('stock')::text").Scan(&stock); err != nil || stock != nil {
+		t.Errorf("stock table after migrating down = %v (%v), want none", stock, err)
+	}
+
+	applied, err := db.MigrateUp()
+	if err != nil {
+		t.Fatalf("migrate up again: %v", err)
+	}
+	if len(applied) != len(migrations) {
+		t.Errorf("applied %d migrations, want %d", len(applied), len(migrations))
+	}
+}
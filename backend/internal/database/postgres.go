@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// stockLockKey is the advisory lock taken by InStockTx on PostgreSQL.
+const stockLockKey int64 = 0x7a61696b6f
+
+// openPostgres opens a PostgreSQL pool whose connections accept the ?
+// placeholders used throughout the repositories and run in UTC, the time
+// zone timestamps are stored in.
+func openPostgres(databaseURL string) (*sql.DB, error) {
+	connector, err := pq.NewConnector(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(rebindConnector{connector}), nil
+}
+
+// postgresConn is the set of driver interfaces implemented by lib/pq
+// connections.
+type postgresConn interface {
+	driver.Conn
+	driver.ConnPrepareContext
+	driver.ConnBeginTx
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.Pinger
+	driver.SessionResetter
+	driver.Validator
+}
+
+type rebindConnector struct {
+	driver.Connector
+}
+
+func (c rebindConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pgConn, ok := conn.(postgresConn)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("unsupported PostgreSQL connection type %T", conn)
+	}
+
+	if _, err := pgConn.ExecContext(ctx, "SET TIME ZONE 'UTC'", nil); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return rebindConn{pgConn}, nil
+}
+
+// rebindConn rewrites ? placeholders to PostgreSQL's $1, $2, ... before a
+// query reaches the driver.
+type rebindConn struct {
+	postgresConn
+}
+
+func (c rebindConn) Prepare(query string) (driver.Stmt, error) {
+	return c.postgresConn.Prepare(rebind(query))
+}
+
+func (c rebindConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.postgresConn.PrepareContext(ctx, rebind(query))
+}
+
+func (c rebindConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.postgresConn.ExecContext(ctx, rebind(query), args)
+}
+
+func (c rebindConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.postgresConn.QueryContext(ctx, rebind(query), args)
+}
+
+// rebind numbers the ? placeholders of a query. Question marks inside
+// string literals, quoted identifiers, comments and dollar-quoted bodies are
+// left alone.
+func rebind(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case ch == '\'' || ch == '"':
+			end := strings.IndexByte(query[i+1:], ch)
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+2])
+			i += end + 1
+
+		case ch == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+1])
+			i += end
+
+		case ch == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+4])
+			i += end + 3
+
+		case ch == '$' && strings.HasPrefix(query[i:], "$$"):
+			end := strings.Index(query[i+2:], "$$")
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+4])
+			i += end + 3
+
+		case ch == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+
+		default:
+			b.WriteByte(ch)
+		}
+	}
+
+	return b.String()
+}
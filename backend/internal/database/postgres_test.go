@@ -0,0 +1,27 @@
+package database
+
+import "testing"
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT 1", "SELECT 1"},
+		{"SELECT * FROM users WHERE id = ?", "SELECT * FROM users WHERE id = $1"},
+		{"INSERT INTO t (a, b, c) VALUES (?, ?, ?)", "INSERT INTO t (a, b, c) VALUES ($1, $2, $3)"},
+		{"SELECT '?' || name FROM t WHERE a = ?", "SELECT '?' || name FROM t WHERE a = $1"},
+		{"SELECT 'it''s' FROM t WHERE a = ? AND b = ?", "SELECT 'it''s' FROM t WHERE a = $1 AND b = $2"},
+		{`SELECT "odd?" FROM t WHERE a = ?`, `SELECT "odd?" FROM t WHERE a = $1`},
+		{"SELECT a -- why?\nFROM t WHERE a = ?", "SELECT a -- why?\nFROM t WHERE a = $1"},
+		{"SELECT /* ? */ a FROM t WHERE a = ?", "SELECT /* ? */ a FROM t WHERE a = $1"},
+		{"SELECT $$ ? $$, ?", "SELECT $$ ? $$, $1"},
+		{"SELECT a FROM t WHERE b = 'unterminated ?", "SELECT a FROM t WHERE b = 'unterminated ?"},
+	}
+
+	for _, tt := range tests {
+		if got := rebind(tt.query); got != tt.want {
+			t.Errorf("rebind(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+	"zaiko/internal/repository"
+)
+
+type AdjustmentReasonHandler struct {
+	db         *database.DB
+	reasonRepo *repository.AdjustmentReasonRepository
+	auditRepo  *repository.AuditRepository
+}
+
+func NewAdjustmentReasonHandler(
+	db *database.DB,
+	reasonRepo *repository.AdjustmentReasonRepository,
+	auditRepo *repository.AuditRepository,
+) *AdjustmentReasonHandler {
+	return &AdjustmentReasonHandler{
+		db:         db,
+		reasonRepo: reasonRepo,
+		auditRepo:  auditRepo,
+	}
+}
+
+func (h *AdjustmentReasonHandler) GetAll(c *gin.Context) {
+	reasons, err := h.reasonRepo.FindAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if reasons == nil {
+		reasons = []models.AdjustmentReason{}
+	}
+
+	c.JSON(http.StatusOK, reasons)
+}
+
+func (h *AdjustmentReasonHandler) Create(c *gin.Context) {
+	var req models.CreateAdjustmentReasonRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var reason *models.AdjustmentReason
+	err := h.db.InTx(func(tx *sql.Tx) error {
+		var err error
+		reason, err = h.reasonRepo.WithTx(tx).Create(req)
+		if err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditCreate, models.AuditEntityAdjustmentReason, reason.ID, nil, reason)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, reason)
+}
+
+func (h *AdjustmentReasonHandler) Update(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req models.UpdateAdjustmentReasonRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before, err := h.reasonRepo.FindByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Adjustment reason not found"})
+		return
+	}
+
+	var reason *models.AdjustmentReason
+	err = h.db.InTx(func(tx *sql.Tx) error {
+		var err error
+		reason, err = h.reasonRepo.WithTx(tx).Update(id, req)
+		if err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditUpdate, models.AuditEntityAdjustmentReason, id, before, reason)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, reason)
+}
+
+func (h *AdjustmentReasonHandler) Delete(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	before, err := h.reasonRepo.FindByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Adjustment reason not found"})
+		return
+	}
+
+	err = h.db.InTx(func(tx *sql.Tx) error {
+		if err := h.reasonRepo.WithTx(tx).Delete(id); err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditDelete, models.AuditEntityAdjustmentReason, id, before, nil)
+	})
+	if err != nil {
+		respondDeleteError(c, "Adjustment reason", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Adjustment reason deleted"})
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"zaiko/internal/database"
+	"zaiko/internal/middleware"
+	"zaiko/internal/models"
+	"zaiko/internal/repository"
+	"zaiko/internal/service"
+)
+
+type APIKeyHandler struct {
+	db            *database.DB
+	userRepo      *repository.UserRepository
+	auditRepo     *repository.AuditRepository
+	apiKeyService *service.APIKeyService
+}
+
+func NewAPIKeyHandler(
+	db *database.DB,
+	userRepo *repository.UserRepository,
+	auditRepo *repository.AuditRepository,
+	apiKeyService *service.APIKeyService,
+) *APIKeyHandler {
+	return &APIKeyHandler{
+		db:            db,
+		userRepo:      userRepo,
+		auditRepo:     auditRepo,
+		apiKeyService: apiKeyService,
+	}
+}
+
+// GetAll lists the current user's keys. Admins can list every key with
+// all=true.
+func (h *APIKeyHandler) GetAll(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if c.Query("all") == "true" && middleware.GetRole(c).Can(models.PermissionUserManage) {
+		userID = 0
+	}
+
+	keys, err := h.apiKeyService.FindAll(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if keys == nil {
+		keys = []models.APIKey{}
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// Create issues a key. Keys are not asked for a two-factor login, so one
+// that grants a permission requiring it can only be created from a session
+// that has one.
+func (h *APIKeyHandler) Create(c *gin.Context) {
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, permission := range req.Permissions {
+		if middleware.TwoFactorRequired(permission) && !middleware.TwoFactorVerified(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication required"})
+			return
+		}
+	}
+
+	user, err := h.userRepo.FindByID(middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var resp *models.CreateAPIKeyResponse
+	err = h.db.InTx(func(tx *sql.Tx) error {
+		var err error
+		resp, err = h.apiKeyService.WithTx(tx).Create(user, req)
+		if err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditCreate, models.AuditEntityAPIKey, resp.APIKey.ID, nil, resp.APIKey)
+	})
+	if err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+// Revoke revokes one of the current user's keys, or any key for admins.
+func (h *APIKeyHandler) Revoke(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	asAdmin := middleware.GetRole(c).Can(models.PermissionUserManage)
+	err = h.db.InTx(func(tx *sql.Tx) error {
+		if err := h.apiKeyService.WithTx(tx).Revoke(id, middleware.GetUserID(c), asAdmin); err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditRevoke, models.AuditEntityAPIKey, id, nil, nil)
+	})
+	if err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
+
+func respondAPIKeyError(c *gin.Context, err error) {
+	var notGranted *service.PermissionNotGrantedError
+	switch {
+	case errors.Is(err, service.ErrAPIKeyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+	case errors.Is(err, service.ErrWarehouseNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Warehouse not found"})
+	case errors.Is(err, service.ErrInvalidExpiry):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Expiry must be in the future"})
+	case errors.As(err, &notGranted):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your role does not grant this permission", "permission": notGranted.Permission})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// requireWarehouse responds with 403 unless the request may act on every
+// given warehouse. A warehouse ID of 0 stands for all warehouses, which keys
+// limited to warehouses may not act on.
+func requireWarehouse(c *gin.Context, warehouseIDs ...int64) bool {
+	for _, id := range warehouseIDs {
+		if !middleware.AllowsWarehouse(c, id) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key is not allowed in this warehouse"})
+			return false
+		}
+	}
+	return true
+}
+
+// apiKeyWarehouses returns the warehouses the request's API key is limited
+// to, or nil if it is not limited.
+func apiKeyWarehouses(c *gin.Context) []int64 {
+	if apiKey := middleware.GetAPIKey(c); apiKey != nil && len(apiKey.WarehouseIDs) > 0 {
+		return apiKey.WarehouseIDs
+	}
+	return nil
+}
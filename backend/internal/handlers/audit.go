@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"zaiko/internal/middleware"
+	"zaiko/internal/models"
+	"zaiko/internal/repository"
+)
+
+type AuditHandler struct {
+	auditRepo *repository.AuditRepository
+}
+
+func NewAuditHandler(auditRepo *repository.AuditRepository) *AuditHandler {
+	return &AuditHandler{
+		auditRepo: auditRepo,
+	}
+}
+
+func (h *AuditHandler) GetAll(c *gin.Context) {
+	var filter models.AuditFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if filter.Limit <= 0 || filter.Limit > 1000 {
+		filter.Limit = 1000
+	}
+
+	entries, err := h.auditRepo.FindAll(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if entries == nil {
+		entries = []models.AuditEntry{}
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// recordAudit appends a change made by the current request to the audit
+// log. before and after are snapshots of the entity and may be nil. Master
+// data is written with auditRepo bound to the transaction that makes the
+// change, so the change is only committed together with its entry; other
+// callers fail the request when the entry cannot be written.
+func recordAudit(c *gin.Context, auditRepo *repository.AuditRepository, action models.AuditAction, entityType models.AuditEntity, entityID int64, before, after interface{}) error {
+	entry := &models.AuditEntry{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Before:     auditSnapshot(before),
+		After:      auditSnapshot(after),
+		IPAddress:  c.ClientIP(),
+	}
+
+	if userID := middleware.GetUserID(c); userID > 0 {
+		entry.ActorID = &userID
+	}
+	if key := middleware.GetAPIKey(c); key != nil {
+		entry.APIKeyID = &key.ID
+	}
+
+	if err := auditRepo.Create(entry); err != nil {
+		return fmt.Errorf("record audit entry (%s %s %d): %w", action, entityType, entityID, err)
+	}
+	return nil
+}
+
+func auditSnapshot(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil || string(data) == "null" {
+		return nil
+	}
+	return data
+}
@@ -1,25 +1,45 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
 
+	"zaiko/internal/database"
 	"zaiko/internal/middleware"
 	"zaiko/internal/models"
 	"zaiko/internal/repository"
+	"zaiko/internal/service"
 )
 
 type AuthHandler struct {
-	userRepo      *repository.UserRepository
-	jwtExpiration int
+	db               *database.DB
+	userRepo         *repository.UserRepository
+	auditRepo        *repository.AuditRepository
+	sessionService   *service.SessionService
+	twoFactorService *service.TwoFactorService
+	loginGuard       *service.LoginGuard
 }
 
-func NewAuthHandler(jwtExpiration int) *AuthHandler {
+func NewAuthHandler(
+	db *database.DB,
+	userRepo *repository.UserRepository,
+	auditRepo *repository.AuditRepository,
+	sessionService *service.SessionService,
+	twoFactorService *service.TwoFactorService,
+	loginGuard *service.LoginGuard,
+) *AuthHandler {
 	return &AuthHandler{
-		userRepo:      repository.NewUserRepository(),
-		jwtExpiration: jwtExpiration,
+		db:               db,
+		userRepo:         userRepo,
+		auditRepo:        auditRepo,
+		sessionService:   sessionService,
+		twoFactorService: twoFactorService,
+		loginGuard:       loginGuard,
 	}
 }
 
@@ -30,27 +50,85 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	// Locked out attempts are refused without checking the password
+	if err := h.loginGuard.Check(req.Username, c.ClientIP()); err != nil {
+		respondLoginError(c, err)
+		return
+	}
+
 	user, err := h.userRepo.FindByUsername(req.Username)
+	if err == nil {
+		err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password))
+	}
 	if err != nil {
+		if err := h.loginGuard.Fail(req.Username, c.ClientIP()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+	if user.Disabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is disabled"})
 		return
 	}
 
-	token, err := middleware.GenerateToken(user.ID, user.Username, h.jwtExpiration)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+	// The session is only started once the challenge is completed with a
+	// code, and the failures are kept until then so that the code cannot be
+	// guessed with a fresh count
+	if user.TwoFactorEnabled {
+		challenge, err := h.twoFactorService.Challenge(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, challenge)
 		return
 	}
 
-	c.JSON(http.StatusOK, models.LoginResponse{
-		Token: token,
-		User:  *user,
-	})
+	if err := h.loginGuard.Succeed(user.Username); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.startSession(c, user, false)
+}
+
+// Refresh exchanges a refresh token for a new token pair. Each refresh token
+// can be used once.
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := h.sessionService.Refresh(req.RefreshToken)
+	switch {
+	case errors.Is(err, service.ErrInvalidRefreshToken):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	case errors.Is(err, service.ErrAccountDisabled):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is disabled"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// Logout revokes the current session, so neither its access token nor its
+// refresh token can be used again.
+func (h *AuthHandler) Logout(c *gin.Context) {
+	if err := h.sessionService.Revoke(middleware.GetSessionID(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
 }
 
 func (h *AuthHandler) Me(c *gin.Context) {
@@ -68,3 +146,72 @@ func (h *AuthHandler) Me(c *gin.Context) {
 
 	c.JSON(http.StatusOK, user)
 }
+
+// ChangePassword lets users change their own password. All sessions of the
+// user are revoked and the response carries a new session, which is no
+// longer limited by a forced password change.
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.userRepo.FindByID(middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.CurrentPassword)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	err = h.db.InTx(func(tx *sql.Tx) error {
+		if err := h.userRepo.WithTx(tx).UpdatePassword(user.ID, string(hashedPassword), false); err != nil {
+			return err
+		}
+		if _, err := h.sessionService.WithTx(tx).RevokeAll(user.ID); err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditChangePassword, models.AuditEntityUser, user.ID, nil, nil)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	user.MustChangePassword = false
+
+	// The replacement session keeps the two-factor login of the old one
+	h.startSession(c, user, middleware.TwoFactorVerified(c))
+}
+
+func (h *AuthHandler) startSession(c *gin.Context, user *models.User, twoFactor bool) {
+	resp, err := h.sessionService.Start(user, c.Request.UserAgent(), c.ClientIP(), twoFactor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// respondLoginError answers a login guard error, telling locked out clients
+// when to retry.
+func respondLoginError(c *gin.Context, err error) {
+	var locked *service.LoginLockedError
+	if errors.As(err, &locked) {
+		seconds := int(locked.RetryAfter.Seconds())
+		c.Header("Retry-After", strconv.Itoa(seconds))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts", "retry_after": seconds})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -1,22 +1,33 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 
+	"zaiko/internal/database"
 	"zaiko/internal/models"
 	"zaiko/internal/repository"
 )
 
 type CategoryHandler struct {
+	db           *database.DB
 	categoryRepo *repository.CategoryRepository
+	auditRepo    *repository.AuditRepository
 }
 
-func NewCategoryHandler() *CategoryHandler {
+func NewCategoryHandler(
+	db *database.DB,
+	categoryRepo *repository.CategoryRepository,
+	auditRepo *repository.AuditRepository,
+) *CategoryHandler {
 	return &CategoryHandler{
-		categoryRepo: repository.NewCategoryRepository(),
+		db:           db,
+		categoryRepo: categoryRepo,
+		auditRepo:    auditRepo,
 	}
 }
 
@@ -41,7 +52,15 @@ func (h *CategoryHandler) Create(c *gin.Context) {
 		return
 	}
 
-	category, err := h.categoryRepo.Create(req.Name)
+	var category *models.Category
+	err := h.db.InTx(func(tx *sql.Tx) error {
+		var err error
+		category, err = h.categoryRepo.WithTx(tx).Create(req.Name)
+		if err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditCreate, models.AuditEntityCategory, category.ID, nil, category)
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -57,10 +76,34 @@ func (h *CategoryHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.categoryRepo.Delete(id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	before, err := h.categoryRepo.FindByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	err = h.db.InTx(func(tx *sql.Tx) error {
+		if err := h.categoryRepo.WithTx(tx).Delete(id); err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditDelete, models.AuditEntityCategory, id, before, nil)
+	})
+	if err != nil {
+		respondDeleteError(c, "Category", err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Category deleted"})
 }
+
+// respondDeleteError maps an error from deleting a master record. A record
+// that is still referenced gets a 409 with the referencing rows counted by
+// table.
+func respondDeleteError(c *gin.Context, name string, err error) {
+	var inUse *repository.InUseError
+	if errors.As(err, &inUse) {
+		c.JSON(http.StatusConflict, gin.H{"error": name + " is in use", "references": inUse.References})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
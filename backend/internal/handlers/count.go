@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"zaiko/internal/middleware"
+	"zaiko/internal/models"
+	"zaiko/internal/service"
+)
+
+type CountHandler struct {
+	countService *service.CountService
+}
+
+func NewCountHandler(countService *service.CountService) *CountHandler {
+	return &CountHandler{
+		countService: countService,
+	}
+}
+
+func (h *CountHandler) GetAll(c *gin.Context) {
+	var filter models.CountFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sessions, err := h.countService.FindAll(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if sessions == nil {
+		sessions = []models.CountSession{}
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+func (h *CountHandler) GetByID(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	session, err := h.countService.Get(id, c.Query("variance_only") == "true")
+	if err != nil {
+		respondCountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+func (h *CountHandler) Create(c *gin.Context) {
+	var req models.CreateCountSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !requireWarehouse(c, req.WarehouseID) {
+		return
+	}
+
+	session, err := h.countService.Create(req, middleware.GetUserID(c))
+	if err != nil {
+		respondCountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, session)
+}
+
+func (h *CountHandler) Submit(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if !h.requireSessionWarehouse(c, id) {
+		return
+	}
+
+	var req models.SubmitCountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := h.countService.Submit(id, req, middleware.GetUserID(c))
+	if err != nil {
+		respondCountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+func (h *CountHandler) Finalize(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if !h.requireSessionWarehouse(c, id) {
+		return
+	}
+
+	session, err := h.countService.Finalize(id, middleware.GetUserID(c))
+	if err != nil {
+		respondCountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+func (h *CountHandler) Cancel(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if !h.requireSessionWarehouse(c, id) {
+		return
+	}
+
+	session, err := h.countService.Cancel(id)
+	if err != nil {
+		respondCountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+// requireSessionWarehouse checks that an API key may act in the count
+// session's warehouse. Sessions counting all warehouses need a key without
+// warehouse limits.
+func (h *CountHandler) requireSessionWarehouse(c *gin.Context, id int64) bool {
+	if middleware.GetAPIKey(c) == nil {
+		return true
+	}
+
+	session, err := h.countService.Get(id, false)
+	if err != nil {
+		respondCountError(c, err)
+		return false
+	}
+
+	var warehouseID int64
+	if session.WarehouseID != nil {
+		warehouseID = *session.WarehouseID
+	}
+	return requireWarehouse(c, warehouseID)
+}
+
+func respondCountError(c *gin.Context, err error) {
+	var outOfScope *service.CountOutOfScopeError
+	var incomplete *service.CountIncompleteError
+	var serials *service.CountSerialsError
+	switch {
+	case errors.Is(err, service.ErrCountNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Count session not found"})
+	case errors.Is(err, service.ErrCountScopeRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "warehouse_id or category_id is required"})
+	case errors.As(err, &outOfScope):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":        "Item is outside the count scope",
+			"product_id":   outOfScope.ProductID,
+			"warehouse_id": outOfScope.WarehouseID,
+		})
+	case errors.Is(err, service.ErrCountNotOpen):
+		c.JSON(http.StatusConflict, gin.H{"error": "Count session is not open"})
+	case errors.Is(err, service.ErrCountOverlap):
+		c.JSON(http.StatusConflict, gin.H{"error": "An open count session already covers this scope"})
+	case errors.As(err, &incomplete):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":     "Count session has uncounted lines",
+			"uncounted": incomplete.Uncounted,
+		})
+	case errors.As(err, &serials):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":        "Variance on a serialized product needs one serial number per unit",
+			"product_id":   serials.ProductID,
+			"warehouse_id": serials.WarehouseID,
+			"variance":     serials.Variance,
+			"serials":      serials.Serials,
+		})
+	default:
+		respondStockError(c, err)
+	}
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+	"zaiko/internal/repository"
+)
+
+type CustomerHandler struct {
+	db           *database.DB
+	customerRepo *repository.CustomerRepository
+	auditRepo    *repository.AuditRepository
+}
+
+func NewCustomerHandler(
+	db *database.DB,
+	customerRepo *repository.CustomerRepository,
+	auditRepo *repository.AuditRepository,
+) *CustomerHandler {
+	return &CustomerHandler{
+		db:           db,
+		customerRepo: customerRepo,
+		auditRepo:    auditRepo,
+	}
+}
+
+func (h *CustomerHandler) GetAll(c *gin.Context) {
+	customers, err := h.customerRepo.FindAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if customers == nil {
+		customers = []models.Customer{}
+	}
+
+	c.JSON(http.StatusOK, customers)
+}
+
+func (h *CustomerHandler) GetByID(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	customer, err := h.customerRepo.FindByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, customer)
+}
+
+func (h *CustomerHandler) Create(c *gin.Context) {
+	var req models.CreateCustomerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var customer *models.Customer
+	err := h.db.InTx(func(tx *sql.Tx) error {
+		var err error
+		customer, err = h.customerRepo.WithTx(tx).Create(req)
+		if err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditCreate, models.AuditEntityCustomer, customer.ID, nil, customer)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, customer)
+}
+
+func (h *CustomerHandler) Update(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req models.UpdateCustomerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before, err := h.customerRepo.FindByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+		return
+	}
+
+	var customer *models.Customer
+	err = h.db.InTx(func(tx *sql.Tx) error {
+		var err error
+		customer, err = h.customerRepo.WithTx(tx).Update(id, req)
+		if err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditUpdate, models.AuditEntityCustomer, id, before, customer)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, customer)
+}
+
+func (h *CustomerHandler) Delete(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	before, err := h.customerRepo.FindByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+		return
+	}
+
+	err = h.db.InTx(func(tx *sql.Tx) error {
+		if err := h.customerRepo.WithTx(tx).Delete(id); err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditDelete, models.AuditEntityCustomer, id, before, nil)
+	})
+	if err != nil {
+		respondDeleteError(c, "Customer", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Customer deleted"})
+}
@@ -5,48 +5,57 @@ import (
 
 	"github.com/gin-gonic/gin"
 
-	"zaiko/internal/database"
 	"zaiko/internal/models"
 	"zaiko/internal/repository"
 )
 
 type DashboardHandler struct {
-	stockRepo       *repository.StockRepository
-	transactionRepo *repository.TransactionRepository
+	dashboardRepo       *repository.DashboardRepository
+	stockRepo           *repository.StockRepository
+	transactionRepo     *repository.TransactionRepository
+	reorderRepo         *repository.ReorderRepository
+	defaultReorderPoint int
 }
 
-func NewDashboardHandler() *DashboardHandler {
+func NewDashboardHandler(
+	dashboardRepo *repository.DashboardRepository,
+	stockRepo *repository.StockRepository,
+	transactionRepo *repository.TransactionRepository,
+	reorderRepo *repository.ReorderRepository,
+	defaultReorderPoint int,
+) *DashboardHandler {
 	return &DashboardHandler{
-		stockRepo:       repository.NewStockRepository(),
-		transactionRepo: repository.NewTransactionRepository(),
+		dashboardRepo:       dashboardRepo,
+		stockRepo:           stockRepo,
+		transactionRepo:     transactionRepo,
+		reorderRepo:         reorderRepo,
+		defaultReorderPoint: defaultReorderPoint,
 	}
 }
 
 func (h *DashboardHandler) GetSummary(c *gin.Context) {
 	summary := models.DashboardSummary{}
 
-	// Total products
-	if err := database.DB.QueryRow("SELECT COUNT(*) FROM products").Scan(&summary.TotalProducts); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	// Total warehouses
-	if err := database.DB.QueryRow("SELECT COUNT(*) FROM warehouses").Scan(&summary.TotalWarehouses); err != nil {
+	// Total products and warehouses
+	products, warehouses, err := h.dashboardRepo.Totals()
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	summary.TotalProducts = products
+	summary.TotalWarehouses = warehouses
 
-	// Total stock value (quantity)
-	totalStock, err := h.stockRepo.GetTotalQuantity()
+	// Total stock quantity and its value at cost
+	quantity, value, err := h.stockRepo.GetTotals()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	summary.TotalStockValue = totalStock
+	summary.TotalStockQuantity = quantity
+	summary.TotalStockValue = value
 
-	// Low stock items (threshold: 10)
-	lowStock, err := h.stockRepo.GetLowStockCount(10)
+	// Low stock items (below their reorder point)
+	lowStock, err := h.reorderRepo.CountLowStock(h.defaultReorderPoint)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -62,51 +71,18 @@ func (h *DashboardHandler) GetSummary(c *gin.Context) {
 	summary.RecentTransactions = transactions
 
 	// Stock by warehouse
-	warehouseRows, err := database.DB.Query(`
-		SELECT w.id, w.name, COUNT(DISTINCT s.product_id), COALESCE(SUM(s.quantity), 0)
-		FROM warehouses w
-		LEFT JOIN stock s ON w.id = s.warehouse_id
-		GROUP BY w.id, w.name
-		ORDER BY w.name
-	`)
+	summary.StockByWarehouse, err = h.dashboardRepo.StockByWarehouse()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer warehouseRows.Close()
-
-	for warehouseRows.Next() {
-		var ws models.WarehouseStockSummary
-		if err := warehouseRows.Scan(&ws.WarehouseID, &ws.WarehouseName, &ws.TotalItems, &ws.TotalQuantity); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		summary.StockByWarehouse = append(summary.StockByWarehouse, ws)
-	}
 
 	// Stock by category
-	categoryRows, err := database.DB.Query(`
-		SELECT c.id, c.name, COUNT(DISTINCT s.product_id), COALESCE(SUM(s.quantity), 0)
-		FROM categories c
-		LEFT JOIN products p ON c.id = p.category_id
-		LEFT JOIN stock s ON p.id = s.product_id
-		GROUP BY c.id, c.name
-		ORDER BY c.name
-	`)
+	summary.StockByCategory, err = h.dashboardRepo.StockByCategory()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer categoryRows.Close()
-
-	for categoryRows.Next() {
-		var cs models.CategoryStockSummary
-		if err := categoryRows.Scan(&cs.CategoryID, &cs.CategoryName, &cs.TotalItems, &cs.TotalQuantity); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		summary.StockByCategory = append(summary.StockByCategory, cs)
-	}
 
 	c.JSON(http.StatusOK, summary)
 }
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"zaiko/internal/models"
+	"zaiko/internal/repository"
+)
+
+type LotHandler struct {
+	lotRepo *repository.LotRepository
+}
+
+func NewLotHandler(lotRepo *repository.LotRepository) *LotHandler {
+	return &LotHandler{
+		lotRepo: lotRepo,
+	}
+}
+
+func (h *LotHandler) GetAll(c *gin.Context) {
+	var filter models.LotFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if filter.WarehouseID > 0 && !requireWarehouse(c, filter.WarehouseID) {
+		return
+	}
+	filter.WarehouseIDs = apiKeyWarehouses(c)
+
+	lots, err := h.lotRepo.FindAll(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if lots == nil {
+		lots = []models.Lot{}
+	}
+
+	c.JSON(http.StatusOK, lots)
+}
+
+func (h *LotHandler) GetExpiring(c *gin.Context) {
+	var filter models.ExpiringLotFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if filter.WarehouseID > 0 && !requireWarehouse(c, filter.WarehouseID) {
+		return
+	}
+	filter.WarehouseIDs = apiKeyWarehouses(c)
+
+	lots, err := h.lotRepo.FindExpiring(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if lots == nil {
+		lots = []models.Lot{}
+	}
+
+	c.JSON(http.StatusOK, lots)
+}
@@ -1,22 +1,36 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 
+	"zaiko/internal/database"
 	"zaiko/internal/models"
 	"zaiko/internal/repository"
 )
 
 type ProductHandler struct {
+	db          *database.DB
 	productRepo *repository.ProductRepository
+	reorderRepo *repository.ReorderRepository
+	auditRepo   *repository.AuditRepository
 }
 
-func NewProductHandler() *ProductHandler {
+func NewProductHandler(
+	db *database.DB,
+	productRepo *repository.ProductRepository,
+	reorderRepo *repository.ReorderRepository,
+	auditRepo *repository.AuditRepository,
+) *ProductHandler {
 	return &ProductHandler{
-		productRepo: repository.NewProductRepository(),
+		db:          db,
+		productRepo: productRepo,
+		reorderRepo: reorderRepo,
+		auditRepo:   auditRepo,
 	}
 }
 
@@ -63,7 +77,15 @@ func (h *ProductHandler) Create(c *gin.Context) {
 		return
 	}
 
-	product, err := h.productRepo.Create(req)
+	var product *models.Product
+	err := h.db.InTx(func(tx *sql.Tx) error {
+		var err error
+		product, err = h.productRepo.WithTx(tx).Create(req)
+		if err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditCreate, models.AuditEntityProduct, product.ID, nil, product)
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -85,7 +107,21 @@ func (h *ProductHandler) Update(c *gin.Context) {
 		return
 	}
 
-	product, err := h.productRepo.Update(id, req)
+	before, err := h.productRepo.FindByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	var product *models.Product
+	err = h.db.InTx(func(tx *sql.Tx) error {
+		var err error
+		product, err = h.productRepo.WithTx(tx).Update(id, req)
+		if err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditUpdate, models.AuditEntityProduct, id, before, product)
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -101,10 +137,129 @@ func (h *ProductHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.productRepo.Delete(id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	before, err := h.productRepo.FindByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	err = h.db.InTx(func(tx *sql.Tx) error {
+		if err := h.productRepo.WithTx(tx).Delete(id); err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditDelete, models.AuditEntityProduct, id, before, nil)
+	})
+	if err != nil {
+		respondDeleteError(c, "Product", err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Product deleted"})
 }
+
+func (h *ProductHandler) GetReorderSettings(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	settings, err := h.reorderRepo.FindByProduct(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if settings == nil {
+		settings = []models.ReorderSetting{}
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+func (h *ProductHandler) SaveReorderSetting(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req models.ReorderSettingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.MaxQuantity > 0 && req.MaxQuantity < req.ReorderPoint {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_quantity must not be less than reorder_point"})
+		return
+	}
+
+	if _, err := h.productRepo.FindByID(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	before, err := h.reorderRepo.Find(id, req.WarehouseID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	action := models.AuditUpdate
+	if before == nil {
+		action = models.AuditCreate
+	}
+
+	var setting *models.ReorderSetting
+	err = h.db.InTx(func(tx *sql.Tx) error {
+		var err error
+		setting, err = h.reorderRepo.WithTx(tx).Save(id, req)
+		if err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), action, models.AuditEntityReorderSetting, setting.ID, before, setting)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, setting)
+}
+
+func (h *ProductHandler) DeleteReorderSetting(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var warehouseID int64
+	if value := c.Query("warehouse_id"); value != "" {
+		warehouseID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid warehouse ID"})
+			return
+		}
+	}
+
+	before, err := h.reorderRepo.Find(id, warehouseID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reorder setting not found"})
+		return
+	}
+
+	err = h.db.InTx(func(tx *sql.Tx) error {
+		if err := h.reorderRepo.WithTx(tx).Delete(id, warehouseID); err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditDelete, models.AuditEntityReorderSetting, before.ID, before, nil)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Reorder setting deleted"})
+}
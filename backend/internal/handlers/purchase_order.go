@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"zaiko/internal/middleware"
+	"zaiko/internal/models"
+	"zaiko/internal/service"
+)
+
+type PurchaseOrderHandler struct {
+	purchaseOrderService *service.PurchaseOrderService
+}
+
+func NewPurchaseOrderHandler(purchaseOrderService *service.PurchaseOrderService) *PurchaseOrderHandler {
+	return &PurchaseOrderHandler{
+		purchaseOrderService: purchaseOrderService,
+	}
+}
+
+func (h *PurchaseOrderHandler) GetAll(c *gin.Context) {
+	var filter models.PurchaseOrderFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	orders, err := h.purchaseOrderService.FindAll(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if orders == nil {
+		orders = []models.PurchaseOrder{}
+	}
+
+	c.JSON(http.StatusOK, orders)
+}
+
+func (h *PurchaseOrderHandler) GetByID(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	order, err := h.purchaseOrderService.Get(id)
+	if err != nil {
+		respondPurchaseOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+func (h *PurchaseOrderHandler) Create(c *gin.Context) {
+	var req models.CreatePurchaseOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !requireWarehouse(c, req.WarehouseID) {
+		return
+	}
+
+	order, err := h.purchaseOrderService.Create(req, middleware.GetUserID(c))
+	if err != nil {
+		respondPurchaseOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, order)
+}
+
+func (h *PurchaseOrderHandler) Order(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if !h.requireOrderWarehouse(c, id) {
+		return
+	}
+
+	order, err := h.purchaseOrderService.Order(id)
+	if err != nil {
+		respondPurchaseOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+func (h *PurchaseOrderHandler) Receive(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if !h.requireOrderWarehouse(c, id) {
+		return
+	}
+
+	var req models.ReceivePurchaseOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, err := h.purchaseOrderService.Receive(id, req, middleware.GetUserID(c))
+	if err != nil {
+		respondPurchaseOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+func (h *PurchaseOrderHandler) Close(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if !h.requireOrderWarehouse(c, id) {
+		return
+	}
+
+	order, err := h.purchaseOrderService.Close(id)
+	if err != nil {
+		respondPurchaseOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+func (h *PurchaseOrderHandler) Delete(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if !h.requireOrderWarehouse(c, id) {
+		return
+	}
+
+	if err := h.purchaseOrderService.Delete(id); err != nil {
+		respondPurchaseOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Purchase order deleted"})
+}
+
+// requireOrderWarehouse checks that an API key may act in the order's
+// warehouse.
+func (h *PurchaseOrderHandler) requireOrderWarehouse(c *gin.Context, id int64) bool {
+	if middleware.GetAPIKey(c) == nil {
+		return true
+	}
+
+	order, err := h.purchaseOrderService.Get(id)
+	if err != nil {
+		respondPurchaseOrderError(c, err)
+		return false
+	}
+	return requireWarehouse(c, order.WarehouseID)
+}
+
+// respondPurchaseOrderError maps order errors to responses and falls back to
+// the stock errors a receipt can produce.
+func respondPurchaseOrderError(c *gin.Context, err error) {
+	var statusErr *service.PurchaseOrderStatusError
+	var overReceipt *service.OverReceiptError
+	var lineNotFound *service.PurchaseOrderLineNotFoundError
+	switch {
+	case errors.Is(err, service.ErrPurchaseOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Purchase order not found"})
+	case errors.Is(err, service.ErrSupplierNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Supplier not found"})
+	case errors.Is(err, service.ErrWarehouseNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Warehouse not found"})
+	case errors.As(err, &statusErr):
+		c.JSON(http.StatusConflict, gin.H{"error": "Action not allowed for this purchase order", "status": statusErr.Status})
+	case errors.As(err, &overReceipt):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":       "Received quantity exceeds outstanding quantity",
+			"line_id":     overReceipt.LineID,
+			"outstanding": overReceipt.Outstanding,
+			"requested":   overReceipt.Requested,
+		})
+	case errors.As(err, &lineNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Line is not part of the purchase order", "line_id": lineNotFound.LineID})
+	default:
+		respondStockError(c, err)
+	}
+}
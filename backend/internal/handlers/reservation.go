@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"zaiko/internal/middleware"
+	"zaiko/internal/models"
+	"zaiko/internal/service"
+)
+
+type ReservationHandler struct {
+	reservationService *service.ReservationService
+}
+
+func NewReservationHandler(reservationService *service.ReservationService) *ReservationHandler {
+	return &ReservationHandler{
+		reservationService: reservationService,
+	}
+}
+
+func (h *ReservationHandler) GetAll(c *gin.Context) {
+	var filter models.ReservationFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reservations, err := h.reservationService.FindAll(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if reservations == nil {
+		reservations = []models.Reservation{}
+	}
+
+	c.JSON(http.StatusOK, reservations)
+}
+
+func (h *ReservationHandler) GetByID(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	reservation, err := h.reservationService.Get(id)
+	if err != nil {
+		respondReservationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reservation)
+}
+
+func (h *ReservationHandler) Create(c *gin.Context) {
+	var req models.CreateReservationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !requireWarehouse(c, req.WarehouseID) {
+		return
+	}
+
+	reservation, err := h.reservationService.Create(req, middleware.GetUserID(c))
+	if err != nil {
+		respondReservationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, reservation)
+}
+
+func (h *ReservationHandler) Release(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if !h.requireReservationWarehouse(c, id) {
+		return
+	}
+
+	reservation, err := h.reservationService.Release(id)
+	if err != nil {
+		respondReservationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reservation)
+}
+
+// requireReservationWarehouse checks that an API key may act in the
+// reservation's warehouse.
+func (h *ReservationHandler) requireReservationWarehouse(c *gin.Context, id int64) bool {
+	if middleware.GetAPIKey(c) == nil {
+		return true
+	}
+
+	reservation, err := h.reservationService.Get(id)
+	if err != nil {
+		respondReservationError(c, err)
+		return false
+	}
+	return requireWarehouse(c, reservation.WarehouseID)
+}
+
+func respondReservationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrReservationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reservation not found"})
+	case errors.Is(err, service.ErrReservationReleased):
+		c.JSON(http.StatusConflict, gin.H{"error": "Reservation is already released"})
+	case errors.Is(err, service.ErrReservationExpiry):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		respondStockError(c, err)
+	}
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"zaiko/internal/middleware"
+	"zaiko/internal/models"
+	"zaiko/internal/service"
+)
+
+type SalesOrderHandler struct {
+	salesOrderService *service.SalesOrderService
+}
+
+func NewSalesOrderHandler(salesOrderService *service.SalesOrderService) *SalesOrderHandler {
+	return &SalesOrderHandler{
+		salesOrderService: salesOrderService,
+	}
+}
+
+func (h *SalesOrderHandler) GetAll(c *gin.Context) {
+	var filter models.SalesOrderFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	orders, err := h.salesOrderService.FindAll(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if orders == nil {
+		orders = []models.SalesOrder{}
+	}
+
+	c.JSON(http.StatusOK, orders)
+}
+
+func (h *SalesOrderHandler) GetByID(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	order, err := h.salesOrderService.Get(id)
+	if err != nil {
+		respondSalesOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+func (h *SalesOrderHandler) Create(c *gin.Context) {
+	var req models.CreateSalesOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !requireWarehouse(c, req.WarehouseID) {
+		return
+	}
+
+	order, err := h.salesOrderService.Create(req, middleware.GetUserID(c))
+	if err != nil {
+		respondSalesOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, order)
+}
+
+func (h *SalesOrderHandler) Confirm(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if !h.requireOrderWarehouse(c, id) {
+		return
+	}
+
+	order, err := h.salesOrderService.Confirm(id)
+	if err != nil {
+		respondSalesOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+func (h *SalesOrderHandler) Allocate(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if !h.requireOrderWarehouse(c, id) {
+		return
+	}
+
+	order, err := h.salesOrderService.Allocate(id)
+	if err != nil {
+		respondSalesOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+func (h *SalesOrderHandler) Ship(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if !h.requireOrderWarehouse(c, id) {
+		return
+	}
+
+	var req models.ShipSalesOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, err := h.salesOrderService.Ship(id, req, middleware.GetUserID(c))
+	if err != nil {
+		respondSalesOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+func (h *SalesOrderHandler) Cancel(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if !h.requireOrderWarehouse(c, id) {
+		return
+	}
+
+	order, err := h.salesOrderService.Cancel(id)
+	if err != nil {
+		respondSalesOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+func (h *SalesOrderHandler) Delete(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if !h.requireOrderWarehouse(c, id) {
+		return
+	}
+
+	if err := h.salesOrderService.Delete(id); err != nil {
+		respondSalesOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sales order deleted"})
+}
+
+// requireOrderWarehouse checks that an API key may act in the order's
+// warehouse.
+func (h *SalesOrderHandler) requireOrderWarehouse(c *gin.Context, id int64) bool {
+	if middleware.GetAPIKey(c) == nil {
+		return true
+	}
+
+	order, err := h.salesOrderService.Get(id)
+	if err != nil {
+		respondSalesOrderError(c, err)
+		return false
+	}
+	return requireWarehouse(c, order.WarehouseID)
+}
+
+// respondSalesOrderError maps order errors to responses and falls back to
+// the stock errors a shipment can produce.
+func respondSalesOrderError(c *gin.Context, err error) {
+	var statusErr *service.SalesOrderStatusError
+	var overShipment *service.OverShipmentError
+	var lineNotFound *service.SalesOrderLineNotFoundError
+	switch {
+	case errors.Is(err, service.ErrSalesOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sales order not found"})
+	case errors.Is(err, service.ErrCustomerNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+	case errors.Is(err, service.ErrWarehouseNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Warehouse not found"})
+	case errors.As(err, &statusErr):
+		c.JSON(http.StatusConflict, gin.H{"error": "Action not allowed for this sales order", "status": statusErr.Status})
+	case errors.As(err, &overShipment):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":     "Shipped quantity exceeds allocated quantity",
+			"line_id":   overShipment.LineID,
+			"shippable": overShipment.Shippable,
+			"requested": overShipment.Requested,
+		})
+	case errors.As(err, &lineNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Line is not part of the sales order", "line_id": lineNotFound.LineID})
+	default:
+		respondStockError(c, err)
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"zaiko/internal/models"
+	"zaiko/internal/repository"
+)
+
+type SerialHandler struct {
+	serialRepo *repository.SerialRepository
+}
+
+func NewSerialHandler(serialRepo *repository.SerialRepository) *SerialHandler {
+	return &SerialHandler{
+		serialRepo: serialRepo,
+	}
+}
+
+// GetHistory returns every unit carrying the serial number together with
+// the transactions that moved it across warehouses. An API key limited to
+// some warehouses only sees units that moved through them, and only those
+// movements.
+func (h *SerialHandler) GetHistory(c *gin.Context) {
+	warehouseIDs := apiKeyWarehouses(c)
+
+	serials, err := h.serialRepo.FindAllByNumber(c.Param("serial"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	histories := make([]models.SerialHistory, 0, len(serials))
+	for _, serial := range serials {
+		movements, err := h.serialRepo.FindMovements(serial.ID, warehouseIDs)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if len(warehouseIDs) > 0 && len(movements) == 0 {
+			continue
+		}
+		if movements == nil {
+			movements = []models.Transaction{}
+		}
+
+		histories = append(histories, models.SerialHistory{SerialNumber: serial, Movements: movements})
+	}
+
+	if len(histories) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Serial number not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, histories)
+}
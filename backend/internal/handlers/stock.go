@@ -1,24 +1,39 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 
 	"zaiko/internal/middleware"
 	"zaiko/internal/models"
 	"zaiko/internal/repository"
+	"zaiko/internal/service"
 )
 
 type StockHandler struct {
-	stockRepo       *repository.StockRepository
-	transactionRepo *repository.TransactionRepository
+	stockRepo           *repository.StockRepository
+	transactionRepo     *repository.TransactionRepository
+	reorderRepo         *repository.ReorderRepository
+	stockService        *service.StockService
+	defaultReorderPoint int
 }
 
-func NewStockHandler() *StockHandler {
+func NewStockHandler(
+	stockRepo *repository.StockRepository,
+	transactionRepo *repository.TransactionRepository,
+	reorderRepo *repository.ReorderRepository,
+	stockService *service.StockService,
+	defaultReorderPoint int,
+) *StockHandler {
 	return &StockHandler{
-		stockRepo:       repository.NewStockRepository(),
-		transactionRepo: repository.NewTransactionRepository(),
+		stockRepo:           stockRepo,
+		transactionRepo:     transactionRepo,
+		reorderRepo:         reorderRepo,
+		stockService:        stockService,
+		defaultReorderPoint: defaultReorderPoint,
 	}
 }
 
@@ -29,7 +44,18 @@ func (h *StockHandler) GetAll(c *gin.Context) {
 		return
 	}
 
-	stocks, err := h.stockRepo.FindAll(filter)
+	if filter.WarehouseID > 0 && !requireWarehouse(c, filter.WarehouseID) {
+		return
+	}
+	filter.WarehouseIDs = apiKeyWarehouses(c)
+
+	var stocks []models.Stock
+	var err error
+	if filter.AsOf != "" {
+		stocks, err = h.stockService.StockAsOf(filter)
+	} else {
+		stocks, err = h.stockRepo.FindAll(filter)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -42,6 +68,31 @@ func (h *StockHandler) GetAll(c *gin.Context) {
 	c.JSON(http.StatusOK, stocks)
 }
 
+func (h *StockHandler) GetLowStock(c *gin.Context) {
+	var filter models.LowStockFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if filter.WarehouseID > 0 && !requireWarehouse(c, filter.WarehouseID) {
+		return
+	}
+	filter.WarehouseIDs = apiKeyWarehouses(c)
+
+	items, err := h.reorderRepo.FindLowStock(filter, h.defaultReorderPoint)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if items == nil {
+		items = []models.LowStockItem{}
+	}
+
+	c.JSON(http.StatusOK, items)
+}
+
 func (h *StockHandler) StockIn(c *gin.Context) {
 	var req models.StockMovementRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -49,25 +100,15 @@ func (h *StockHandler) StockIn(c *gin.Context) {
 		return
 	}
 
-	userID := middleware.GetUserID(c)
-
-	// Update stock quantity
-	if err := h.stockRepo.UpdateQuantity(req.ProductID, req.WarehouseID, req.Quantity); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if !requireWarehouse(c, req.WarehouseID) {
 		return
 	}
 
-	// Record transaction
-	transaction, err := h.transactionRepo.Create(
-		req.ProductID,
-		req.WarehouseID,
-		models.TransactionTypeIn,
-		req.Quantity,
-		req.Note,
-		userID,
-	)
+	userID := middleware.GetUserID(c)
+
+	transaction, err := h.stockService.StockIn(req, userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondStockError(c, err)
 		return
 	}
 
@@ -84,50 +125,107 @@ func (h *StockHandler) StockOut(c *gin.Context) {
 		return
 	}
 
+	if !requireWarehouse(c, req.WarehouseID) {
+		return
+	}
+
 	userID := middleware.GetUserID(c)
 
-	// Check current stock
-	stock, err := h.stockRepo.FindByProductAndWarehouse(req.ProductID, req.WarehouseID)
+	transaction, err := h.stockService.StockOut(req, userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Stock record not found"})
+		respondStockError(c, err)
 		return
 	}
 
-	if stock.Quantity < req.Quantity {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":     "Insufficient stock",
-			"available": stock.Quantity,
-			"requested": req.Quantity,
-		})
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Stock shipped successfully",
+		"transaction": transaction,
+	})
+}
+
+// Adjust corrects the quantity on hand for a reason code.
+func (h *StockHandler) Adjust(c *gin.Context) {
+	var req models.StockAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Update stock quantity (negative for outbound)
-	if err := h.stockRepo.UpdateQuantity(req.ProductID, req.WarehouseID, -req.Quantity); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if !requireWarehouse(c, req.WarehouseID) {
 		return
 	}
 
-	// Record transaction
-	transaction, err := h.transactionRepo.Create(
-		req.ProductID,
-		req.WarehouseID,
-		models.TransactionTypeOut,
-		req.Quantity,
-		req.Note,
-		userID,
-	)
+	userID := middleware.GetUserID(c)
+
+	transaction, err := h.stockService.Adjust(req, userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondStockError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":     "Stock shipped successfully",
+		"message":     "Stock adjusted successfully",
 		"transaction": transaction,
 	})
 }
 
+// ReverseTransaction posts a transaction that cancels a posted one.
+func (h *StockHandler) ReverseTransaction(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	original, err := h.transactionRepo.FindByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+	if !requireWarehouse(c, original.WarehouseID) {
+		return
+	}
+
+	userID := middleware.GetUserID(c)
+
+	reversal, err := h.stockService.Reverse(id, userID)
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Transaction reversed successfully",
+		"transaction": reversal,
+	})
+}
+
+func (h *StockHandler) Transfer(c *gin.Context) {
+	var req models.StockTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !requireWarehouse(c, req.FromWarehouseID, req.ToWarehouseID) {
+		return
+	}
+
+	userID := middleware.GetUserID(c)
+
+	fromTransaction, toTransaction, err := h.stockService.Transfer(req, userID)
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Stock transferred successfully",
+		"from_transaction": fromTransaction,
+		"to_transaction":   toTransaction,
+	})
+}
+
 func (h *StockHandler) GetTransactions(c *gin.Context) {
 	var filter models.TransactionFilter
 	if err := c.ShouldBindQuery(&filter); err != nil {
@@ -135,6 +233,11 @@ func (h *StockHandler) GetTransactions(c *gin.Context) {
 		return
 	}
 
+	if filter.WarehouseID > 0 && !requireWarehouse(c, filter.WarehouseID) {
+		return
+	}
+	filter.WarehouseIDs = apiKeyWarehouses(c)
+
 	transactions, err := h.transactionRepo.FindAll(filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -147,3 +250,123 @@ func (h *StockHandler) GetTransactions(c *gin.Context) {
 
 	c.JSON(http.StatusOK, transactions)
 }
+
+// GetLedger lists the movements of a product with its running balance.
+func (h *StockHandler) GetLedger(c *gin.Context) {
+	var filter models.LedgerFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if filter.WarehouseID > 0 && !requireWarehouse(c, filter.WarehouseID) {
+		return
+	}
+	filter.WarehouseIDs = apiKeyWarehouses(c)
+
+	ledger, err := h.stockService.Ledger(filter)
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ledger)
+}
+
+// GetValuation reports the quantity and value of stock per warehouse and
+// category at the end of the day given by as_of (default today).
+func (h *StockHandler) GetValuation(c *gin.Context) {
+	var filter models.ValuationFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.WarehouseIDs = apiKeyWarehouses(c)
+
+	report, err := h.stockService.Valuation(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func respondStockError(c *gin.Context, err error) {
+	var insufficient *service.InsufficientStockError
+	var reserved *service.ReservedStockError
+	var lotNotFound *service.LotNotFoundError
+	var duplicateSerial *service.DuplicateSerialError
+	var unknownSerial *service.UnknownSerialError
+	var notReversible *service.NotReversibleError
+	switch {
+	case errors.Is(err, service.ErrStockNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stock record not found"})
+	case errors.Is(err, service.ErrStockLocked):
+		c.JSON(http.StatusConflict, gin.H{"error": "Stock is locked by an open inventory count"})
+	case errors.As(err, &insufficient):
+		body := gin.H{
+			"error":     "Insufficient stock",
+			"available": insufficient.Available,
+			"requested": insufficient.Requested,
+		}
+		if insufficient.LotNumber != "" {
+			body["lot_number"] = insufficient.LotNumber
+		}
+		c.JSON(http.StatusBadRequest, body)
+	case errors.As(err, &reserved):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":     "Stock is reserved",
+			"available": reserved.Available,
+			"reserved":  reserved.Reserved,
+			"requested": reserved.Requested,
+		})
+	case errors.As(err, &lotNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lot not found", "lot_number": lotNotFound.LotNumber})
+	case errors.Is(err, service.ErrLotQuantityMismatch), errors.Is(err, service.ErrDuplicateLotPick):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	case errors.Is(err, service.ErrReasonNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Adjustment reason not found"})
+	case errors.Is(err, service.ErrAdjustmentQuantity), errors.Is(err, service.ErrAdjustmentUnchanged):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrTransactionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+	case errors.Is(err, service.ErrAlreadyReversed):
+		c.JSON(http.StatusConflict, gin.H{"error": "Transaction has already been reversed"})
+	case errors.As(err, &notReversible):
+		c.JSON(http.StatusConflict, gin.H{"error": "Transaction cannot be reversed", "reason": notReversible.Reason})
+	case errors.Is(err, service.ErrSerialCountMismatch), errors.Is(err, service.ErrSerialsNotAllowed):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.As(err, &duplicateSerial):
+		c.JSON(http.StatusConflict, gin.H{"error": "Duplicate serial number", "serial": duplicateSerial.Serial})
+	case errors.As(err, &unknownSerial):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Serial number is not in stock at this warehouse", "serial": unknownSerial.Serial})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// Verify lists the stock rows whose quantity differs from the sum of their
+// transactions.
+func (h *StockHandler) Verify(c *gin.Context) {
+	report, err := h.stockService.Verify(false, middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// Repair posts an adjustment transaction for every mismatch Verify finds.
+func (h *StockHandler) Repair(c *gin.Context) {
+	report, err := h.stockService.Verify(true, middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
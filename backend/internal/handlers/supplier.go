@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+	"zaiko/internal/repository"
+)
+
+type SupplierHandler struct {
+	db           *database.DB
+	supplierRepo *repository.SupplierRepository
+	auditRepo    *repository.AuditRepository
+}
+
+func NewSupplierHandler(
+	db *database.DB,
+	supplierRepo *repository.SupplierRepository,
+	auditRepo *repository.AuditRepository,
+) *SupplierHandler {
+	return &SupplierHandler{
+		db:           db,
+		supplierRepo: supplierRepo,
+		auditRepo:    auditRepo,
+	}
+}
+
+func (h *SupplierHandler) GetAll(c *gin.Context) {
+	suppliers, err := h.supplierRepo.FindAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if suppliers == nil {
+		suppliers = []models.Supplier{}
+	}
+
+	c.JSON(http.StatusOK, suppliers)
+}
+
+func (h *SupplierHandler) GetByID(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	supplier, err := h.supplierRepo.FindByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Supplier not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, supplier)
+}
+
+func (h *SupplierHandler) Create(c *gin.Context) {
+	var req models.CreateSupplierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var supplier *models.Supplier
+	err := h.db.InTx(func(tx *sql.Tx) error {
+		var err error
+		supplier, err = h.supplierRepo.WithTx(tx).Create(req)
+		if err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditCreate, models.AuditEntitySupplier, supplier.ID, nil, supplier)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, supplier)
+}
+
+func (h *SupplierHandler) Update(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req models.UpdateSupplierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before, err := h.supplierRepo.FindByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Supplier not found"})
+		return
+	}
+
+	var supplier *models.Supplier
+	err = h.db.InTx(func(tx *sql.Tx) error {
+		var err error
+		supplier, err = h.supplierRepo.WithTx(tx).Update(id, req)
+		if err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditUpdate, models.AuditEntitySupplier, id, before, supplier)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, supplier)
+}
+
+func (h *SupplierHandler) Delete(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	before, err := h.supplierRepo.FindByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Supplier not found"})
+		return
+	}
+
+	err = h.db.InTx(func(tx *sql.Tx) error {
+		if err := h.supplierRepo.WithTx(tx).Delete(id); err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditDelete, models.AuditEntitySupplier, id, before, nil)
+	})
+	if err != nil {
+		respondDeleteError(c, "Supplier", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Supplier deleted"})
+}
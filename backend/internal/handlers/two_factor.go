@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+
+	"zaiko/internal/database"
+	"zaiko/internal/middleware"
+	"zaiko/internal/models"
+	"zaiko/internal/repository"
+	"zaiko/internal/service"
+)
+
+type TwoFactorHandler struct {
+	db               *database.DB
+	userRepo         *repository.UserRepository
+	auditRepo        *repository.AuditRepository
+	twoFactorService *service.TwoFactorService
+}
+
+func NewTwoFactorHandler(
+	db *database.DB,
+	userRepo *repository.UserRepository,
+	auditRepo *repository.AuditRepository,
+	twoFactorService *service.TwoFactorService,
+) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		db:               db,
+		userRepo:         userRepo,
+		auditRepo:        auditRepo,
+		twoFactorService: twoFactorService,
+	}
+}
+
+// Setup starts enrollment with a new secret, returned with an otpauth URI
+// for authenticator apps.
+func (h *TwoFactorHandler) Setup(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	setup, err := h.twoFactorService.Setup(user)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, setup)
+}
+
+// Enable completes enrollment with a code from the authenticator and
+// returns the recovery codes.
+func (h *TwoFactorHandler) Enable(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	var codes []string
+	err := h.db.InTx(func(tx *sql.Tx) error {
+		var err error
+		codes, err = h.twoFactorService.WithTx(tx).Enable(user, middleware.GetSessionID(c), req.Code)
+		if err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditEnable2FA, models.AuditEntityUser, user.ID, nil, nil)
+	})
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Disable turns two-factor authentication off. It requires the password
+// and a code.
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	var req models.DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		return
+	}
+
+	err := h.db.InTx(func(tx *sql.Tx) error {
+		if err := h.twoFactorService.WithTx(tx).Disable(user, req.Code); err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditDisable2FA, models.AuditEntityUser, user.ID, nil, nil)
+	})
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	var codes []string
+	err := h.db.InTx(func(tx *sql.Tx) error {
+		var err error
+		codes, err = h.twoFactorService.WithTx(tx).RegenerateRecoveryCodes(user, req.Code)
+		if err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditRegenerateRecoveryCodes, models.AuditEntityUser, user.ID, nil, nil)
+	})
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Verify completes a login challenge with a TOTP code or a recovery code.
+func (h *TwoFactorHandler) Verify(c *gin.Context) {
+	var req models.TwoFactorVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := h.twoFactorService.Verify(req.Challenge, req.Code, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *TwoFactorHandler) currentUser(c *gin.Context) (*models.User, bool) {
+	user, err := h.userRepo.FindByID(middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	return user, true
+}
+
+func respondTwoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrTwoFactorAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+	case errors.Is(err, service.ErrTwoFactorNotEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is not enabled"})
+	case errors.Is(err, service.ErrTwoFactorNotSetUp):
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication has not been set up"})
+	case errors.Is(err, service.ErrInvalidTwoFactorCode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+	case errors.Is(err, service.ErrInvalidChallenge):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired two-factor challenge"})
+	case errors.Is(err, service.ErrAccountDisabled):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is disabled"})
+	case errors.As(err, new(*service.LoginLockedError)):
+		respondLoginError(c, err)
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+
+	"zaiko/internal/database"
+	"zaiko/internal/middleware"
+	"zaiko/internal/models"
+	"zaiko/internal/repository"
+	"zaiko/internal/service"
+)
+
+type UserHandler struct {
+	db            *database.DB
+	userRepo      *repository.UserRepository
+	sessionRepo   *repository.SessionRepository
+	twoFactorRepo *repository.TwoFactorRepository
+	auditRepo     *repository.AuditRepository
+	loginGuard    *service.LoginGuard
+}
+
+func NewUserHandler(
+	db *database.DB,
+	userRepo *repository.UserRepository,
+	sessionRepo *repository.SessionRepository,
+	twoFactorRepo *repository.TwoFactorRepository,
+	auditRepo *repository.AuditRepository,
+	loginGuard *service.LoginGuard,
+) *UserHandler {
+	return &UserHandler{
+		db:            db,
+		userRepo:      userRepo,
+		sessionRepo:   sessionRepo,
+		twoFactorRepo: twoFactorRepo,
+		auditRepo:     auditRepo,
+		loginGuard:    loginGuard,
+	}
+}
+
+func (h *UserHandler) GetAll(c *gin.Context) {
+	users, err := h.userRepo.FindAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if users == nil {
+		users = []models.User{}
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+func (h *UserHandler) Create(c *gin.Context) {
+	var req models.CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, err := h.userRepo.FindByUsername(req.Username)
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
+		return
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	var user *models.User
+	err = h.db.InTx(func(tx *sql.Tx) error {
+		var err error
+		user, err = h.userRepo.WithTx(tx).Create(req.Username, string(hashedPassword), req.Role)
+		if err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditCreate, models.AuditEntityUser, user.ID, nil, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+func (h *UserHandler) Disable(c *gin.Context) {
+	h.setDisabled(c, true)
+}
+
+func (h *UserHandler) Enable(c *gin.Context) {
+	h.setDisabled(c, false)
+}
+
+func (h *UserHandler) setDisabled(c *gin.Context, disabled bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if disabled && id == middleware.GetUserID(c) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot disable your own account"})
+		return
+	}
+
+	action := models.AuditEnable
+	if disabled {
+		action = models.AuditDisable
+	}
+
+	var user *models.User
+	err = h.db.InTx(func(tx *sql.Tx) error {
+		users := h.userRepo.WithTx(tx)
+		before, err := users.FindByID(id)
+		if err != nil {
+			return err
+		}
+
+		if err := users.SetDisabled(id, disabled); err != nil {
+			return err
+		}
+		if disabled {
+			if _, err := h.sessionRepo.WithTx(tx).RevokeAllForUser(id); err != nil {
+				return err
+			}
+		}
+
+		user, err = users.FindByID(id)
+		if err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), action, models.AuditEntityUser, id, before, user)
+	})
+	if err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ResetPassword sets a temporary password that the user has to change at
+// the next login. The user's sessions are revoked.
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	err = h.db.InTx(func(tx *sql.Tx) error {
+		users := h.userRepo.WithTx(tx)
+		if _, err := users.FindByID(id); err != nil {
+			return err
+		}
+
+		if err := users.UpdatePassword(id, string(hashedPassword), true); err != nil {
+			return err
+		}
+		if _, err := h.sessionRepo.WithTx(tx).RevokeAllForUser(id); err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditResetPassword, models.AuditEntityUser, id, nil, nil)
+	})
+	if err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset"})
+}
+
+// RevokeSessions signs a user out everywhere.
+func (h *UserHandler) RevokeSessions(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var revoked int64
+	err = h.db.InTx(func(tx *sql.Tx) error {
+		if _, err := h.userRepo.WithTx(tx).FindByID(id); err != nil {
+			return err
+		}
+
+		var err error
+		revoked, err = h.sessionRepo.WithTx(tx).RevokeAllForUser(id)
+		if err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditRevokeSessions, models.AuditEntityUser, id, nil, gin.H{"revoked": revoked})
+	})
+	if err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions revoked", "revoked": revoked})
+}
+
+// Unlock lifts a lockout caused by failed logins for the user's username.
+func (h *UserHandler) Unlock(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	err = h.db.InTx(func(tx *sql.Tx) error {
+		user, err := h.userRepo.WithTx(tx).FindByID(id)
+		if err != nil {
+			return err
+		}
+
+		if err := h.loginGuard.WithTx(tx).Unlock(user.Username, middleware.GetUserID(c)); err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditUnlock, models.AuditEntityUser, id, nil, nil)
+	})
+	if err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked"})
+}
+
+// ResetTwoFactor turns off two-factor authentication for a user who has
+// lost their authenticator and recovery codes, so that they can enroll
+// again.
+func (h *UserHandler) ResetTwoFactor(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	err = h.db.InTx(func(tx *sql.Tx) error {
+		users := h.userRepo.WithTx(tx)
+		before, err := users.FindByID(id)
+		if err != nil {
+			return err
+		}
+
+		if err := h.twoFactorRepo.WithTx(tx).Disable(id); err != nil {
+			return err
+		}
+
+		user, err := users.FindByID(id)
+		if err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditReset2FA, models.AuditEntityUser, id, before, user)
+	})
+	if err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+}
+
+func (h *UserHandler) LockoutEvents(c *gin.Context) {
+	var filter models.LockoutEventFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	events, err := h.loginGuard.Events(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if events == nil {
+		events = []models.LockoutEvent{}
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// respondUserError answers the error of a change to a user, which is read
+// in the same transaction as the change.
+func respondUserError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -1,22 +1,32 @@
 package handlers
 
 import (
+	"database/sql"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 
+	"zaiko/internal/database"
 	"zaiko/internal/models"
 	"zaiko/internal/repository"
 )
 
 type WarehouseHandler struct {
+	db            *database.DB
 	warehouseRepo *repository.WarehouseRepository
+	auditRepo     *repository.AuditRepository
 }
 
-func NewWarehouseHandler() *WarehouseHandler {
+func NewWarehouseHandler(
+	db *database.DB,
+	warehouseRepo *repository.WarehouseRepository,
+	auditRepo *repository.AuditRepository,
+) *WarehouseHandler {
 	return &WarehouseHandler{
-		warehouseRepo: repository.NewWarehouseRepository(),
+		db:            db,
+		warehouseRepo: warehouseRepo,
+		auditRepo:     auditRepo,
 	}
 }
 
@@ -57,7 +67,15 @@ func (h *WarehouseHandler) Create(c *gin.Context) {
 		return
 	}
 
-	warehouse, err := h.warehouseRepo.Create(req)
+	var warehouse *models.Warehouse
+	err := h.db.InTx(func(tx *sql.Tx) error {
+		var err error
+		warehouse, err = h.warehouseRepo.WithTx(tx).Create(req)
+		if err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditCreate, models.AuditEntityWarehouse, warehouse.ID, nil, warehouse)
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -79,7 +97,21 @@ func (h *WarehouseHandler) Update(c *gin.Context) {
 		return
 	}
 
-	warehouse, err := h.warehouseRepo.Update(id, req)
+	before, err := h.warehouseRepo.FindByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Warehouse not found"})
+		return
+	}
+
+	var warehouse *models.Warehouse
+	err = h.db.InTx(func(tx *sql.Tx) error {
+		var err error
+		warehouse, err = h.warehouseRepo.WithTx(tx).Update(id, req)
+		if err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditUpdate, models.AuditEntityWarehouse, id, before, warehouse)
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -95,8 +127,20 @@ func (h *WarehouseHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.warehouseRepo.Delete(id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	before, err := h.warehouseRepo.FindByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Warehouse not found"})
+		return
+	}
+
+	err = h.db.InTx(func(tx *sql.Tx) error {
+		if err := h.warehouseRepo.WithTx(tx).Delete(id); err != nil {
+			return err
+		}
+		return recordAudit(c, h.auditRepo.WithTx(tx), models.AuditDelete, models.AuditEntityWarehouse, id, before, nil)
+	})
+	if err != nil {
+		respondDeleteError(c, "Warehouse", err)
 		return
 	}
 
@@ -7,6 +7,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+
+	"zaiko/internal/models"
+	"zaiko/internal/repository"
 )
 
 var JWTSecret []byte
@@ -15,18 +18,27 @@ func SetJWTSecret(secret string) {
 	JWTSecret = []byte(secret)
 }
 
+// Claims are carried by access tokens. SessionID ties the token to a
+// session that can be revoked; MustChangePassword limits the token to
+// changing the password.
 type Claims struct {
-	UserID   int64  `json:"user_id"`
-	Username string `json:"username"`
+	UserID             int64       `json:"user_id"`
+	Username           string      `json:"username"`
+	Role               models.Role `json:"role"`
+	SessionID          int64       `json:"sid"`
+	MustChangePassword bool        `json:"must_change_password,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID int64, username string, expirationHours int) (string, error) {
+func GenerateToken(user *models.User, sessionID int64, ttl time.Duration) (string, error) {
 	claims := &Claims{
-		UserID:   userID,
-		Username: username,
+		UserID:             user.ID,
+		Username:           user.Username,
+		Role:               user.Role,
+		SessionID:          sessionID,
+		MustChangePassword: user.MustChangePassword,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(expirationHours) * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -35,8 +47,17 @@ func GenerateToken(userID int64, username string, expirationHours int) (string,
 	return token.SignedString(JWTSecret)
 }
 
-func AuthMiddleware() gin.HandlerFunc {
+func AuthMiddleware(
+	userRepo *repository.UserRepository,
+	sessionRepo *repository.SessionRepository,
+	apiKeyRepo *repository.APIKeyRepository,
+) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if key := apiKeyFromRequest(c); key != "" {
+			authenticateAPIKey(c, apiKeyRepo, userRepo, key)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
@@ -62,12 +83,79 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
+		// Logging out or disabling a user invalidates tokens that were
+		// already issued
+		session, err := sessionRepo.FindByID(claims.SessionID)
+		if err != nil || !session.Active() {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+			c.Abort()
+			return
+		}
+
+		user, err := userRepo.FindByID(claims.UserID)
+		if err != nil || user.Disabled {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is disabled"})
+			c.Abort()
+			return
+		}
+
+		// The role comes from the database so that a changed role takes
+		// effect without waiting for the token to expire
+		c.Set("user_id", user.ID)
+		c.Set("username", user.Username)
+		c.Set("role", user.Role)
+		c.Set("session_id", claims.SessionID)
+		c.Set("must_change_password", claims.MustChangePassword)
+		// Sessions started before two-factor authentication was enabled, or
+		// still alive after it was reset, do not count
+		c.Set("two_factor", session.TwoFactor && user.TwoFactorEnabled)
 		c.Next()
 	}
 }
 
+// apiKeyFromRequest returns the API key sent in the X-API-Key header, or as
+// a bearer token, if any.
+func apiKeyFromRequest(c *gin.Context) string {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	if token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "); strings.HasPrefix(token, "zk_") {
+		return token
+	}
+	return ""
+}
+
+// authenticateAPIKey authenticates the request as the owner of the key.
+// The key's own permissions are checked by RequirePermission.
+func authenticateAPIKey(c *gin.Context, apiKeyRepo *repository.APIKeyRepository, userRepo *repository.UserRepository, key string) {
+	apiKey, err := apiKeyRepo.FindByKey(key)
+	if err != nil || !apiKey.Active() {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired API key"})
+		c.Abort()
+		return
+	}
+
+	user, err := userRepo.FindByID(apiKey.UserID)
+	if err != nil || user.Disabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is disabled"})
+		c.Abort()
+		return
+	}
+
+	if err := apiKeyRepo.Touch(apiKey.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.Set("user_id", user.ID)
+	c.Set("username", user.Username)
+	c.Set("role", user.Role)
+	c.Set("api_key", apiKey)
+	c.Set("must_change_password", user.MustChangePassword)
+	c.Next()
+}
+
 func GetUserID(c *gin.Context) int64 {
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -75,3 +163,56 @@ func GetUserID(c *gin.Context) int64 {
 	}
 	return userID.(int64)
 }
+
+func GetRole(c *gin.Context) models.Role {
+	role, exists := c.Get("role")
+	if !exists {
+		return ""
+	}
+	return role.(models.Role)
+}
+
+func GetSessionID(c *gin.Context) int64 {
+	return c.GetInt64("session_id")
+}
+
+func MustChangePassword(c *gin.Context) bool {
+	return c.GetBool("must_change_password")
+}
+
+// TwoFactorVerified reports whether the request belongs to a session that
+// was logged in with a two-factor code. It is false for API keys, which
+// RequirePermission does not ask for one.
+func TwoFactorVerified(c *gin.Context) bool {
+	return c.GetBool("two_factor")
+}
+
+// GetAPIKey returns the API key the request was authenticated with, or nil
+// for requests authenticated with an access token.
+func GetAPIKey(c *gin.Context) *models.APIKey {
+	apiKey, exists := c.Get("api_key")
+	if !exists {
+		return nil
+	}
+	return apiKey.(*models.APIKey)
+}
+
+// AllowsWarehouse reports whether the request may act on the warehouse.
+// Only API keys can be limited to warehouses.
+func AllowsWarehouse(c *gin.Context, warehouseID int64) bool {
+	apiKey := GetAPIKey(c)
+	return apiKey == nil || apiKey.AllowsWarehouse(warehouseID)
+}
+
+// RequireSession rejects requests made with an API key. It guards account
+// management, which keys must not be able to reach.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if GetAPIKey(c) != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not available with an API key"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"zaiko/internal/models"
+)
+
+// twoFactorRequired lists permissions that can only be used from a session
+// that was logged in with a two-factor code. API keys are exempt: they are
+// meant for devices that cannot enter a code, and a key that grants such a
+// permission can only be created from a two-factor session.
+var twoFactorRequired = map[models.Permission]bool{}
+
+// SetTwoFactorRequired sets whether permission requires a two-factor login.
+func SetTwoFactorRequired(permission models.Permission, required bool) {
+	twoFactorRequired[permission] = required
+}
+
+// TwoFactorRequired reports whether permission requires a two-factor login.
+func TwoFactorRequired(permission models.Permission) bool {
+	return twoFactorRequired[permission]
+}
+
+// RequirePermission rejects requests whose role, or API key, does not grant
+// permission, and all requests from users who still have to change their
+// password. It must run after AuthMiddleware.
+func RequirePermission(permission models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if MustChangePassword(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Password change required"})
+			c.Abort()
+			return
+		}
+		if !GetRole(c).Can(permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			c.Abort()
+			return
+		}
+		if apiKey := GetAPIKey(c); apiKey != nil && !apiKey.Can(permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key does not grant this permission"})
+			c.Abort()
+			return
+		}
+		if twoFactorRequired[permission] && GetAPIKey(c) == nil && !TwoFactorVerified(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
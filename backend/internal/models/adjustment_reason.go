@@ -0,0 +1,19 @@
+package models
+
+// AdjustmentReason classifies manual stock adjustments, e.g. damage, loss,
+// found items or samples.
+type AdjustmentReason struct {
+	ID   int64  `json:"id"`
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+type CreateAdjustmentReasonRequest struct {
+	Code string `json:"code" binding:"required"`
+	Name string `json:"name" binding:"required"`
+}
+
+type UpdateAdjustmentReasonRequest struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// APIKey authenticates a machine client as its owner. It grants only the
+// listed permissions that the owner's role still has, and when WarehouseIDs
+// is not empty only in those warehouses. Prefix identifies the key in lists;
+// the key itself is shown once on creation.
+type APIKey struct {
+	ID           int64        `json:"id"`
+	UserID       int64        `json:"user_id"`
+	Username     string       `json:"username"`
+	Name         string       `json:"name"`
+	Prefix       string       `json:"prefix"`
+	Permissions  []Permission `json:"permissions"`
+	WarehouseIDs []int64      `json:"warehouse_ids"`
+	ExpiresAt    *time.Time   `json:"expires_at"`
+	LastUsedAt   *time.Time   `json:"last_used_at"`
+	CreatedAt    time.Time    `json:"created_at"`
+	RevokedAt    *time.Time   `json:"revoked_at"`
+}
+
+// Active reports whether the key can still be used.
+func (k *APIKey) Active() bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || time.Now().Before(*k.ExpiresAt))
+}
+
+// Can reports whether the key grants the permission.
+func (k *APIKey) Can(permission Permission) bool {
+	return slices.Contains(k.Permissions, permission)
+}
+
+// AllowsWarehouse reports whether the key may be used in the warehouse.
+func (k *APIKey) AllowsWarehouse(warehouseID int64) bool {
+	return len(k.WarehouseIDs) == 0 || slices.Contains(k.WarehouseIDs, warehouseID)
+}
+
+// CreateAPIKeyRequest creates a key for the current user. User management
+// cannot be granted to keys.
+type CreateAPIKeyRequest struct {
+	Name         string       `json:"name" binding:"required"`
+	Permissions  []Permission `json:"permissions" binding:"required,min=1,dive,oneof=read stock:write master:write master:delete"`
+	WarehouseIDs []int64      `json:"warehouse_ids"`
+	ExpiresAt    *time.Time   `json:"expires_at"`
+}
+
+// CreateAPIKeyResponse is the only response that contains the key.
+type CreateAPIKeyResponse struct {
+	Key    string `json:"key"`
+	APIKey APIKey `json:"api_key"`
+}
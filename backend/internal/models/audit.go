@@ -0,0 +1,67 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditAction string
+
+const (
+	AuditCreate                  AuditAction = "create"
+	AuditUpdate                  AuditAction = "update"
+	AuditDelete                  AuditAction = "delete"
+	AuditDisable                 AuditAction = "disable"
+	AuditEnable                  AuditAction = "enable"
+	AuditResetPassword           AuditAction = "reset_password"
+	AuditChangePassword          AuditAction = "change_password"
+	AuditRevokeSessions          AuditAction = "revoke_sessions"
+	AuditUnlock                  AuditAction = "unlock"
+	AuditEnable2FA               AuditAction = "enable_2fa"
+	AuditDisable2FA              AuditAction = "disable_2fa"
+	AuditReset2FA                AuditAction = "reset_2fa"
+	AuditRegenerateRecoveryCodes AuditAction = "regenerate_recovery_codes"
+	AuditRevoke                  AuditAction = "revoke"
+)
+
+type AuditEntity string
+
+const (
+	AuditEntityProduct          AuditEntity = "product"
+	AuditEntityCategory         AuditEntity = "category"
+	AuditEntityWarehouse        AuditEntity = "warehouse"
+	AuditEntitySupplier         AuditEntity = "supplier"
+	AuditEntityCustomer         AuditEntity = "customer"
+	AuditEntityReorderSetting   AuditEntity = "reorder_setting"
+	AuditEntityUser             AuditEntity = "user"
+	AuditEntityAPIKey           AuditEntity = "api_key"
+	AuditEntityAdjustmentReason AuditEntity = "adjustment_reason"
+)
+
+// AuditEntry records one change to master data, users or settings. Before
+// and After are JSON snapshots of the entity; Before is null on creation and
+// After is null on deletion. Entries cannot be changed or deleted.
+type AuditEntry struct {
+	ID            int64           `json:"id"`
+	ActorID       *int64          `json:"actor_id"`
+	ActorUsername string          `json:"actor_username"`
+	APIKeyID      *int64          `json:"api_key_id"`
+	Action        AuditAction     `json:"action"`
+	EntityType    AuditEntity     `json:"entity_type"`
+	EntityID      int64           `json:"entity_id"`
+	Before        json.RawMessage `json:"before"`
+	After         json.RawMessage `json:"after"`
+	IPAddress     string          `json:"ip_address"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// AuditFilter selects audit entries. From and To are inclusive dates.
+type AuditFilter struct {
+	EntityType AuditEntity `form:"entity_type"`
+	EntityID   int64       `form:"entity_id"`
+	ActorID    int64       `form:"actor_id"`
+	Action     AuditAction `form:"action"`
+	From       string      `form:"from" binding:"omitempty,datetime=2006-01-02"`
+	To         string      `form:"to" binding:"omitempty,datetime=2006-01-02"`
+	Limit      int         `form:"limit"`
+}
@@ -0,0 +1,63 @@
+package models
+
+// CostingMethod decides the cost at which stock leaves a warehouse.
+type CostingMethod string
+
+const (
+	// CostingMovingAverage issues stock at the average cost of what is on
+	// hand in the warehouse
+	CostingMovingAverage CostingMethod = "moving_average"
+	// CostingFIFO issues stock at the cost of the oldest receipts first
+	CostingFIFO CostingMethod = "fifo"
+)
+
+// Valid reports whether m is a known costing method.
+func (m CostingMethod) Valid() bool {
+	return m == CostingMovingAverage || m == CostingFIFO
+}
+
+// CostLayer is a quantity of a product received into a warehouse at one
+// unit cost. Remaining is what has not been issued yet.
+type CostLayer struct {
+	ID            int64   `json:"id"`
+	ProductID     int64   `json:"product_id"`
+	WarehouseID   int64   `json:"warehouse_id"`
+	TransactionID *int64  `json:"transaction_id"`
+	UnitCost      float64 `json:"unit_cost"`
+	Quantity      int     `json:"quantity"`
+	Remaining     int     `json:"remaining"`
+}
+
+// ValuationReport is the quantity and value of stock at the end of a day.
+type ValuationReport struct {
+	AsOf          string               `json:"as_of"`
+	Method        CostingMethod        `json:"method"`
+	TotalQuantity int                  `json:"total_quantity"`
+	TotalValue    float64              `json:"total_value"`
+	ByWarehouse   []WarehouseValuation `json:"by_warehouse"`
+	ByCategory    []CategoryValuation  `json:"by_category"`
+}
+
+type WarehouseValuation struct {
+	WarehouseID   int64   `json:"warehouse_id"`
+	WarehouseName string  `json:"warehouse_name"`
+	Quantity      int     `json:"quantity"`
+	Value         float64 `json:"value"`
+}
+
+// CategoryValuation groups products by category. Products without a
+// category have a CategoryID of 0.
+type CategoryValuation struct {
+	CategoryID   int64   `json:"category_id"`
+	CategoryName string  `json:"category_name"`
+	Quantity     int     `json:"quantity"`
+	Value        float64 `json:"value"`
+}
+
+// ValuationFilter selects the day of a valuation report. WarehouseIDs is
+// set by the server to limit the report to the warehouses an API key may
+// see.
+type ValuationFilter struct {
+	AsOf         string  `form:"as_of" binding:"omitempty,datetime=2006-01-02"`
+	WarehouseIDs []int64 `form:"-"`
+}
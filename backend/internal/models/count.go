@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+type CountStatus string
+
+const (
+	CountStatusOpen      CountStatus = "open"
+	CountStatusFinalized CountStatus = "finalized"
+	CountStatusCancelled CountStatus = "cancelled"
+)
+
+// CountSession is a physical inventory count (棚卸). While a session is open
+// the stock it covers is locked against movements.
+type CountSession struct {
+	ID          int64       `json:"id"`
+	WarehouseID *int64      `json:"warehouse_id"`
+	CategoryID  *int64      `json:"category_id"`
+	Status      CountStatus `json:"status"`
+	Note        string      `json:"note"`
+	CreatedBy   int64       `json:"created_by"`
+	CreatedAt   time.Time   `json:"created_at"`
+	ClosedAt    *time.Time  `json:"closed_at"`
+	Lines       []CountLine `json:"lines,omitempty"`
+}
+
+type CountLine struct {
+	ID                      int64      `json:"id"`
+	SessionID               int64      `json:"session_id"`
+	ProductID               int64      `json:"product_id"`
+	Product                 *Product   `json:"product,omitempty"`
+	WarehouseID             int64      `json:"warehouse_id"`
+	Warehouse               *Warehouse `json:"warehouse,omitempty"`
+	ExpectedQuantity        int        `json:"expected_quantity"`
+	CountedQuantity         *int       `json:"counted_quantity"`
+	Variance                *int       `json:"variance"`
+	CountedBy               *int64     `json:"counted_by"`
+	CountedAt               *time.Time `json:"counted_at"`
+	AdjustmentTransactionID *int64     `json:"adjustment_transaction_id,omitempty"`
+	// Serials of a serialized product that are missing, for a shortage, or
+	// were found, for a gain
+	Serials []string `json:"serials,omitempty"`
+}
+
+type CreateCountSessionRequest struct {
+	WarehouseID int64  `json:"warehouse_id"`
+	CategoryID  int64  `json:"category_id"`
+	Note        string `json:"note"`
+}
+
+type SubmitCountRequest struct {
+	Lines []CountEntry `json:"lines" binding:"required,min=1,dive"`
+}
+
+type CountEntry struct {
+	ProductID       int64 `json:"product_id" binding:"required"`
+	WarehouseID     int64 `json:"warehouse_id" binding:"required"`
+	CountedQuantity *int  `json:"counted_quantity" binding:"required,min=0"`
+	// Serials names the serial numbers behind the variance of a serialized
+	// product, one for each unit missing or found
+	Serials []string `json:"serials" binding:"omitempty,dive,required"`
+}
+
+type CountFilter struct {
+	Status      string `form:"status"`
+	WarehouseID int64  `form:"warehouse_id"`
+}
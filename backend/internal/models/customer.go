@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+type Customer struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Contact   string    `json:"contact"`
+	Address   string    `json:"address"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateCustomerRequest struct {
+	Name    string `json:"name" binding:"required"`
+	Contact string `json:"contact"`
+	Address string `json:"address"`
+}
+
+type UpdateCustomerRequest struct {
+	Name    string `json:"name"`
+	Contact string `json:"contact"`
+	Address string `json:"address"`
+}
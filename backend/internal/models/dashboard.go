@@ -3,7 +3,8 @@ package models
 type DashboardSummary struct {
 	TotalProducts       int                    `json:"total_products"`
 	TotalWarehouses     int                    `json:"total_warehouses"`
-	TotalStockValue     int                    `json:"total_stock_value"`
+	TotalStockQuantity  int                    `json:"total_stock_quantity"`
+	TotalStockValue     float64                `json:"total_stock_value"`
 	LowStockItems       int                    `json:"low_stock_items"`
 	RecentTransactions  []Transaction          `json:"recent_transactions"`
 	StockByWarehouse    []WarehouseStockSummary `json:"stock_by_warehouse"`
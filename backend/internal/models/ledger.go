@@ -0,0 +1,37 @@
+package models
+
+// LedgerFilter selects the ledger of a product over a date range. From and
+// To (YYYY-MM-DD) are inclusive and open when empty. Without a warehouse the
+// ledger covers all warehouses. WarehouseIDs is set by the server to limit
+// the ledger to the warehouses an API key may see.
+type LedgerFilter struct {
+	ProductID    int64   `form:"product_id" binding:"required"`
+	WarehouseID  int64   `form:"warehouse_id"`
+	From         string  `form:"from" binding:"omitempty,datetime=2006-01-02"`
+	To           string  `form:"to" binding:"omitempty,datetime=2006-01-02"`
+	WarehouseIDs []int64 `form:"-"`
+}
+
+// StockLedger lists the movements of a product with the balance after each
+// one, starting from the balance before the range.
+type StockLedger struct {
+	ProductID       int64         `json:"product_id"`
+	WarehouseID     int64         `json:"warehouse_id,omitempty"`
+	From            string        `json:"from,omitempty"`
+	To              string        `json:"to,omitempty"`
+	OpeningQuantity int           `json:"opening_quantity"`
+	OpeningValue    float64       `json:"opening_value"`
+	ClosingQuantity int           `json:"closing_quantity"`
+	ClosingValue    float64       `json:"closing_value"`
+	Entries         []LedgerEntry `json:"entries"`
+}
+
+// LedgerEntry is a transaction with the signed change it made to the
+// quantity and value on hand and the balances after it.
+type LedgerEntry struct {
+	Transaction
+	Change       int     `json:"change"`
+	ValueChange  float64 `json:"value_change"`
+	Balance      int     `json:"balance"`
+	BalanceValue float64 `json:"balance_value"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// LoginThrottleScope is what failed logins are counted against.
+type LoginThrottleScope string
+
+const (
+	LoginThrottleUsername LoginThrottleScope = "username"
+	LoginThrottleIP       LoginThrottleScope = "ip"
+)
+
+// LoginThrottle counts consecutive failed logins for a username or client
+// IP. No login is attempted for it before LockedUntil.
+type LoginThrottle struct {
+	Scope        LoginThrottleScope
+	Key          string
+	Failures     int
+	LastFailedAt time.Time
+	LockedUntil  *time.Time
+}
+
+type LockoutEventType string
+
+const (
+	LockoutEventLocked   LockoutEventType = "locked"
+	LockoutEventUnlocked LockoutEventType = "unlocked"
+)
+
+// LockoutEvent records a username or IP being locked out after repeated
+// failures, or an admin lifting the lock. UserID is the admin who unlocked.
+type LockoutEvent struct {
+	ID          int64              `json:"id"`
+	Scope       LoginThrottleScope `json:"scope"`
+	Key         string             `json:"key"`
+	Event       LockoutEventType   `json:"event"`
+	Failures    int                `json:"failures"`
+	IPAddress   string             `json:"ip_address"`
+	LockedUntil *time.Time         `json:"locked_until"`
+	UserID      *int64             `json:"user_id"`
+	CreatedAt   time.Time          `json:"created_at"`
+}
+
+type LockoutEventFilter struct {
+	Scope LoginThrottleScope `form:"scope"`
+	Key   string             `form:"key"`
+	Event LockoutEventType   `form:"event"`
+}
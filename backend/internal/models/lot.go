@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// Lot is a batch of a product held in one warehouse. Dates are stored as
+// YYYY-MM-DD strings.
+type Lot struct {
+	ID              int64      `json:"id"`
+	ProductID       int64      `json:"product_id"`
+	Product         *Product   `json:"product,omitempty"`
+	WarehouseID     int64      `json:"warehouse_id"`
+	Warehouse       *Warehouse `json:"warehouse,omitempty"`
+	LotNumber       string     `json:"lot_number"`
+	ManufacturedOn  *string    `json:"manufactured_on"`
+	ExpiresOn       *string    `json:"expires_on"`
+	Quantity        int        `json:"quantity"`
+	DaysUntilExpiry *int       `json:"days_until_expiry,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// LotRequest describes the lot received by a stock-in.
+type LotRequest struct {
+	LotNumber      string `json:"lot_number" binding:"required"`
+	ManufacturedOn string `json:"manufactured_on" binding:"omitempty,datetime=2006-01-02"`
+	ExpiresOn      string `json:"expires_on" binding:"omitempty,datetime=2006-01-02"`
+}
+
+// LotPick selects a quantity from an explicit lot on stock-out.
+type LotPick struct {
+	LotNumber string `json:"lot_number" binding:"required"`
+	Quantity  int    `json:"quantity" binding:"required,min=1"`
+}
+
+// TransactionLot records how much of a transaction came from or went to a lot.
+type TransactionLot struct {
+	LotID     int64  `json:"lot_id"`
+	LotNumber string `json:"lot_number"`
+	Quantity  int    `json:"quantity"`
+}
+
+// LotFilter selects lots. WarehouseIDs is set by the server to limit the
+// result to the warehouses an API key may see.
+type LotFilter struct {
+	ProductID    int64   `form:"product_id"`
+	WarehouseID  int64   `form:"warehouse_id"`
+	WarehouseIDs []int64 `form:"-"`
+}
+
+// ExpiringLotFilter selects lots expiring within Days. WarehouseIDs is set
+// by the server to limit the result to the warehouses an API key may see.
+type ExpiringLotFilter struct {
+	Days         int     `form:"days,default=30" binding:"min=0"`
+	WarehouseID  int64   `form:"warehouse_id"`
+	WarehouseIDs []int64 `form:"-"`
+}
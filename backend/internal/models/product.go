@@ -10,6 +10,7 @@ type Product struct {
 	CategoryID  int64     `json:"category_id"`
 	Category    *Category `json:"category,omitempty"`
 	Unit        string    `json:"unit"`
+	Serialized  bool      `json:"serialized"`
 	CreatedAt   time.Time `json:"created_at"`
 }
 
@@ -19,6 +20,7 @@ type CreateProductRequest struct {
 	Description string `json:"description"`
 	CategoryID  int64  `json:"category_id"`
 	Unit        string `json:"unit" binding:"required"`
+	Serialized  bool   `json:"serialized"`
 }
 
 type UpdateProductRequest struct {
@@ -27,6 +29,7 @@ type UpdateProductRequest struct {
 	Description string `json:"description"`
 	CategoryID  int64  `json:"category_id"`
 	Unit        string `json:"unit"`
+	Serialized  *bool  `json:"serialized"`
 }
 
 type ProductFilter struct {
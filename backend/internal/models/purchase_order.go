@@ -0,0 +1,73 @@
+package models
+
+import "time"
+
+type PurchaseOrderStatus string
+
+const (
+	PurchaseOrderStatusDraft             PurchaseOrderStatus = "draft"
+	PurchaseOrderStatusOrdered           PurchaseOrderStatus = "ordered"
+	PurchaseOrderStatusPartiallyReceived PurchaseOrderStatus = "partially_received"
+	PurchaseOrderStatusClosed            PurchaseOrderStatus = "closed"
+)
+
+type PurchaseOrder struct {
+	ID           int64               `json:"id"`
+	SupplierID   int64               `json:"supplier_id"`
+	Supplier     *Supplier           `json:"supplier,omitempty"`
+	WarehouseID  int64               `json:"warehouse_id"`
+	Warehouse    *Warehouse          `json:"warehouse,omitempty"`
+	Status       PurchaseOrderStatus `json:"status"`
+	ExpectedDate *string             `json:"expected_date"`
+	Note         string              `json:"note"`
+	CreatedBy    int64               `json:"created_by"`
+	CreatedAt    time.Time           `json:"created_at"`
+	OrderedAt    *time.Time          `json:"ordered_at"`
+	ClosedAt     *time.Time          `json:"closed_at"`
+	Lines        []PurchaseOrderLine `json:"lines,omitempty"`
+}
+
+// PurchaseOrderLine is a product ordered on a purchase order. UnitCost is
+// nil when receipts come in at the default unit cost.
+type PurchaseOrderLine struct {
+	ID                  int64    `json:"id"`
+	PurchaseOrderID     int64    `json:"purchase_order_id"`
+	ProductID           int64    `json:"product_id"`
+	Product             *Product `json:"product,omitempty"`
+	Quantity            int      `json:"quantity"`
+	ReceivedQuantity    int      `json:"received_quantity"`
+	OutstandingQuantity int      `json:"outstanding_quantity"`
+	UnitCost            *float64 `json:"unit_cost"`
+}
+
+type CreatePurchaseOrderRequest struct {
+	SupplierID   int64                      `json:"supplier_id" binding:"required"`
+	WarehouseID  int64                      `json:"warehouse_id" binding:"required"`
+	ExpectedDate string                     `json:"expected_date" binding:"omitempty,datetime=2006-01-02"`
+	Note         string                     `json:"note"`
+	Lines        []PurchaseOrderLineRequest `json:"lines" binding:"required,min=1,dive"`
+}
+
+type PurchaseOrderLineRequest struct {
+	ProductID int64    `json:"product_id" binding:"required"`
+	Quantity  int      `json:"quantity" binding:"required,min=1"`
+	UnitCost  *float64 `json:"unit_cost" binding:"omitempty,min=0"`
+}
+
+// ReceivePurchaseOrderRequest books delivered quantities against PO lines.
+type ReceivePurchaseOrderRequest struct {
+	Lines []ReceiptLineRequest `json:"lines" binding:"required,min=1,dive"`
+	Note  string               `json:"note"`
+}
+
+type ReceiptLineRequest struct {
+	LineID   int64       `json:"line_id" binding:"required"`
+	Quantity int         `json:"quantity" binding:"required,min=1"`
+	Lot      *LotRequest `json:"lot"`
+	Serials  []string    `json:"serials" binding:"omitempty,dive,required"`
+}
+
+type PurchaseOrderFilter struct {
+	Status     string `form:"status"`
+	SupplierID int64  `form:"supplier_id"`
+}
@@ -0,0 +1,46 @@
+package models
+
+// ReorderSetting holds the stock levels of a product. A setting without a
+// warehouse is the product default; warehouse settings override it.
+// MinQuantity is the minimum order quantity: low stock is never suggested
+// to be reordered in smaller amounts.
+type ReorderSetting struct {
+	ID           int64  `json:"id"`
+	ProductID    int64  `json:"product_id"`
+	WarehouseID  *int64 `json:"warehouse_id"`
+	MinQuantity  int    `json:"min_quantity"`
+	MaxQuantity  int    `json:"max_quantity"`
+	ReorderPoint int    `json:"reorder_point"`
+}
+
+type ReorderSettingRequest struct {
+	WarehouseID  int64 `json:"warehouse_id"`
+	MinQuantity  int   `json:"min_quantity" binding:"min=0"`
+	MaxQuantity  int   `json:"max_quantity" binding:"min=0"`
+	ReorderPoint int   `json:"reorder_point" binding:"min=0"`
+}
+
+// LowStockItem is a stock row below its reorder point.
+type LowStockItem struct {
+	ProductID    int64      `json:"product_id"`
+	Product      *Product   `json:"product,omitempty"`
+	WarehouseID  int64      `json:"warehouse_id"`
+	Warehouse    *Warehouse `json:"warehouse,omitempty"`
+	Quantity     int        `json:"quantity"`
+	MinQuantity  int        `json:"min_quantity"`
+	MaxQuantity  int        `json:"max_quantity"`
+	ReorderPoint int        `json:"reorder_point"`
+	// Shortfall is how far the quantity is below the reorder point
+	Shortfall int `json:"shortfall"`
+	// SuggestedOrderQuantity refills up to the maximum, or to the reorder
+	// point when no maximum is set, but is at least the minimum quantity
+	SuggestedOrderQuantity int `json:"suggested_order_quantity"`
+}
+
+// LowStockFilter selects low stock. WarehouseIDs is set by the server to
+// limit the result to the warehouses an API key may see.
+type LowStockFilter struct {
+	ProductID    int64   `form:"product_id"`
+	WarehouseID  int64   `form:"warehouse_id"`
+	WarehouseIDs []int64 `form:"-"`
+}
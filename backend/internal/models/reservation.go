@@ -0,0 +1,47 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Reservation holds stock for an owner without moving it. It stops counting
+// once it is released, fully consumed or past its expiry.
+type Reservation struct {
+	ID          int64      `json:"id"`
+	ProductID   int64      `json:"product_id"`
+	Product     *Product   `json:"product,omitempty"`
+	WarehouseID int64      `json:"warehouse_id"`
+	Warehouse   *Warehouse `json:"warehouse,omitempty"`
+	Quantity    int        `json:"quantity"`
+	OwnerRef    string     `json:"owner_ref"`
+	Note        string     `json:"note"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	CreatedBy   int64      `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ReleasedAt  *time.Time `json:"released_at"`
+	Active      bool       `json:"active"`
+}
+
+type CreateReservationRequest struct {
+	ProductID   int64      `json:"product_id" binding:"required"`
+	WarehouseID int64      `json:"warehouse_id" binding:"required"`
+	Quantity    int        `json:"quantity" binding:"required,min=1"`
+	OwnerRef    string     `json:"owner_ref" binding:"required"`
+	Note        string     `json:"note"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+type ReservationFilter struct {
+	ProductID   int64  `form:"product_id"`
+	WarehouseID int64  `form:"warehouse_id"`
+	OwnerRef    string `form:"owner_ref"`
+	// All includes released and expired reservations
+	All bool `form:"all"`
+}
+
+// SalesOrderOwnerRef is the owner reference under which a sales order holds
+// its allocated stock.
+func SalesOrderOwnerRef(salesOrderID int64) string {
+	return fmt.Sprintf("sales_order:%d", salesOrderID)
+}
@@ -0,0 +1,50 @@
+package models
+
+type Role string
+
+const (
+	RoleAdmin    Role = "admin"
+	RoleManager  Role = "manager"
+	RoleOperator Role = "operator"
+	RoleViewer   Role = "viewer"
+)
+
+// Permission names an action that route groups require.
+type Permission string
+
+const (
+	// PermissionRead allows viewing all inventory data
+	PermissionRead Permission = "read"
+	// PermissionStockWrite allows stock movements, counts, reservations and
+	// order processing
+	PermissionStockWrite Permission = "stock:write"
+	// PermissionMasterWrite allows creating and updating master data
+	PermissionMasterWrite Permission = "master:write"
+	// PermissionMasterDelete allows deleting master data
+	PermissionMasterDelete Permission = "master:delete"
+	// PermissionUserManage allows managing user accounts
+	PermissionUserManage Permission = "users:manage"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleViewer:   {PermissionRead},
+	RoleOperator: {PermissionRead, PermissionStockWrite, PermissionMasterWrite},
+	RoleManager:  {PermissionRead, PermissionStockWrite, PermissionMasterWrite, PermissionMasterDelete},
+	RoleAdmin:    {PermissionRead, PermissionStockWrite, PermissionMasterWrite, PermissionMasterDelete, PermissionUserManage},
+}
+
+// Valid reports whether r is a known role.
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Can reports whether the role grants the permission.
+func (r Role) Can(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
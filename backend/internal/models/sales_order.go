@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+type SalesOrderStatus string
+
+const (
+	SalesOrderStatusDraft            SalesOrderStatus = "draft"
+	SalesOrderStatusConfirmed        SalesOrderStatus = "confirmed"
+	SalesOrderStatusPartiallyShipped SalesOrderStatus = "partially_shipped"
+	SalesOrderStatusShipped          SalesOrderStatus = "shipped"
+	SalesOrderStatusCancelled        SalesOrderStatus = "cancelled"
+)
+
+type SalesOrder struct {
+	ID          int64            `json:"id"`
+	CustomerID  int64            `json:"customer_id"`
+	Customer    *Customer        `json:"customer,omitempty"`
+	WarehouseID int64            `json:"warehouse_id"`
+	Warehouse   *Warehouse       `json:"warehouse,omitempty"`
+	Status      SalesOrderStatus `json:"status"`
+	Note        string           `json:"note"`
+	CreatedBy   int64            `json:"created_by"`
+	CreatedAt   time.Time        `json:"created_at"`
+	ConfirmedAt *time.Time       `json:"confirmed_at"`
+	ClosedAt    *time.Time       `json:"closed_at"`
+	Lines       []SalesOrderLine `json:"lines,omitempty"`
+}
+
+// SalesOrderLine tracks how much of the ordered quantity is allocated from
+// stock and how much has shipped. The unallocated rest is backordered.
+type SalesOrderLine struct {
+	ID                  int64    `json:"id"`
+	SalesOrderID        int64    `json:"sales_order_id"`
+	ProductID           int64    `json:"product_id"`
+	Product             *Product `json:"product,omitempty"`
+	Quantity            int      `json:"quantity"`
+	AllocatedQuantity   int      `json:"allocated_quantity"`
+	ShippedQuantity     int      `json:"shipped_quantity"`
+	BackorderedQuantity int      `json:"backordered_quantity"`
+	UnitPrice           float64  `json:"unit_price"`
+}
+
+type CreateSalesOrderRequest struct {
+	CustomerID  int64                   `json:"customer_id" binding:"required"`
+	WarehouseID int64                   `json:"warehouse_id" binding:"required"`
+	Note        string                  `json:"note"`
+	Lines       []SalesOrderLineRequest `json:"lines" binding:"required,min=1,dive"`
+}
+
+type SalesOrderLineRequest struct {
+	ProductID int64   `json:"product_id" binding:"required"`
+	Quantity  int     `json:"quantity" binding:"required,min=1"`
+	UnitPrice float64 `json:"unit_price" binding:"min=0"`
+}
+
+// ShipSalesOrderRequest ships allocated quantities of the order's lines.
+type ShipSalesOrderRequest struct {
+	Lines []ShipmentLineRequest `json:"lines" binding:"required,min=1,dive"`
+	Note  string                `json:"note"`
+}
+
+type ShipmentLineRequest struct {
+	LineID   int64     `json:"line_id" binding:"required"`
+	Quantity int       `json:"quantity" binding:"required,min=1"`
+	Lots     []LotPick `json:"lots" binding:"omitempty,dive"`
+	Serials  []string  `json:"serials" binding:"omitempty,dive,required"`
+}
+
+type SalesOrderFilter struct {
+	Status     string `form:"status"`
+	CustomerID int64  `form:"customer_id"`
+	ProductID  int64  `form:"product_id"`
+}
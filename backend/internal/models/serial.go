@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+type SerialStatus string
+
+const (
+	SerialStatusInStock SerialStatus = "in_stock"
+	SerialStatusShipped SerialStatus = "shipped"
+)
+
+// SerialNumber is one unit of a serialized product. WarehouseID is the
+// current location and is nil once the unit has been shipped.
+type SerialNumber struct {
+	ID           int64        `json:"id"`
+	ProductID    int64        `json:"product_id"`
+	Product      *Product     `json:"product,omitempty"`
+	SerialNumber string       `json:"serial_number"`
+	WarehouseID  *int64       `json:"warehouse_id"`
+	Status       SerialStatus `json:"status"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+}
+
+// SerialHistory is a serial number with every transaction that moved it.
+type SerialHistory struct {
+	SerialNumber
+	Movements []Transaction `json:"movements"`
+}
@@ -2,6 +2,11 @@ package models
 
 import "time"
 
+// Stock is the quantity of a product held in a warehouse. Quantity and
+// OnHand are the same physical quantity; Reserved is held by reservations and
+// sales order allocations, and Available is what is left for anyone else.
+// Allocated is the sales order part of Reserved; Backordered is ordered but
+// could not be allocated. Value is the cost of the quantity on hand.
 type Stock struct {
 	ID          int64      `json:"id"`
 	ProductID   int64      `json:"product_id"`
@@ -9,13 +14,24 @@ type Stock struct {
 	WarehouseID int64      `json:"warehouse_id"`
 	Warehouse   *Warehouse `json:"warehouse,omitempty"`
 	Quantity    int        `json:"quantity"`
+	Value       float64    `json:"value"`
+	OnHand      int        `json:"on_hand"`
+	Reserved    int        `json:"reserved"`
+	Available   int        `json:"available"`
+	Allocated   int        `json:"allocated"`
+	Backordered int        `json:"backordered"`
 	UpdatedAt   time.Time  `json:"updated_at"`
 }
 
+// StockFilter selects stock. AsOf (YYYY-MM-DD) asks for the stock at the
+// end of that day instead of now. WarehouseIDs is set by the server to
+// limit the result to the warehouses an API key may see.
 type StockFilter struct {
-	ProductID   int64  `form:"product_id"`
-	WarehouseID int64  `form:"warehouse_id"`
-	Search      string `form:"search"`
+	ProductID    int64   `form:"product_id"`
+	WarehouseID  int64   `form:"warehouse_id"`
+	Search       string  `form:"search"`
+	AsOf         string  `form:"as_of" binding:"omitempty,datetime=2006-01-02"`
+	WarehouseIDs []int64 `form:"-"`
 }
 
 type StockMovementRequest struct {
@@ -23,4 +39,25 @@ type StockMovementRequest struct {
 	WarehouseID int64  `json:"warehouse_id" binding:"required"`
 	Quantity    int    `json:"quantity" binding:"required,min=1"`
 	Note        string `json:"note"`
+	// UnitCost is the cost per unit of a stock-in. When it is not given the
+	// stock is received at the current average cost of the product
+	UnitCost *float64 `json:"unit_cost" binding:"omitempty,min=0"`
+	// Lot is the lot received by a stock-in
+	Lot *LotRequest `json:"lot"`
+	// Lots picks explicit lots on stock-out; FEFO allocation is used when empty
+	Lots []LotPick `json:"lots" binding:"omitempty,dive"`
+	// Serials lists the moved units of a serialized product, one per quantity
+	Serials []string `json:"serials" binding:"omitempty,dive,required"`
+	// OwnerRef lets a stock-out consume stock reserved under that reference
+	OwnerRef string `json:"owner_ref"`
+}
+
+type StockTransferRequest struct {
+	ProductID       int64     `json:"product_id" binding:"required"`
+	FromWarehouseID int64     `json:"from_warehouse_id" binding:"required"`
+	ToWarehouseID   int64     `json:"to_warehouse_id" binding:"required,nefield=FromWarehouseID"`
+	Quantity        int       `json:"quantity" binding:"required,min=1"`
+	Note            string    `json:"note"`
+	Lots            []LotPick `json:"lots" binding:"omitempty,dive"`
+	Serials         []string  `json:"serials" binding:"omitempty,dive,required"`
 }
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+type Supplier struct {
+	ID           int64     `json:"id"`
+	Name         string    `json:"name"`
+	Contact      string    `json:"contact"`
+	LeadTimeDays int       `json:"lead_time_days"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type CreateSupplierRequest struct {
+	Name         string `json:"name" binding:"required"`
+	Contact      string `json:"contact"`
+	LeadTimeDays int    `json:"lead_time_days" binding:"min=0"`
+}
+
+type UpdateSupplierRequest struct {
+	Name         string `json:"name"`
+	Contact      string `json:"contact"`
+	LeadTimeDays *int   `json:"lead_time_days" binding:"omitempty,min=0"`
+}
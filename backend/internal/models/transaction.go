@@ -7,25 +7,89 @@ type TransactionType string
 const (
 	TransactionTypeIn  TransactionType = "in"
 	TransactionTypeOut TransactionType = "out"
+	// TransactionTypeTransfer is one leg of an inter-warehouse transfer.
+	// Its quantity is signed: negative at the source, positive at the destination.
+	TransactionTypeTransfer TransactionType = "transfer"
+	// TransactionTypeAdjustment corrects the on-hand quantity, e.g. after an
+	// inventory count. Its quantity is signed.
+	TransactionTypeAdjustment TransactionType = "adjustment"
 )
 
+// Transaction is a stock movement. TotalCost is the cost of the moved
+// quantity and carries the same sign as Quantity, so for an "out" it is the
+// cost of goods issued; UnitCost is TotalCost per unit.
+//
+// A reversal has the type of the transaction it reverses (ReversalOfID)
+// with the quantity and cost negated, so the two cancel out in every sum.
+// ReversedByID is set on a transaction that has been reversed.
 type Transaction struct {
-	ID          int64           `json:"id"`
-	ProductID   int64           `json:"product_id"`
-	Product     *Product        `json:"product,omitempty"`
-	WarehouseID int64           `json:"warehouse_id"`
-	Warehouse   *Warehouse      `json:"warehouse,omitempty"`
-	Type        TransactionType `json:"type"`
-	Quantity    int             `json:"quantity"`
-	Note        string          `json:"note"`
-	UserID      int64           `json:"user_id"`
-	User        *User           `json:"user,omitempty"`
-	CreatedAt   time.Time       `json:"created_at"`
+	ID                   int64             `json:"id"`
+	ProductID            int64             `json:"product_id"`
+	Product              *Product          `json:"product,omitempty"`
+	WarehouseID          int64             `json:"warehouse_id"`
+	Warehouse            *Warehouse        `json:"warehouse,omitempty"`
+	Type                 TransactionType   `json:"type"`
+	Quantity             int               `json:"quantity"`
+	UnitCost             float64           `json:"unit_cost"`
+	TotalCost            float64           `json:"total_cost"`
+	Note                 string            `json:"note"`
+	ReasonID             *int64            `json:"reason_id,omitempty"`
+	Reason               *AdjustmentReason `json:"reason,omitempty"`
+	UserID               int64             `json:"user_id"`
+	User                 *User             `json:"user,omitempty"`
+	RelatedTransactionID *int64            `json:"related_transaction_id,omitempty"`
+	ReversalOfID         *int64            `json:"reversal_of,omitempty"`
+	ReversedByID         *int64            `json:"reversed_by,omitempty"`
+	Lots                 []TransactionLot  `json:"lots,omitempty"`
+	Serials              []string          `json:"serials,omitempty"`
+	CreatedAt            time.Time         `json:"created_at"`
 }
 
+// StockAdjustmentRequest corrects the quantity on hand for a reason. Either
+// Quantity, the signed change, or TargetQuantity, the quantity that should
+// be on hand afterwards, must be given.
+type StockAdjustmentRequest struct {
+	ProductID      int64  `json:"product_id" binding:"required"`
+	WarehouseID    int64  `json:"warehouse_id" binding:"required"`
+	Quantity       *int   `json:"quantity"`
+	TargetQuantity *int   `json:"target_quantity" binding:"omitempty,min=0"`
+	ReasonID       int64  `json:"reason_id" binding:"required"`
+	Note           string `json:"note"`
+	// UnitCost is the cost per unit of a gain; the current average cost of
+	// the product is used when it is not given
+	UnitCost *float64 `json:"unit_cost" binding:"omitempty,min=0"`
+	// Lot is the lot a gain is booked into
+	Lot *LotRequest `json:"lot"`
+	// Lots picks explicit lots for a loss; FEFO allocation is used when empty
+	Lots []LotPick `json:"lots" binding:"omitempty,dive"`
+	// Serials lists the adjusted units of a serialized product
+	Serials []string `json:"serials" binding:"omitempty,dive,required"`
+}
+
+// TransactionFilter selects transactions. WarehouseIDs is set by the
+// server to limit the result to the warehouses an API key may see.
 type TransactionFilter struct {
-	ProductID   int64  `form:"product_id"`
-	WarehouseID int64  `form:"warehouse_id"`
-	Type        string `form:"type"`
-	Limit       int    `form:"limit"`
+	ProductID    int64   `form:"product_id"`
+	WarehouseID  int64   `form:"warehouse_id"`
+	Type         string  `form:"type"`
+	ReasonID     int64   `form:"reason_id"`
+	Limit        int     `form:"limit"`
+	WarehouseIDs []int64 `form:"-"`
+}
+
+// SignedQuantity is the change the transaction made to the quantity on
+// hand. "out" rows store a positive quantity; the other types are signed.
+func (t *Transaction) SignedQuantity() int {
+	if t.Type == TransactionTypeOut {
+		return -t.Quantity
+	}
+	return t.Quantity
+}
+
+// SignedCost is the change the transaction made to the value on hand.
+func (t *Transaction) SignedCost() float64 {
+	if t.Type == TransactionTypeOut {
+		return -t.TotalCost
+	}
+	return t.TotalCost
 }
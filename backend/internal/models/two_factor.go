@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// TwoFactorSetup is a new TOTP secret waiting to be confirmed with a code.
+type TwoFactorSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// RecoveryCodesResponse lists recovery codes. They are only shown once;
+// each can replace a TOTP code a single time.
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// TwoFactorChallenge is the pending login of a user who has passed the
+// password check but not yet entered a code.
+type TwoFactorChallenge struct {
+	ID          int64
+	UserID      int64
+	Attempts    int
+	ExpiresAt   time.Time
+	CompletedAt *time.Time
+}
+
+// TwoFactorChallengeResponse is returned by login instead of a
+// LoginResponse when the user has two-factor authentication enabled.
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	Challenge         string `json:"challenge"`
+	ExpiresIn         int    `json:"expires_in"`
+}
+
+// TwoFactorCodeRequest carries a TOTP code or a recovery code.
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorVerifyRequest struct {
+	Challenge string `json:"challenge" binding:"required"`
+	Code      string `json:"code" binding:"required"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
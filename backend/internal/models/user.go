@@ -2,11 +2,21 @@ package models
 
 import "time"
 
+// User is an account. TOTPSecret is set once two-factor setup has started;
+// it is only used at login after the setup is confirmed and
+// TwoFactorEnabled is set. TOTPLastStep is the time step of the last
+// accepted code, which cannot be used again.
 type User struct {
-	ID           int64     `json:"id"`
-	Username     string    `json:"username"`
-	PasswordHash string    `json:"-"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID                 int64     `json:"id"`
+	Username           string    `json:"username"`
+	PasswordHash       string    `json:"-"`
+	Role               Role      `json:"role"`
+	Disabled           bool      `json:"disabled"`
+	MustChangePassword bool      `json:"must_change_password"`
+	TwoFactorEnabled   bool      `json:"two_factor_enabled"`
+	TOTPSecret         string    `json:"-"`
+	TOTPLastStep       int64     `json:"-"`
+	CreatedAt          time.Time `json:"created_at"`
 }
 
 type LoginRequest struct {
@@ -14,7 +24,28 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// LoginResponse carries a short-lived access token, valid for ExpiresIn
+// seconds, and the refresh token that obtains the next one.
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	User         User   `json:"user"`
+}
+
+type CreateUserRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+	Role     Role   `json:"role" binding:"required,oneof=admin manager operator viewer"`
+}
+
+// ResetPasswordRequest sets a temporary password that the user must change
+// at the next login.
+type ResetPasswordRequest struct {
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8,nefield=CurrentPassword"`
 }
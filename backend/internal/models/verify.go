@@ -0,0 +1,30 @@
+package models
+
+// StockMismatch is a (product, warehouse) pair whose quantity in the stock
+// table differs from the sum of its transactions. Difference is the stock
+// quantity less the ledger quantity. Orphaned rows refer to a product or
+// warehouse that no longer exists and cannot be repaired by a transaction.
+type StockMismatch struct {
+	ProductID      int64   `json:"product_id"`
+	ProductCode    string  `json:"product_code"`
+	ProductName    string  `json:"product_name"`
+	WarehouseID    int64   `json:"warehouse_id"`
+	WarehouseName  string  `json:"warehouse_name"`
+	StockQuantity  int     `json:"stock_quantity"`
+	LedgerQuantity int     `json:"ledger_quantity"`
+	Difference     int     `json:"difference"`
+	StockValue     float64 `json:"stock_value"`
+	LedgerValue    float64 `json:"ledger_value"`
+	Orphaned       bool    `json:"orphaned,omitempty"`
+	TransactionID  *int64  `json:"transaction_id,omitempty"`
+}
+
+// VerifyReport lists the mismatches between stock and the ledger. Each
+// repaired mismatch carries the adjustment transaction that was posted for
+// it; the others, including all of them when no repair was asked for, count
+// as unrepaired.
+type VerifyReport struct {
+	Mismatches []StockMismatch `json:"mismatches"`
+	Repaired   int             `json:"repaired"`
+	Unrepaired int             `json:"unrepaired"`
+}
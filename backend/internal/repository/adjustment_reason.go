@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+)
+
+type AdjustmentReasonRepository struct {
+	db *database.DB
+	tx *sql.Tx
+}
+
+func NewAdjustmentReasonRepository(db *database.DB) *AdjustmentReasonRepository {
+	return &AdjustmentReasonRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *AdjustmentReasonRepository) WithTx(tx *sql.Tx) *AdjustmentReasonRepository {
+	return &AdjustmentReasonRepository{db: r.db, tx: tx}
+}
+
+func (r *AdjustmentReasonRepository) FindAll() ([]models.AdjustmentReason, error) {
+	rows, err := conn(r.db, r.tx).Query("SELECT id, code, name FROM adjustment_reasons ORDER BY code")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reasons []models.AdjustmentReason
+	for rows.Next() {
+		var reason models.AdjustmentReason
+		if err := rows.Scan(&reason.ID, &reason.Code, &reason.Name); err != nil {
+			return nil, err
+		}
+		reasons = append(reasons, reason)
+	}
+
+	return reasons, nil
+}
+
+func (r *AdjustmentReasonRepository) FindByID(id int64) (*models.AdjustmentReason, error) {
+	reason := &models.AdjustmentReason{}
+	err := conn(r.db, r.tx).QueryRow(
+		"SELECT id, code, name FROM adjustment_reasons WHERE id = ?",
+		id,
+	).Scan(&reason.ID, &reason.Code, &reason.Name)
+
+	if err != nil {
+		return nil, err
+	}
+	return reason, nil
+}
+
+func (r *AdjustmentReasonRepository) FindByCode(code string) (*models.AdjustmentReason, error) {
+	reason := &models.AdjustmentReason{}
+	err := conn(r.db, r.tx).QueryRow(
+		"SELECT id, code, name FROM adjustment_reasons WHERE code = ?",
+		code,
+	).Scan(&reason.ID, &reason.Code, &reason.Name)
+
+	if err != nil {
+		return nil, err
+	}
+	return reason, nil
+}
+
+func (r *AdjustmentReasonRepository) Create(req models.CreateAdjustmentReasonRequest) (*models.AdjustmentReason, error) {
+	var id int64
+	err := conn(r.db, r.tx).QueryRow(
+		"INSERT INTO adjustment_reasons (code, name) VALUES (?, ?) RETURNING id",
+		req.Code, req.Name,
+	).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AdjustmentReason{ID: id, Code: req.Code, Name: req.Name}, nil
+}
+
+func (r *AdjustmentReasonRepository) Update(id int64, req models.UpdateAdjustmentReasonRequest) (*models.AdjustmentReason, error) {
+	var updates []string
+	var args []interface{}
+
+	if req.Code != "" {
+		updates = append(updates, "code = ?")
+		args = append(args, req.Code)
+	}
+	if req.Name != "" {
+		updates = append(updates, "name = ?")
+		args = append(args, req.Name)
+	}
+
+	if len(updates) == 0 {
+		return r.FindByID(id)
+	}
+
+	args = append(args, id)
+	query := fmt.Sprintf("UPDATE adjustment_reasons SET %s WHERE id = ?", strings.Join(updates, ", "))
+
+	if _, err := conn(r.db, r.tx).Exec(query, args...); err != nil {
+		return nil, err
+	}
+
+	return r.FindByID(id)
+}
+
+// adjustmentReasonReferences are the rows that keep a reason from being
+// deleted.
+var adjustmentReasonReferences = []reference{
+	{"transactions", "reason_id"},
+}
+
+// Delete removes a reason. It returns an *InUseError if adjustments were
+// recorded with it.
+func (r *AdjustmentReasonRepository) Delete(id int64) error {
+	return deleteUnreferenced(r.db, r.tx, "adjustment_reasons", id, adjustmentReasonReferences)
+}
@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"time"
+
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+)
+
+type APIKeyRepository struct {
+	db *database.DB
+	tx *sql.Tx
+}
+
+func NewAPIKeyRepository(db *database.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *APIKeyRepository) WithTx(tx *sql.Tx) *APIKeyRepository {
+	return &APIKeyRepository{db: r.db, tx: tx}
+}
+
+// hashAPIKey returns the hash under which a key is stored. Keys are random
+// with 256 bits of entropy, so a fast hash is sufficient.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+const apiKeyQuery = `
+	SELECT k.id, k.user_id, u.username, k.name, k.prefix, k.expires_at, k.last_used_at, k.created_at, k.revoked_at
+	FROM api_keys k
+	JOIN users u ON k.user_id = u.id
+`
+
+func (r *APIKeyRepository) scan(scanner interface{ Scan(...interface{}) error }) (*models.APIKey, error) {
+	var k models.APIKey
+	if err := scanner.Scan(
+		&k.ID, &k.UserID, &k.Username, &k.Name, &k.Prefix, &k.ExpiresAt, &k.LastUsedAt, &k.CreatedAt, &k.RevokedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// loadScopes fills in the permissions and warehouses of the keys.
+func (r *APIKeyRepository) loadScopes(keys []*models.APIKey) error {
+	for _, k := range keys {
+		k.Permissions = []models.Permission{}
+		k.WarehouseIDs = []int64{}
+
+		rows, err := conn(r.db, r.tx).Query("SELECT permission FROM api_key_permissions WHERE api_key_id = ? ORDER BY permission", k.ID)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var p models.Permission
+			if err := rows.Scan(&p); err != nil {
+				rows.Close()
+				return err
+			}
+			k.Permissions = append(k.Permissions, p)
+		}
+		rows.Close()
+
+		rows, err = conn(r.db, r.tx).Query("SELECT warehouse_id FROM api_key_warehouses WHERE api_key_id = ? ORDER BY warehouse_id", k.ID)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			k.WarehouseIDs = append(k.WarehouseIDs, id)
+		}
+		rows.Close()
+	}
+	return nil
+}
+
+// FindAll lists the keys of a user, or of all users when userID is 0.
+func (r *APIKeyRepository) FindAll(userID int64) ([]models.APIKey, error) {
+	query := apiKeyQuery + " WHERE 1=1"
+	var args []interface{}
+
+	if userID > 0 {
+		query += " AND k.user_id = ?"
+		args = append(args, userID)
+	}
+
+	query += " ORDER BY k.created_at DESC, k.id DESC"
+
+	rows, err := conn(r.db, r.tx).Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*models.APIKey
+	for rows.Next() {
+		k, err := r.scan(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	rows.Close()
+
+	if err := r.loadScopes(keys); err != nil {
+		return nil, err
+	}
+
+	result := make([]models.APIKey, len(keys))
+	for i, k := range keys {
+		result[i] = *k
+	}
+	return result, nil
+}
+
+func (r *APIKeyRepository) FindByID(id int64) (*models.APIKey, error) {
+	k, err := r.scan(conn(r.db, r.tx).QueryRow(apiKeyQuery+" WHERE k.id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	if err := r.loadScopes([]*models.APIKey{k}); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// FindByKey looks up a key by its plaintext value.
+func (r *APIKeyRepository) FindByKey(key string) (*models.APIKey, error) {
+	k, err := r.scan(conn(r.db, r.tx).QueryRow(apiKeyQuery+" WHERE k.key_hash = ?", hashAPIKey(key)))
+	if err != nil {
+		return nil, err
+	}
+	if err := r.loadScopes([]*models.APIKey{k}); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (r *APIKeyRepository) Create(userID int64, req models.CreateAPIKeyRequest, key, prefix string) (int64, error) {
+	var expiresAt interface{}
+	if req.ExpiresAt != nil {
+		expiresAt = req.ExpiresAt.UTC().Format(time.DateTime)
+	}
+
+	var id int64
+	err := inTx(r.db, r.tx, func(tx *sql.Tx) error {
+		err := tx.QueryRow(`
+			INSERT INTO api_keys (user_id, name, prefix, key_hash, expires_at)
+			VALUES (?, ?, ?, ?, ?)
+			RETURNING id
+		`, userID, req.Name, prefix, hashAPIKey(key), expiresAt).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range req.Permissions {
+			if _, err := tx.Exec("INSERT INTO api_key_permissions (api_key_id, permission) VALUES (?, ?) ON CONFLICT DO NOTHING", id, p); err != nil {
+				return err
+			}
+		}
+		for _, w := range req.WarehouseIDs {
+			if _, err := tx.Exec("INSERT INTO api_key_warehouses (api_key_id, warehouse_id) VALUES (?, ?) ON CONFLICT DO NOTHING", id, w); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// Revoke revokes a key. It reports whether the key was still active.
+func (r *APIKeyRepository) Revoke(id int64) (bool, error) {
+	result, err := conn(r.db, r.tx).Exec(
+		"UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = ? AND revoked_at IS NULL",
+		id,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// Touch records that a key was used. It writes at most once a minute per
+// key so that busy clients do not write on every request.
+func (r *APIKeyRepository) Touch(id int64) error {
+	_, err := conn(r.db, r.tx).Exec(`
+		UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)
+	`, id, time.Now().Add(-time.Minute).UTC().Format(time.DateTime))
+	return err
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+)
+
+type AuditRepository struct {
+	db *database.DB
+	tx *sql.Tx
+}
+
+func NewAuditRepository(db *database.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *AuditRepository) WithTx(tx *sql.Tx) *AuditRepository {
+	return &AuditRepository{db: r.db, tx: tx}
+}
+
+func (r *AuditRepository) Create(e *models.AuditEntry) error {
+	var before, after interface{}
+	if e.Before != nil {
+		before = string(e.Before)
+	}
+	if e.After != nil {
+		after = string(e.After)
+	}
+
+	_, err := conn(r.db, r.tx).Exec(`
+		INSERT INTO audit_log (actor_id, api_key_id, action, entity_type, entity_id, before_json, after_json, ip_address)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, e.ActorID, e.APIKeyID, e.Action, e.EntityType, e.EntityID, before, after, e.IPAddress)
+	return err
+}
+
+func (r *AuditRepository) FindAll(filter models.AuditFilter) ([]models.AuditEntry, error) {
+	query := `
+		SELECT a.id, a.actor_id, COALESCE(u.username, ''), a.api_key_id, a.action, a.entity_type, a.entity_id,
+		       a.before_json, a.after_json, a.ip_address, a.created_at
+		FROM audit_log a
+		LEFT JOIN users u ON a.actor_id = u.id
+		WHERE 1=1
+	`
+	var args []interface{}
+
+	if filter.EntityType != "" {
+		query += " AND a.entity_type = ?"
+		args = append(args, filter.EntityType)
+	}
+
+	if filter.EntityID > 0 {
+		query += " AND a.entity_id = ?"
+		args = append(args, filter.EntityID)
+	}
+
+	if filter.ActorID > 0 {
+		query += " AND a.actor_id = ?"
+		args = append(args, filter.ActorID)
+	}
+
+	if filter.Action != "" {
+		query += " AND a.action = ?"
+		args = append(args, filter.Action)
+	}
+
+	if filter.From != "" {
+		query += " AND a.created_at >= ?"
+		args = append(args, filter.From)
+	}
+
+	if filter.To != "" {
+		to, err := time.Parse(time.DateOnly, filter.To)
+		if err != nil {
+			return nil, err
+		}
+		query += " AND a.created_at < ?"
+		args = append(args, to.AddDate(0, 0, 1).Format(time.DateOnly))
+	}
+
+	query += " ORDER BY a.created_at DESC, a.id DESC"
+
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := conn(r.db, r.tx).Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.AuditEntry
+	for rows.Next() {
+		var e models.AuditEntry
+		var before, after, ipAddress *string
+		if err := rows.Scan(
+			&e.ID, &e.ActorID, &e.ActorUsername, &e.APIKeyID, &e.Action, &e.EntityType, &e.EntityID,
+			&before, &after, &ipAddress, &e.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if before != nil {
+			e.Before = []byte(*before)
+		}
+		if after != nil {
+			e.After = []byte(*after)
+		}
+		if ipAddress != nil {
+			e.IPAddress = *ipAddress
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
@@ -1,18 +1,27 @@
 package repository
 
 import (
+	"database/sql"
 	"zaiko/internal/database"
 	"zaiko/internal/models"
 )
 
-type CategoryRepository struct{}
+type CategoryRepository struct {
+	db *database.DB
+	tx *sql.Tx
+}
+
+func NewCategoryRepository(db *database.DB) *CategoryRepository {
+	return &CategoryRepository{db: db}
+}
 
-func NewCategoryRepository() *CategoryRepository {
-	return &CategoryRepository{}
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *CategoryRepository) WithTx(tx *sql.Tx) *CategoryRepository {
+	return &CategoryRepository{db: r.db, tx: tx}
 }
 
 func (r *CategoryRepository) FindAll() ([]models.Category, error) {
-	rows, err := database.DB.Query("SELECT id, name FROM categories ORDER BY name")
+	rows, err := conn(r.db, r.tx).Query("SELECT id, name FROM categories ORDER BY name")
 	if err != nil {
 		return nil, err
 	}
@@ -32,7 +41,7 @@ func (r *CategoryRepository) FindAll() ([]models.Category, error) {
 
 func (r *CategoryRepository) FindByID(id int64) (*models.Category, error) {
 	category := &models.Category{}
-	err := database.DB.QueryRow(
+	err := conn(r.db, r.tx).QueryRow(
 		"SELECT id, name FROM categories WHERE id = ?",
 		id,
 	).Scan(&category.ID, &category.Name)
@@ -44,12 +53,8 @@ func (r *CategoryRepository) FindByID(id int64) (*models.Category, error) {
 }
 
 func (r *CategoryRepository) Create(name string) (*models.Category, error) {
-	result, err := database.DB.Exec("INSERT INTO categories (name) VALUES (?)", name)
-	if err != nil {
-		return nil, err
-	}
-
-	id, err := result.LastInsertId()
+	var id int64
+	err := conn(r.db, r.tx).QueryRow("INSERT INTO categories (name) VALUES (?) RETURNING id", name).Scan(&id)
 	if err != nil {
 		return nil, err
 	}
@@ -57,7 +62,14 @@ func (r *CategoryRepository) Create(name string) (*models.Category, error) {
 	return &models.Category{ID: id, Name: name}, nil
 }
 
+// categoryReferences are the rows that keep a category from being deleted.
+var categoryReferences = []reference{
+	{"products", "category_id"},
+	{"count_sessions", "category_id"},
+}
+
+// Delete removes a category. It returns an *InUseError if products or
+// count sessions still refer to it.
 func (r *CategoryRepository) Delete(id int64) error {
-	_, err := database.DB.Exec("DELETE FROM categories WHERE id = ?", id)
-	return err
+	return deleteUnreferenced(r.db, r.tx, "categories", id, categoryReferences)
 }
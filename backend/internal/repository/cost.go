@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+)
+
+// signedQuantity and signedCost are the change a transaction makes to the
+// quantity and value on hand. "out" rows store positive amounts; the other
+// types carry their sign.
+const (
+	signedQuantity = "CASE WHEN t.type = 'out' THEN -t.quantity ELSE t.quantity END"
+	signedCost     = "CASE WHEN t.type = 'out' THEN -t.total_cost ELSE t.total_cost END"
+)
+
+// CostRepository keeps the cost layers of stock and reports its value.
+type CostRepository struct {
+	db *database.DB
+	tx *sql.Tx
+}
+
+func NewCostRepository(db *database.DB) *CostRepository {
+	return &CostRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *CostRepository) WithTx(tx *sql.Tx) *CostRepository {
+	return &CostRepository{db: r.db, tx: tx}
+}
+
+func (r *CostRepository) AddLayer(productID, warehouseID, transactionID int64, unitCost float64, quantity int) error {
+	_, err := conn(r.db, r.tx).Exec(`
+		INSERT INTO cost_layers (product_id, warehouse_id, transaction_id, unit_cost, quantity, remaining)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, productID, warehouseID, transactionID, unitCost, quantity, quantity)
+	return err
+}
+
+// OpenLayers returns the layers of a product in a warehouse that still
+// have stock, oldest first.
+func (r *CostRepository) OpenLayers(productID, warehouseID int64) ([]models.CostLayer, error) {
+	rows, err := conn(r.db, r.tx).Query(`
+		SELECT id, product_id, warehouse_id, transaction_id, unit_cost, quantity, remaining
+		FROM cost_layers
+		WHERE product_id = ? AND warehouse_id = ? AND remaining > 0
+		ORDER BY id
+	`, productID, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var layers []models.CostLayer
+	for rows.Next() {
+		var l models.CostLayer
+		if err := rows.Scan(&l.ID, &l.ProductID, &l.WarehouseID, &l.TransactionID, &l.UnitCost, &l.Quantity, &l.Remaining); err != nil {
+			return nil, err
+		}
+		layers = append(layers, l)
+	}
+
+	return layers, rows.Err()
+}
+
+// Consume takes quantity out of a layer.
+func (r *CostRepository) Consume(layerID int64, quantity int) error {
+	_, err := conn(r.db, r.tx).Exec("UPDATE cost_layers SET remaining = remaining - ? WHERE id = ?", quantity, layerID)
+	return err
+}
+
+// LastUnitCost returns the unit cost of the latest receipt of a product in
+// any warehouse. It reports false if the product was never received.
+func (r *CostRepository) LastUnitCost(productID int64) (float64, bool, error) {
+	var unitCost float64
+	err := conn(r.db, r.tx).QueryRow(
+		"SELECT unit_cost FROM cost_layers WHERE product_id = ? AND transaction_id IS NOT NULL ORDER BY id DESC LIMIT 1",
+		productID,
+	).Scan(&unitCost)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return unitCost, true, nil
+}
+
+// ValuationByWarehouse returns the quantity and value of stock per warehouse
+// after all transactions created before the given time. Warehouses without
+// transactions by then are left out.
+func (r *CostRepository) ValuationByWarehouse(before string, warehouseIDs []int64) ([]models.WarehouseValuation, error) {
+	query := `
+		SELECT w.id, w.name, SUM(` + signedQuantity + `), SUM(` + signedCost + `)
+		FROM transactions t
+		JOIN warehouses w ON t.warehouse_id = w.id
+		WHERE t.created_at < ?
+	`
+	args := []interface{}{before}
+	query, args = inWarehouses(query, args, warehouseIDs)
+	query += " GROUP BY w.id, w.name ORDER BY w.name"
+
+	rows, err := conn(r.db, r.tx).Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var valuations []models.WarehouseValuation
+	for rows.Next() {
+		var v models.WarehouseValuation
+		if err := rows.Scan(&v.WarehouseID, &v.WarehouseName, &v.Quantity, &v.Value); err != nil {
+			return nil, err
+		}
+		valuations = append(valuations, v)
+	}
+
+	return valuations, rows.Err()
+}
+
+// ValuationByCategory is ValuationByWarehouse grouped by product category.
+func (r *CostRepository) ValuationByCategory(before string, warehouseIDs []int64) ([]models.CategoryValuation, error) {
+	query := `
+		SELECT COALESCE(c.id, 0), COALESCE(c.name, ''), SUM(` + signedQuantity + `), SUM(` + signedCost + `)
+		FROM transactions t
+		JOIN products p ON t.product_id = p.id
+		LEFT JOIN categories c ON p.category_id = c.id
+		WHERE t.created_at < ?
+	`
+	args := []interface{}{before}
+	query, args = inWarehouses(query, args, warehouseIDs)
+	query += " GROUP BY c.id, c.name ORDER BY c.name"
+
+	rows, err := conn(r.db, r.tx).Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var valuations []models.CategoryValuation
+	for rows.Next() {
+		var v models.CategoryValuation
+		if err := rows.Scan(&v.CategoryID, &v.CategoryName, &v.Quantity, &v.Value); err != nil {
+			return nil, err
+		}
+		valuations = append(valuations, v)
+	}
+
+	return valuations, rows.Err()
+}
+
+// inWarehouses limits a transaction query to the given warehouses, if any.
+func inWarehouses(query string, args []interface{}, warehouseIDs []int64) (string, []interface{}) {
+	if len(warehouseIDs) == 0 {
+		return query, args
+	}
+
+	query += " AND t.warehouse_id IN (" + questionMarks(len(warehouseIDs)) + ")"
+	for _, id := range warehouseIDs {
+		args = append(args, id)
+	}
+	return query, args
+}
@@ -0,0 +1,290 @@
+package repository
+
+import (
+	"database/sql"
+
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+)
+
+type CountRepository struct {
+	db *database.DB
+	tx *sql.Tx
+}
+
+func NewCountRepository(db *database.DB) *CountRepository {
+	return &CountRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *CountRepository) WithTx(tx *sql.Tx) *CountRepository {
+	return &CountRepository{db: r.db, tx: tx}
+}
+
+func nullableID(id int64) interface{} {
+	if id > 0 {
+		return id
+	}
+	return nil
+}
+
+// CreateSession opens a count session and snapshots the current quantity of
+// every stock row in its scope as the expected quantity.
+func (r *CountRepository) CreateSession(req models.CreateCountSessionRequest, userID int64) (int64, error) {
+	var id int64
+	err := conn(r.db, r.tx).QueryRow(
+		"INSERT INTO count_sessions (warehouse_id, category_id, note, created_by) VALUES (?, ?, ?, ?) RETURNING id",
+		nullableID(req.WarehouseID), nullableID(req.CategoryID), req.Note, userID,
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		INSERT INTO count_lines (session_id, product_id, warehouse_id, expected_quantity)
+		SELECT CAST(? AS INTEGER), s.product_id, s.warehouse_id, s.quantity
+		FROM stock s
+		JOIN products p ON s.product_id = p.id
+		WHERE 1=1
+	`
+	args := []interface{}{id}
+
+	if req.WarehouseID > 0 {
+		query += " AND s.warehouse_id = ?"
+		args = append(args, req.WarehouseID)
+	}
+
+	if req.CategoryID > 0 {
+		query += " AND p.category_id = ?"
+		args = append(args, req.CategoryID)
+	}
+
+	if _, err := conn(r.db, r.tx).Exec(query, args...); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// IsLocked reports whether an open count session covers the product in the
+// warehouse.
+func (r *CountRepository) IsLocked(productID, warehouseID int64) (bool, error) {
+	var count int
+	err := conn(r.db, r.tx).QueryRow(`
+		SELECT COUNT(*)
+		FROM count_sessions cs
+		JOIN products p ON p.id = ?
+		WHERE cs.status = 'open'
+		  AND (cs.warehouse_id IS NULL OR cs.warehouse_id = ?)
+		  AND (cs.category_id IS NULL OR cs.category_id = p.category_id)
+	`, productID, warehouseID).Scan(&count)
+	return count > 0, err
+}
+
+func (r *CountRepository) FindAll(filter models.CountFilter) ([]models.CountSession, error) {
+	query := `
+		SELECT id, warehouse_id, category_id, status, note, created_by, created_at, closed_at
+		FROM count_sessions
+		WHERE 1=1
+	`
+	var args []interface{}
+
+	if filter.Status != "" {
+		query += " AND status = ?"
+		args = append(args, filter.Status)
+	}
+
+	if filter.WarehouseID > 0 {
+		query += " AND warehouse_id = ?"
+		args = append(args, filter.WarehouseID)
+	}
+
+	query += " ORDER BY created_at DESC, id DESC"
+
+	rows, err := conn(r.db, r.tx).Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []models.CountSession
+	for rows.Next() {
+		var cs models.CountSession
+		var note *string
+		if err := rows.Scan(
+			&cs.ID, &cs.WarehouseID, &cs.CategoryID, &cs.Status, &note, &cs.CreatedBy, &cs.CreatedAt, &cs.ClosedAt,
+		); err != nil {
+			return nil, err
+		}
+		if note != nil {
+			cs.Note = *note
+		}
+		sessions = append(sessions, cs)
+	}
+
+	return sessions, nil
+}
+
+func (r *CountRepository) FindByID(id int64) (*models.CountSession, error) {
+	var cs models.CountSession
+	var note *string
+
+	err := conn(r.db, r.tx).QueryRow(`
+		SELECT id, warehouse_id, category_id, status, note, created_by, created_at, closed_at
+		FROM count_sessions WHERE id = ?
+	`, id).Scan(
+		&cs.ID, &cs.WarehouseID, &cs.CategoryID, &cs.Status, &note, &cs.CreatedBy, &cs.CreatedAt, &cs.ClosedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if note != nil {
+		cs.Note = *note
+	}
+
+	return &cs, nil
+}
+
+func (r *CountRepository) FindLines(sessionID int64) ([]models.CountLine, error) {
+	rows, err := conn(r.db, r.tx).Query(`
+		SELECT l.id, l.session_id, l.product_id, l.warehouse_id, l.expected_quantity, l.counted_quantity,
+		       l.counted_by, l.counted_at, l.adjustment_transaction_id,
+		       p.id, p.code, p.name, p.unit,
+		       w.id, w.name
+		FROM count_lines l
+		JOIN products p ON l.product_id = p.id
+		JOIN warehouses w ON l.warehouse_id = w.id
+		WHERE l.session_id = ?
+		ORDER BY w.name, p.name
+	`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []models.CountLine
+	for rows.Next() {
+		var l models.CountLine
+		var p models.Product
+		var w models.Warehouse
+
+		if err := rows.Scan(
+			&l.ID, &l.SessionID, &l.ProductID, &l.WarehouseID, &l.ExpectedQuantity, &l.CountedQuantity,
+			&l.CountedBy, &l.CountedAt, &l.AdjustmentTransactionID,
+			&p.ID, &p.Code, &p.Name, &p.Unit,
+			&w.ID, &w.Name,
+		); err != nil {
+			return nil, err
+		}
+
+		if l.CountedQuantity != nil {
+			variance := *l.CountedQuantity - l.ExpectedQuantity
+			l.Variance = &variance
+		}
+
+		l.Product = &p
+		l.Warehouse = &w
+		lines = append(lines, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.loadSerials(sessionID, lines); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// loadSerials fills in the serial numbers of a session's lines.
+func (r *CountRepository) loadSerials(sessionID int64, lines []models.CountLine) error {
+	rows, err := conn(r.db, r.tx).Query(`
+		SELECT s.count_line_id, s.serial_number
+		FROM count_line_serials s
+		JOIN count_lines l ON s.count_line_id = l.id
+		WHERE l.session_id = ?
+		ORDER BY s.serial_number
+	`, sessionID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	serials := map[int64][]string{}
+	for rows.Next() {
+		var lineID int64
+		var serial string
+		if err := rows.Scan(&lineID, &serial); err != nil {
+			return err
+		}
+		serials[lineID] = append(serials[lineID], serial)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range lines {
+		lines[i].Serials = serials[lines[i].ID]
+	}
+	return nil
+}
+
+// SaveCount records a counted quantity and replaces the line's serial
+// numbers. A line is added with an expected quantity of zero when the item
+// had no stock row at snapshot time.
+func (r *CountRepository) SaveCount(sessionID int64, entry models.CountEntry, userID int64) error {
+	var lineID int64
+	err := conn(r.db, r.tx).QueryRow(`
+		INSERT INTO count_lines (session_id, product_id, warehouse_id, expected_quantity, counted_quantity, counted_by, counted_at)
+		VALUES (?, ?, ?, 0, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(session_id, product_id, warehouse_id) DO UPDATE SET
+			counted_quantity = excluded.counted_quantity,
+			counted_by = excluded.counted_by,
+			counted_at = CURRENT_TIMESTAMP
+		RETURNING id
+	`, sessionID, entry.ProductID, entry.WarehouseID, *entry.CountedQuantity, userID).Scan(&lineID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := conn(r.db, r.tx).Exec("DELETE FROM count_line_serials WHERE count_line_id = ?", lineID); err != nil {
+		return err
+	}
+	for _, serial := range entry.Serials {
+		if _, err := conn(r.db, r.tx).Exec(
+			"INSERT INTO count_line_serials (count_line_id, serial_number) VALUES (?, ?) ON CONFLICT DO NOTHING",
+			lineID, serial,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *CountRepository) SetAdjustment(lineID, transactionID int64) error {
+	_, err := conn(r.db, r.tx).Exec(
+		"UPDATE count_lines SET adjustment_transaction_id = ? WHERE id = ?",
+		transactionID, lineID,
+	)
+	return err
+}
+
+// Close moves an open session to the given status. It reports whether the
+// session was open.
+func (r *CountRepository) Close(id int64, status models.CountStatus) (bool, error) {
+	result, err := conn(r.db, r.tx).Exec(`
+		UPDATE count_sessions SET status = ?, closed_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = 'open'
+	`, status, id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+)
+
+type CustomerRepository struct {
+	db *database.DB
+	tx *sql.Tx
+}
+
+func NewCustomerRepository(db *database.DB) *CustomerRepository {
+	return &CustomerRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *CustomerRepository) WithTx(tx *sql.Tx) *CustomerRepository {
+	return &CustomerRepository{db: r.db, tx: tx}
+}
+
+func (r *CustomerRepository) FindAll() ([]models.Customer, error) {
+	rows, err := conn(r.db, r.tx).Query(
+		"SELECT id, name, contact, address, created_at FROM customers ORDER BY name",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var customers []models.Customer
+	for rows.Next() {
+		var cu models.Customer
+		var contact, address *string
+		if err := rows.Scan(&cu.ID, &cu.Name, &contact, &address, &cu.CreatedAt); err != nil {
+			return nil, err
+		}
+		if contact != nil {
+			cu.Contact = *contact
+		}
+		if address != nil {
+			cu.Address = *address
+		}
+		customers = append(customers, cu)
+	}
+
+	return customers, nil
+}
+
+func (r *CustomerRepository) FindByID(id int64) (*models.Customer, error) {
+	var cu models.Customer
+	var contact, address *string
+
+	err := conn(r.db, r.tx).QueryRow(
+		"SELECT id, name, contact, address, created_at FROM customers WHERE id = ?",
+		id,
+	).Scan(&cu.ID, &cu.Name, &contact, &address, &cu.CreatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if contact != nil {
+		cu.Contact = *contact
+	}
+	if address != nil {
+		cu.Address = *address
+	}
+
+	return &cu, nil
+}
+
+func (r *CustomerRepository) Create(req models.CreateCustomerRequest) (*models.Customer, error) {
+	var id int64
+	err := conn(r.db, r.tx).QueryRow(
+		"INSERT INTO customers (name, contact, address) VALUES (?, ?, ?) RETURNING id",
+		req.Name, req.Contact, req.Address,
+	).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.FindByID(id)
+}
+
+func (r *CustomerRepository) Update(id int64, req models.UpdateCustomerRequest) (*models.Customer, error) {
+	var updates []string
+	var args []interface{}
+
+	if req.Name != "" {
+		updates = append(updates, "name = ?")
+		args = append(args, req.Name)
+	}
+	if req.Contact != "" {
+		updates = append(updates, "contact = ?")
+		args = append(args, req.Contact)
+	}
+	if req.Address != "" {
+		updates = append(updates, "address = ?")
+		args = append(args, req.Address)
+	}
+
+	if len(updates) == 0 {
+		return r.FindByID(id)
+	}
+
+	args = append(args, id)
+	query := fmt.Sprintf("UPDATE customers SET %s WHERE id = ?", strings.Join(updates, ", "))
+
+	_, err := conn(r.db, r.tx).Exec(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.FindByID(id)
+}
+
+// Delete removes a customer. It returns an *InUseError if sales orders
+// still refer to it.
+func (r *CustomerRepository) Delete(id int64) error {
+	return deleteUnreferenced(r.db, r.tx, "customers", id, []reference{{"sales_orders", "customer_id"}})
+}
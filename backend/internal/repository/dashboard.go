@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+)
+
+// DashboardRepository runs the aggregate queries of the dashboard summary.
+type DashboardRepository struct {
+	db *database.DB
+}
+
+func NewDashboardRepository(db *database.DB) *DashboardRepository {
+	return &DashboardRepository{db: db}
+}
+
+// Totals returns the number of products and warehouses.
+func (r *DashboardRepository) Totals() (products, warehouses int, err error) {
+	if err = r.db.QueryRow("SELECT COUNT(*) FROM products").Scan(&products); err != nil {
+		return 0, 0, err
+	}
+	if err = r.db.QueryRow("SELECT COUNT(*) FROM warehouses").Scan(&warehouses); err != nil {
+		return 0, 0, err
+	}
+	return products, warehouses, nil
+}
+
+func (r *DashboardRepository) StockByWarehouse() ([]models.WarehouseStockSummary, error) {
+	rows, err := r.db.Query(`
+		SELECT w.id, w.name, COUNT(DISTINCT s.product_id), COALESCE(SUM(s.quantity), 0)
+		FROM warehouses w
+		LEFT JOIN stock s ON w.id = s.warehouse_id
+		GROUP BY w.id, w.name
+		ORDER BY w.name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []models.WarehouseStockSummary
+	for rows.Next() {
+		var ws models.WarehouseStockSummary
+		if err := rows.Scan(&ws.WarehouseID, &ws.WarehouseName, &ws.TotalItems, &ws.TotalQuantity); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, ws)
+	}
+
+	return summaries, nil
+}
+
+func (r *DashboardRepository) StockByCategory() ([]models.CategoryStockSummary, error) {
+	rows, err := r.db.Query(`
+		SELECT c.id, c.name, COUNT(DISTINCT s.product_id), COALESCE(SUM(s.quantity), 0)
+		FROM categories c
+		LEFT JOIN products p ON c.id = p.category_id
+		LEFT JOIN stock s ON p.id = s.product_id
+		GROUP BY c.id, c.name
+		ORDER BY c.name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []models.CategoryStockSummary
+	for rows.Next() {
+		var cs models.CategoryStockSummary
+		if err := rows.Scan(&cs.CategoryID, &cs.CategoryName, &cs.TotalItems, &cs.TotalQuantity); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, cs)
+	}
+
+	return summaries, nil
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"zaiko/internal/database"
+)
+
+// dbtx is satisfied by both *sql.DB and *sql.Tx, so repository methods can
+// run either standalone or as part of a caller-managed transaction.
+type dbtx interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// conn returns tx if the repository runs in a transaction and db otherwise.
+func conn(db *database.DB, tx *sql.Tx) dbtx {
+	if tx != nil {
+		return tx
+	}
+	return db
+}
+
+// inTx runs fn in tx, or in a transaction of its own when tx is nil, for
+// repository methods that make several changes that belong together.
+func inTx(db *database.DB, tx *sql.Tx, fn func(tx *sql.Tx) error) error {
+	if tx != nil {
+		return fn(tx)
+	}
+	return db.InTx(fn)
+}
+
+// today returns the current UTC date. Date columns hold YYYY-MM-DD text and
+// are compared with today computed here rather than with SQL date functions,
+// which differ between databases.
+func today() time.Time {
+	return time.Now().UTC().Truncate(24 * time.Hour)
+}
+
+// questionMarks returns n comma-separated query placeholders.
+func questionMarks(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// InUseError is returned when a record cannot be deleted because rows in
+// other tables still refer to it. References counts those rows by table.
+type InUseError struct {
+	References map[string]int
+}
+
+func (e *InUseError) Error() string {
+	tables := make([]string, 0, len(e.References))
+	for table := range e.References {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	parts := make([]string, len(tables))
+	for i, table := range tables {
+		parts[i] = fmt.Sprintf("%s: %d", table, e.References[table])
+	}
+	return "record is referenced by " + strings.Join(parts, ", ")
+}
+
+// reference is a column in another table that holds the id of a record.
+type reference struct {
+	table  string
+	column string
+}
+
+// deleteUnreferenced deletes the row of table with the given id unless one
+// of refs still points at it, in which case it returns an *InUseError.
+// Statements in dependents remove rows that belong to the record and go
+// with it, such as its settings; each takes the id as its only argument.
+// The checks and the delete run in tx, or in a transaction of their own
+// when tx is nil, so nothing can start referring to the row in between.
+func deleteUnreferenced(db *database.DB, tx *sql.Tx, table string, id int64, refs []reference, dependents ...string) error {
+	if tx == nil {
+		return db.InTx(func(tx *sql.Tx) error {
+			return deleteUnreferenced(db, tx, table, id, refs, dependents...)
+		})
+	}
+
+	inUse := map[string]int{}
+	for _, ref := range refs {
+		var count int
+		query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = ?", ref.table, ref.column)
+		if err := tx.QueryRow(query, id).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			inUse[ref.table] += count
+		}
+	}
+	if len(inUse) > 0 {
+		return &InUseError{References: inUse}
+	}
+
+	for _, stmt := range dependents {
+		if _, err := tx.Exec(stmt, id); err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = ?", table), id)
+	return err
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+)
+
+type LoginThrottleRepository struct {
+	db *database.DB
+	tx *sql.Tx
+}
+
+func NewLoginThrottleRepository(db *database.DB) *LoginThrottleRepository {
+	return &LoginThrottleRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *LoginThrottleRepository) WithTx(tx *sql.Tx) *LoginThrottleRepository {
+	return &LoginThrottleRepository{db: r.db, tx: tx}
+}
+
+func (r *LoginThrottleRepository) Find(scope models.LoginThrottleScope, key string) (*models.LoginThrottle, error) {
+	t := models.LoginThrottle{Scope: scope, Key: key}
+	err := conn(r.db, r.tx).QueryRow(
+		"SELECT failures, last_failed_at, locked_until FROM login_throttles WHERE scope = ? AND key = ?",
+		scope, key,
+	).Scan(&t.Failures, &t.LastFailedAt, &t.LockedUntil)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *LoginThrottleRepository) Save(t *models.LoginThrottle) error {
+	var lockedUntil interface{}
+	if t.LockedUntil != nil {
+		lockedUntil = t.LockedUntil.UTC().Format(time.DateTime)
+	}
+
+	_, err := conn(r.db, r.tx).Exec(`
+		INSERT INTO login_throttles (scope, key, failures, last_failed_at, locked_until)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (scope, key) DO UPDATE SET
+			failures = excluded.failures,
+			last_failed_at = excluded.last_failed_at,
+			locked_until = excluded.locked_until
+	`, t.Scope, t.Key, t.Failures, t.LastFailedAt.UTC().Format(time.DateTime), lockedUntil)
+	return err
+}
+
+// Delete clears the failures of a username or IP. It reports whether there
+// were any.
+func (r *LoginThrottleRepository) Delete(scope models.LoginThrottleScope, key string) (bool, error) {
+	result, err := conn(r.db, r.tx).Exec("DELETE FROM login_throttles WHERE scope = ? AND key = ?", scope, key)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *LoginThrottleRepository) AddEvent(e *models.LockoutEvent) error {
+	var lockedUntil interface{}
+	if e.LockedUntil != nil {
+		lockedUntil = e.LockedUntil.UTC().Format(time.DateTime)
+	}
+
+	_, err := conn(r.db, r.tx).Exec(`
+		INSERT INTO login_lockout_events (scope, key, event, failures, ip_address, locked_until, user_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, e.Scope, e.Key, e.Event, e.Failures, e.IPAddress, lockedUntil, e.UserID)
+	return err
+}
+
+func (r *LoginThrottleRepository) FindEvents(filter models.LockoutEventFilter) ([]models.LockoutEvent, error) {
+	query := `
+		SELECT id, scope, key, event, failures, ip_address, locked_until, user_id, created_at
+		FROM login_lockout_events WHERE 1=1
+	`
+	var args []interface{}
+
+	if filter.Scope != "" {
+		query += " AND scope = ?"
+		args = append(args, filter.Scope)
+	}
+
+	if filter.Key != "" {
+		query += " AND key = ?"
+		args = append(args, filter.Key)
+	}
+
+	if filter.Event != "" {
+		query += " AND event = ?"
+		args = append(args, filter.Event)
+	}
+
+	query += " ORDER BY created_at DESC, id DESC"
+
+	rows, err := conn(r.db, r.tx).Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.LockoutEvent
+	for rows.Next() {
+		var e models.LockoutEvent
+		var ipAddress *string
+		if err := rows.Scan(
+			&e.ID, &e.Scope, &e.Key, &e.Event, &e.Failures, &ipAddress, &e.LockedUntil, &e.UserID, &e.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if ipAddress != nil {
+			e.IPAddress = *ipAddress
+		}
+		events = append(events, e)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,240 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+)
+
+type LotRepository struct {
+	db *database.DB
+	tx *sql.Tx
+}
+
+func NewLotRepository(db *database.DB) *LotRepository {
+	return &LotRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *LotRepository) WithTx(tx *sql.Tx) *LotRepository {
+	return &LotRepository{db: r.db, tx: tx}
+}
+
+const lotColumns = `
+	l.id, l.product_id, l.warehouse_id, l.lot_number, l.manufactured_on, l.expires_on, l.quantity, l.created_at,
+	p.id, p.code, p.name, p.unit,
+	w.id, w.name
+`
+
+func scanLot(scanner interface{ Scan(...interface{}) error }) (*models.Lot, error) {
+	var l models.Lot
+	var p models.Product
+	var w models.Warehouse
+
+	if err := scanner.Scan(
+		&l.ID, &l.ProductID, &l.WarehouseID, &l.LotNumber, &l.ManufacturedOn, &l.ExpiresOn, &l.Quantity, &l.CreatedAt,
+		&p.ID, &p.Code, &p.Name, &p.Unit,
+		&w.ID, &w.Name,
+	); err != nil {
+		return nil, err
+	}
+
+	if l.ExpiresOn != nil {
+		expiresOn, err := time.Parse(time.DateOnly, *l.ExpiresOn)
+		if err != nil {
+			return nil, err
+		}
+		days := int(expiresOn.Sub(today()).Hours() / 24)
+		l.DaysUntilExpiry = &days
+	}
+
+	l.Product = &p
+	l.Warehouse = &w
+	return &l, nil
+}
+
+func (r *LotRepository) query(where string, args ...interface{}) ([]models.Lot, error) {
+	rows, err := conn(r.db, r.tx).Query(`
+		SELECT `+lotColumns+`
+		FROM lots l
+		JOIN products p ON l.product_id = p.id
+		JOIN warehouses w ON l.warehouse_id = w.id
+		WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lots []models.Lot
+	for rows.Next() {
+		lot, err := scanLot(rows)
+		if err != nil {
+			return nil, err
+		}
+		lots = append(lots, *lot)
+	}
+
+	return lots, nil
+}
+
+func (r *LotRepository) FindAll(filter models.LotFilter) ([]models.Lot, error) {
+	where := "l.quantity > 0"
+	var args []interface{}
+
+	if filter.ProductID > 0 {
+		where += " AND l.product_id = ?"
+		args = append(args, filter.ProductID)
+	}
+
+	if filter.WarehouseID > 0 {
+		where += " AND l.warehouse_id = ?"
+		args = append(args, filter.WarehouseID)
+	}
+
+	if len(filter.WarehouseIDs) > 0 {
+		where += " AND l.warehouse_id IN (" + questionMarks(len(filter.WarehouseIDs)) + ")"
+		for _, id := range filter.WarehouseIDs {
+			args = append(args, id)
+		}
+	}
+
+	return r.query(where+" ORDER BY p.name, w.name, l.expires_on IS NULL, l.expires_on, l.id", args...)
+}
+
+// FindExpiring returns lots with stock that expire within the given number
+// of days, including lots that have already expired.
+func (r *LotRepository) FindExpiring(filter models.ExpiringLotFilter) ([]models.Lot, error) {
+	where := "l.quantity > 0 AND l.expires_on IS NOT NULL AND l.expires_on <= ?"
+	args := []interface{}{today().AddDate(0, 0, filter.Days).Format(time.DateOnly)}
+
+	if filter.WarehouseID > 0 {
+		where += " AND l.warehouse_id = ?"
+		args = append(args, filter.WarehouseID)
+	}
+
+	if len(filter.WarehouseIDs) > 0 {
+		where += " AND l.warehouse_id IN (" + questionMarks(len(filter.WarehouseIDs)) + ")"
+		for _, id := range filter.WarehouseIDs {
+			args = append(args, id)
+		}
+	}
+
+	return r.query(where+" ORDER BY l.expires_on, p.name, w.name", args...)
+}
+
+func (r *LotRepository) FindByNumber(productID, warehouseID int64, lotNumber string) (*models.Lot, error) {
+	row := conn(r.db, r.tx).QueryRow(`
+		SELECT `+lotColumns+`
+		FROM lots l
+		JOIN products p ON l.product_id = p.id
+		JOIN warehouses w ON l.warehouse_id = w.id
+		WHERE l.product_id = ? AND l.warehouse_id = ? AND l.lot_number = ?
+	`, productID, warehouseID, lotNumber)
+
+	return scanLot(row)
+}
+
+// FindAllocatable returns the unexpired lots with stock in first-expired-
+// first-out order. Lots without an expiry date come last.
+func (r *LotRepository) FindAllocatable(productID, warehouseID int64) ([]models.Lot, error) {
+	return r.query(`
+		l.product_id = ? AND l.warehouse_id = ? AND l.quantity > 0
+		AND (l.expires_on IS NULL OR l.expires_on >= ?)
+		ORDER BY l.expires_on IS NULL, l.expires_on, l.id
+	`, productID, warehouseID, today().Format(time.DateOnly))
+}
+
+func (r *LotRepository) TotalQuantity(productID, warehouseID int64) (int, error) {
+	var total int
+	err := conn(r.db, r.tx).QueryRow(
+		"SELECT COALESCE(SUM(quantity), 0) FROM lots WHERE product_id = ? AND warehouse_id = ?",
+		productID, warehouseID,
+	).Scan(&total)
+	return total, err
+}
+
+// Receive adds quantity to a lot, creating it if needed. Dates of an existing
+// lot are kept and only filled in when they were unknown.
+func (r *LotRepository) Receive(productID, warehouseID int64, lot models.LotRequest, quantity int) (*models.Lot, error) {
+	var manufacturedOn, expiresOn interface{}
+	if lot.ManufacturedOn != "" {
+		manufacturedOn = lot.ManufacturedOn
+	}
+	if lot.ExpiresOn != "" {
+		expiresOn = lot.ExpiresOn
+	}
+
+	_, err := conn(r.db, r.tx).Exec(`
+		INSERT INTO lots (product_id, warehouse_id, lot_number, manufactured_on, expires_on, quantity)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(product_id, warehouse_id, lot_number) DO UPDATE SET
+			quantity = lots.quantity + excluded.quantity,
+			manufactured_on = COALESCE(lots.manufactured_on, excluded.manufactured_on),
+			expires_on = COALESCE(lots.expires_on, excluded.expires_on)
+	`, productID, warehouseID, lot.LotNumber, manufacturedOn, expiresOn, quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.FindByNumber(productID, warehouseID, lot.LotNumber)
+}
+
+// Decrement lowers a lot's quantity only if enough is left. It reports
+// whether the lot was updated.
+func (r *LotRepository) Decrement(id int64, quantity int) (bool, error) {
+	result, err := conn(r.db, r.tx).Exec(
+		"UPDATE lots SET quantity = quantity - ? WHERE id = ? AND quantity >= ?",
+		quantity, id, quantity,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// Restore puts quantity back into a lot.
+func (r *LotRepository) Restore(id int64, quantity int) error {
+	_, err := conn(r.db, r.tx).Exec("UPDATE lots SET quantity = quantity + ? WHERE id = ?", quantity, id)
+	return err
+}
+
+// FindByTransaction returns the lots a transaction moved.
+func (r *LotRepository) FindByTransaction(transactionID int64) ([]models.TransactionLot, error) {
+	rows, err := conn(r.db, r.tx).Query(`
+		SELECT tl.lot_id, l.lot_number, tl.quantity
+		FROM transaction_lots tl
+		JOIN lots l ON tl.lot_id = l.id
+		WHERE tl.transaction_id = ?
+		ORDER BY tl.lot_id
+	`, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lots []models.TransactionLot
+	for rows.Next() {
+		var tl models.TransactionLot
+		if err := rows.Scan(&tl.LotID, &tl.LotNumber, &tl.Quantity); err != nil {
+			return nil, err
+		}
+		lots = append(lots, tl)
+	}
+
+	return lots, rows.Err()
+}
+
+func (r *LotRepository) AddToTransaction(transactionID, lotID int64, quantity int) error {
+	_, err := conn(r.db, r.tx).Exec(
+		"INSERT INTO transaction_lots (transaction_id, lot_id, quantity) VALUES (?, ?, ?)",
+		transactionID, lotID, quantity,
+	)
+	return err
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"database/sql"
 	"fmt"
 	"strings"
 
@@ -8,15 +9,23 @@ import (
 	"zaiko/internal/models"
 )
 
-type ProductRepository struct{}
+type ProductRepository struct {
+	db *database.DB
+	tx *sql.Tx
+}
+
+func NewProductRepository(db *database.DB) *ProductRepository {
+	return &ProductRepository{db: db}
+}
 
-func NewProductRepository() *ProductRepository {
-	return &ProductRepository{}
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *ProductRepository) WithTx(tx *sql.Tx) *ProductRepository {
+	return &ProductRepository{db: r.db, tx: tx}
 }
 
 func (r *ProductRepository) FindAll(filter models.ProductFilter) ([]models.Product, error) {
 	query := `
-		SELECT p.id, p.code, p.name, p.description, p.category_id, p.unit, p.created_at,
+		SELECT p.id, p.code, p.name, p.description, p.category_id, p.unit, p.serialized, p.created_at,
 		       c.id, c.name
 		FROM products p
 		LEFT JOIN categories c ON p.category_id = c.id
@@ -25,8 +34,8 @@ func (r *ProductRepository) FindAll(filter models.ProductFilter) ([]models.Produ
 	var args []interface{}
 
 	if filter.Search != "" {
-		query += " AND (p.name LIKE ? OR p.code LIKE ?)"
-		searchTerm := "%" + filter.Search + "%"
+		query += " AND (LOWER(p.name) LIKE ? OR LOWER(p.code) LIKE ?)"
+		searchTerm := "%" + strings.ToLower(filter.Search) + "%"
 		args = append(args, searchTerm, searchTerm)
 	}
 
@@ -37,7 +46,7 @@ func (r *ProductRepository) FindAll(filter models.ProductFilter) ([]models.Produ
 
 	query += " ORDER BY p.name"
 
-	rows, err := database.DB.Query(query, args...)
+	rows, err := conn(r.db, r.tx).Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -47,15 +56,18 @@ func (r *ProductRepository) FindAll(filter models.ProductFilter) ([]models.Produ
 	for rows.Next() {
 		var p models.Product
 		var categoryID, catID *int64
-		var catName *string
+		var description, catName *string
 
 		if err := rows.Scan(
-			&p.ID, &p.Code, &p.Name, &p.Description, &categoryID, &p.Unit, &p.CreatedAt,
+			&p.ID, &p.Code, &p.Name, &description, &categoryID, &p.Unit, &p.Serialized, &p.CreatedAt,
 			&catID, &catName,
 		); err != nil {
 			return nil, err
 		}
 
+		if description != nil {
+			p.Description = *description
+		}
 		if categoryID != nil {
 			p.CategoryID = *categoryID
 		}
@@ -72,16 +84,16 @@ func (r *ProductRepository) FindAll(filter models.ProductFilter) ([]models.Produ
 func (r *ProductRepository) FindByID(id int64) (*models.Product, error) {
 	var p models.Product
 	var categoryID, catID *int64
-	var catName *string
+	var description, catName *string
 
-	err := database.DB.QueryRow(`
-		SELECT p.id, p.code, p.name, p.description, p.category_id, p.unit, p.created_at,
+	err := conn(r.db, r.tx).QueryRow(`
+		SELECT p.id, p.code, p.name, p.description, p.category_id, p.unit, p.serialized, p.created_at,
 		       c.id, c.name
 		FROM products p
 		LEFT JOIN categories c ON p.category_id = c.id
 		WHERE p.id = ?
 	`, id).Scan(
-		&p.ID, &p.Code, &p.Name, &p.Description, &categoryID, &p.Unit, &p.CreatedAt,
+		&p.ID, &p.Code, &p.Name, &description, &categoryID, &p.Unit, &p.Serialized, &p.CreatedAt,
 		&catID, &catName,
 	)
 
@@ -89,6 +101,9 @@ func (r *ProductRepository) FindByID(id int64) (*models.Product, error) {
 		return nil, err
 	}
 
+	if description != nil {
+		p.Description = *description
+	}
 	if categoryID != nil {
 		p.CategoryID = *categoryID
 	}
@@ -105,15 +120,11 @@ func (r *ProductRepository) Create(req models.CreateProductRequest) (*models.Pro
 		categoryID = req.CategoryID
 	}
 
-	result, err := database.DB.Exec(
-		"INSERT INTO products (code, name, description, category_id, unit) VALUES (?, ?, ?, ?, ?)",
-		req.Code, req.Name, req.Description, categoryID, req.Unit,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	id, err := result.LastInsertId()
+	var id int64
+	err := conn(r.db, r.tx).QueryRow(
+		"INSERT INTO products (code, name, description, category_id, unit, serialized) VALUES (?, ?, ?, ?, ?, ?) RETURNING id",
+		req.Code, req.Name, req.Description, categoryID, req.Unit, req.Serialized,
+	).Scan(&id)
 	if err != nil {
 		return nil, err
 	}
@@ -145,6 +156,10 @@ func (r *ProductRepository) Update(id int64, req models.UpdateProductRequest) (*
 		updates = append(updates, "unit = ?")
 		args = append(args, req.Unit)
 	}
+	if req.Serialized != nil {
+		updates = append(updates, "serialized = ?")
+		args = append(args, *req.Serialized)
+	}
 
 	if len(updates) == 0 {
 		return r.FindByID(id)
@@ -153,7 +168,7 @@ func (r *ProductRepository) Update(id int64, req models.UpdateProductRequest) (*
 	args = append(args, id)
 	query := fmt.Sprintf("UPDATE products SET %s WHERE id = ?", strings.Join(updates, ", "))
 
-	_, err := database.DB.Exec(query, args...)
+	_, err := conn(r.db, r.tx).Exec(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -161,7 +176,22 @@ func (r *ProductRepository) Update(id int64, req models.UpdateProductRequest) (*
 	return r.FindByID(id)
 }
 
+// productReferences are the rows that keep a product from being deleted.
+var productReferences = []reference{
+	{"stock", "product_id"},
+	{"transactions", "product_id"},
+	{"lots", "product_id"},
+	{"serial_numbers", "product_id"},
+	{"count_lines", "product_id"},
+	{"purchase_order_lines", "product_id"},
+	{"sales_order_lines", "product_id"},
+	{"reservations", "product_id"},
+}
+
+// Delete removes a product and its reorder settings. It returns an
+// *InUseError if the product has stock, history or orders.
 func (r *ProductRepository) Delete(id int64) error {
-	_, err := database.DB.Exec("DELETE FROM products WHERE id = ?", id)
-	return err
+	return deleteUnreferenced(r.db, r.tx, "products", id, productReferences,
+		"DELETE FROM reorder_settings WHERE product_id = ?",
+	)
 }
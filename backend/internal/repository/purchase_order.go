@@ -0,0 +1,269 @@
+package repository
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+)
+
+type PurchaseOrderRepository struct {
+	db *database.DB
+	tx *sql.Tx
+}
+
+func NewPurchaseOrderRepository(db *database.DB) *PurchaseOrderRepository {
+	return &PurchaseOrderRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *PurchaseOrderRepository) WithTx(tx *sql.Tx) *PurchaseOrderRepository {
+	return &PurchaseOrderRepository{db: r.db, tx: tx}
+}
+
+const purchaseOrderQuery = `
+	SELECT po.id, po.supplier_id, po.warehouse_id, po.status, po.expected_date, po.note, po.created_by,
+	       po.created_at, po.ordered_at, po.closed_at,
+	       s.id, s.name,
+	       w.id, w.name
+	FROM purchase_orders po
+	JOIN suppliers s ON po.supplier_id = s.id
+	JOIN warehouses w ON po.warehouse_id = w.id
+`
+
+func scanPurchaseOrder(scanner interface{ Scan(...interface{}) error }) (*models.PurchaseOrder, error) {
+	var po models.PurchaseOrder
+	var note *string
+	var s models.Supplier
+	var w models.Warehouse
+
+	if err := scanner.Scan(
+		&po.ID, &po.SupplierID, &po.WarehouseID, &po.Status, &po.ExpectedDate, &note, &po.CreatedBy,
+		&po.CreatedAt, &po.OrderedAt, &po.ClosedAt,
+		&s.ID, &s.Name,
+		&w.ID, &w.Name,
+	); err != nil {
+		return nil, err
+	}
+
+	if note != nil {
+		po.Note = *note
+	}
+
+	po.Supplier = &s
+	po.Warehouse = &w
+	return &po, nil
+}
+
+func (r *PurchaseOrderRepository) FindAll(filter models.PurchaseOrderFilter) ([]models.PurchaseOrder, error) {
+	query := purchaseOrderQuery + " WHERE 1=1"
+	var args []interface{}
+
+	if filter.Status != "" {
+		query += " AND po.status = ?"
+		args = append(args, filter.Status)
+	}
+
+	if filter.SupplierID > 0 {
+		query += " AND po.supplier_id = ?"
+		args = append(args, filter.SupplierID)
+	}
+
+	query += " ORDER BY po.created_at DESC, po.id DESC"
+
+	rows, err := conn(r.db, r.tx).Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []models.PurchaseOrder
+	for rows.Next() {
+		po, err := scanPurchaseOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, *po)
+	}
+
+	return orders, nil
+}
+
+func (r *PurchaseOrderRepository) FindByID(id int64) (*models.PurchaseOrder, error) {
+	return scanPurchaseOrder(conn(r.db, r.tx).QueryRow(purchaseOrderQuery+" WHERE po.id = ?", id))
+}
+
+func (r *PurchaseOrderRepository) FindLines(purchaseOrderID int64) ([]models.PurchaseOrderLine, error) {
+	rows, err := conn(r.db, r.tx).Query(`
+		SELECT l.id, l.purchase_order_id, l.product_id, l.quantity, l.received_quantity, l.unit_cost,
+		       p.id, p.code, p.name, p.unit
+		FROM purchase_order_lines l
+		JOIN products p ON l.product_id = p.id
+		WHERE l.purchase_order_id = ?
+		ORDER BY l.id
+	`, purchaseOrderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []models.PurchaseOrderLine
+	for rows.Next() {
+		var l models.PurchaseOrderLine
+		var p models.Product
+		if err := rows.Scan(
+			&l.ID, &l.PurchaseOrderID, &l.ProductID, &l.Quantity, &l.ReceivedQuantity, &l.UnitCost,
+			&p.ID, &p.Code, &p.Name, &p.Unit,
+		); err != nil {
+			return nil, err
+		}
+
+		l.OutstandingQuantity = l.Quantity - l.ReceivedQuantity
+		l.Product = &p
+		lines = append(lines, l)
+	}
+
+	return lines, nil
+}
+
+func (r *PurchaseOrderRepository) Create(req models.CreatePurchaseOrderRequest, userID int64) (int64, error) {
+	var expectedDate interface{}
+	if req.ExpectedDate != "" {
+		expectedDate = req.ExpectedDate
+	}
+
+	var id int64
+	err := conn(r.db, r.tx).QueryRow(`
+		INSERT INTO purchase_orders (supplier_id, warehouse_id, expected_date, note, created_by)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id
+	`, req.SupplierID, req.WarehouseID, expectedDate, req.Note, userID).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, line := range req.Lines {
+		_, err := conn(r.db, r.tx).Exec(`
+			INSERT INTO purchase_order_lines (purchase_order_id, product_id, quantity, unit_cost)
+			VALUES (?, ?, ?, ?)
+		`, id, line.ProductID, line.Quantity, line.UnitCost)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return id, nil
+}
+
+// UpdateStatus moves an order to a new status if it is currently in one of
+// the given statuses. It reports whether the order was updated.
+func (r *PurchaseOrderRepository) UpdateStatus(id int64, status models.PurchaseOrderStatus, from ...models.PurchaseOrderStatus) (bool, error) {
+	query := "UPDATE purchase_orders SET status = ?"
+	switch status {
+	case models.PurchaseOrderStatusOrdered:
+		query += ", ordered_at = CURRENT_TIMESTAMP"
+	case models.PurchaseOrderStatusClosed:
+		query += ", closed_at = CURRENT_TIMESTAMP"
+	}
+
+	placeholders := make([]string, len(from))
+	args := []interface{}{status, id}
+	for i, f := range from {
+		placeholders[i] = "?"
+		args = append(args, f)
+	}
+	query += " WHERE id = ? AND status IN (" + strings.Join(placeholders, ", ") + ")"
+
+	result, err := conn(r.db, r.tx).Exec(query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// SetExpectedDateFromLeadTime fills in a missing expected date as today plus
+// the supplier's lead time.
+func (r *PurchaseOrderRepository) SetExpectedDateFromLeadTime(id int64) error {
+	var leadTimeDays int
+	err := conn(r.db, r.tx).QueryRow(`
+		SELECT s.lead_time_days FROM purchase_orders po
+		JOIN suppliers s ON po.supplier_id = s.id
+		WHERE po.id = ?
+	`, id).Scan(&leadTimeDays)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn(r.db, r.tx).Exec(
+		"UPDATE purchase_orders SET expected_date = ? WHERE id = ? AND expected_date IS NULL",
+		today().AddDate(0, 0, leadTimeDays).Format(time.DateOnly), id,
+	)
+	return err
+}
+
+// Receive adds a received quantity to a line of the order, unless it would
+// exceed the ordered quantity. It reports whether the line was updated.
+func (r *PurchaseOrderRepository) Receive(purchaseOrderID, lineID int64, quantity int) (bool, error) {
+	result, err := conn(r.db, r.tx).Exec(`
+		UPDATE purchase_order_lines SET received_quantity = received_quantity + ?
+		WHERE id = ? AND purchase_order_id = ? AND received_quantity + ? <= quantity
+	`, quantity, lineID, purchaseOrderID, quantity)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *PurchaseOrderRepository) AddReceipt(lineID, transactionID int64, quantity int) error {
+	_, err := conn(r.db, r.tx).Exec(
+		"INSERT INTO purchase_order_receipts (line_id, transaction_id, quantity) VALUES (?, ?, ?)",
+		lineID, transactionID, quantity,
+	)
+	return err
+}
+
+// OutstandingQuantity returns the total quantity still to be received.
+func (r *PurchaseOrderRepository) OutstandingQuantity(purchaseOrderID int64) (int, error) {
+	var outstanding int
+	err := conn(r.db, r.tx).QueryRow(
+		"SELECT COALESCE(SUM(quantity - received_quantity), 0) FROM purchase_order_lines WHERE purchase_order_id = ?",
+		purchaseOrderID,
+	).Scan(&outstanding)
+	return outstanding, err
+}
+
+// DeleteDraft removes an order and its lines if it is still a draft. It
+// reports whether the order was deleted.
+func (r *PurchaseOrderRepository) DeleteDraft(id int64) (bool, error) {
+	// The lines go first so that they never refer to a missing order
+	_, err := conn(r.db, r.tx).Exec(`
+		DELETE FROM purchase_order_lines
+		WHERE purchase_order_id IN (SELECT id FROM purchase_orders WHERE id = ? AND status = 'draft')
+	`, id)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := conn(r.db, r.tx).Exec("DELETE FROM purchase_orders WHERE id = ? AND status = 'draft'", id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
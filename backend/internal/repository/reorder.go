@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"database/sql"
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+)
+
+type ReorderRepository struct {
+	db *database.DB
+	tx *sql.Tx
+}
+
+func NewReorderRepository(db *database.DB) *ReorderRepository {
+	return &ReorderRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *ReorderRepository) WithTx(tx *sql.Tx) *ReorderRepository {
+	return &ReorderRepository{db: r.db, tx: tx}
+}
+
+func (r *ReorderRepository) FindByProduct(productID int64) ([]models.ReorderSetting, error) {
+	rows, err := conn(r.db, r.tx).Query(`
+		SELECT id, product_id, warehouse_id, min_quantity, max_quantity, reorder_point
+		FROM reorder_settings
+		WHERE product_id = ?
+		ORDER BY warehouse_id IS NOT NULL, warehouse_id
+	`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var settings []models.ReorderSetting
+	for rows.Next() {
+		var rs models.ReorderSetting
+		if err := rows.Scan(
+			&rs.ID, &rs.ProductID, &rs.WarehouseID, &rs.MinQuantity, &rs.MaxQuantity, &rs.ReorderPoint,
+		); err != nil {
+			return nil, err
+		}
+		settings = append(settings, rs)
+	}
+
+	return settings, nil
+}
+
+// Find returns the product default (warehouseID 0) or a warehouse override.
+func (r *ReorderRepository) Find(productID, warehouseID int64) (*models.ReorderSetting, error) {
+	scope, args := warehouseScope(warehouseID)
+
+	var rs models.ReorderSetting
+	err := conn(r.db, r.tx).QueryRow(`
+		SELECT id, product_id, warehouse_id, min_quantity, max_quantity, reorder_point
+		FROM reorder_settings WHERE product_id = ? AND `+scope,
+		append([]interface{}{productID}, args...)...,
+	).Scan(
+		&rs.ID, &rs.ProductID, &rs.WarehouseID, &rs.MinQuantity, &rs.MaxQuantity, &rs.ReorderPoint,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+	return &rs, nil
+}
+
+// Save creates or replaces the product default (no warehouse) or a
+// warehouse override.
+func (r *ReorderRepository) Save(productID int64, req models.ReorderSettingRequest) (*models.ReorderSetting, error) {
+	scope, args := warehouseScope(req.WarehouseID)
+
+	result, err := conn(r.db, r.tx).Exec(`
+		UPDATE reorder_settings SET min_quantity = ?, max_quantity = ?, reorder_point = ?
+		WHERE product_id = ? AND `+scope,
+		append([]interface{}{req.MinQuantity, req.MaxQuantity, req.ReorderPoint, productID}, args...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if affected == 0 {
+		_, err = conn(r.db, r.tx).Exec(`
+			INSERT INTO reorder_settings (product_id, warehouse_id, min_quantity, max_quantity, reorder_point)
+			VALUES (?, ?, ?, ?, ?)
+		`, productID, nullableID(req.WarehouseID), req.MinQuantity, req.MaxQuantity, req.ReorderPoint)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return r.Find(productID, req.WarehouseID)
+}
+
+func (r *ReorderRepository) Delete(productID, warehouseID int64) error {
+	scope, args := warehouseScope(warehouseID)
+
+	_, err := conn(r.db, r.tx).Exec(
+		"DELETE FROM reorder_settings WHERE product_id = ? AND "+scope,
+		append([]interface{}{productID}, args...)...,
+	)
+	return err
+}
+
+// warehouseScope returns the condition that selects the product default
+// (warehouseID 0) or a warehouse override, with its arguments.
+func warehouseScope(warehouseID int64) (string, []interface{}) {
+	if warehouseID > 0 {
+		return "warehouse_id = ?", []interface{}{warehouseID}
+	}
+	return "warehouse_id IS NULL", nil
+}
+
+// lowStockQuery selects every (product, warehouse) pair that has stock or a
+// warehouse override, resolved against the override, the product default
+// and finally the fallback reorder point.
+const lowStockQuery = `
+	WITH pairs AS (
+		SELECT product_id, warehouse_id FROM stock
+		UNION
+		SELECT product_id, warehouse_id FROM reorder_settings WHERE warehouse_id IS NOT NULL
+	),
+	levels AS (
+		SELECT pr.product_id, pr.warehouse_id,
+		       COALESCE(s.quantity, 0) AS quantity,
+		       COALESCE(o.min_quantity, d.min_quantity, 0) AS min_quantity,
+		       COALESCE(o.max_quantity, d.max_quantity, 0) AS max_quantity,
+		       COALESCE(o.reorder_point, d.reorder_point, ?) AS reorder_point
+		FROM pairs pr
+		LEFT JOIN stock s ON s.product_id = pr.product_id AND s.warehouse_id = pr.warehouse_id
+		LEFT JOIN reorder_settings o ON o.product_id = pr.product_id AND o.warehouse_id = pr.warehouse_id
+		LEFT JOIN reorder_settings d ON d.product_id = pr.product_id AND d.warehouse_id IS NULL
+	)
+`
+
+func fallbackReorderPoint(fallback int) interface{} {
+	if fallback > 0 {
+		return fallback
+	}
+	return nil
+}
+
+// FindLowStock lists stock below its reorder point. fallback applies to
+// stock without any reorder setting; 0 disables it.
+func (r *ReorderRepository) FindLowStock(filter models.LowStockFilter, fallback int) ([]models.LowStockItem, error) {
+	query := lowStockQuery + `
+		SELECT l.product_id, l.warehouse_id, l.quantity, l.min_quantity, l.max_quantity, l.reorder_point,
+		       p.id, p.code, p.name, p.unit,
+		       w.id, w.name
+		FROM levels l
+		JOIN products p ON l.product_id = p.id
+		JOIN warehouses w ON l.warehouse_id = w.id
+		WHERE l.reorder_point IS NOT NULL AND l.quantity < l.reorder_point
+	`
+	args := []interface{}{fallbackReorderPoint(fallback)}
+
+	if filter.ProductID > 0 {
+		query += " AND l.product_id = ?"
+		args = append(args, filter.ProductID)
+	}
+
+	if filter.WarehouseID > 0 {
+		query += " AND l.warehouse_id = ?"
+		args = append(args, filter.WarehouseID)
+	}
+
+	if len(filter.WarehouseIDs) > 0 {
+		query += " AND l.warehouse_id IN (" + questionMarks(len(filter.WarehouseIDs)) + ")"
+		for _, id := range filter.WarehouseIDs {
+			args = append(args, id)
+		}
+	}
+
+	query += " ORDER BY l.reorder_point - l.quantity DESC, p.name, w.name"
+
+	rows, err := conn(r.db, r.tx).Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.LowStockItem
+	for rows.Next() {
+		var item models.LowStockItem
+		var p models.Product
+		var w models.Warehouse
+
+		if err := rows.Scan(
+			&item.ProductID, &item.WarehouseID, &item.Quantity, &item.MinQuantity, &item.MaxQuantity, &item.ReorderPoint,
+			&p.ID, &p.Code, &p.Name, &p.Unit,
+			&w.ID, &w.Name,
+		); err != nil {
+			return nil, err
+		}
+
+		item.Shortfall = item.ReorderPoint - item.Quantity
+		target := item.ReorderPoint
+		if item.MaxQuantity > target {
+			target = item.MaxQuantity
+		}
+		item.SuggestedOrderQuantity = target - item.Quantity
+		if item.SuggestedOrderQuantity < item.MinQuantity {
+			item.SuggestedOrderQuantity = item.MinQuantity
+		}
+
+		item.Product = &p
+		item.Warehouse = &w
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// CountLowStock returns the number of distinct products that are below their
+// reorder point in at least one warehouse.
+func (r *ReorderRepository) CountLowStock(fallback int) (int, error) {
+	var count int
+	err := conn(r.db, r.tx).QueryRow(lowStockQuery+`
+		SELECT COUNT(DISTINCT l.product_id)
+		FROM levels l
+		JOIN products p ON l.product_id = p.id
+		JOIN warehouses w ON l.warehouse_id = w.id
+		WHERE l.reorder_point IS NOT NULL AND l.quantity < l.reorder_point
+	`, fallbackReorderPoint(fallback)).Scan(&count)
+	return count, err
+}
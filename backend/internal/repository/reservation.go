@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+)
+
+// activeReservation matches reservations that still hold stock.
+const activeReservation = "r.released_at IS NULL AND r.quantity > 0 AND (r.expires_at IS NULL OR r.expires_at > CURRENT_TIMESTAMP)"
+
+type ReservationRepository struct {
+	db *database.DB
+	tx *sql.Tx
+}
+
+func NewReservationRepository(db *database.DB) *ReservationRepository {
+	return &ReservationRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *ReservationRepository) WithTx(tx *sql.Tx) *ReservationRepository {
+	return &ReservationRepository{db: r.db, tx: tx}
+}
+
+const reservationQuery = `
+	SELECT r.id, r.product_id, r.warehouse_id, r.quantity, r.owner_ref, r.note, r.expires_at,
+	       r.created_by, r.created_at, r.released_at, ` + activeReservation + `,
+	       p.id, p.code, p.name, p.unit,
+	       w.id, w.name
+	FROM reservations r
+	JOIN products p ON r.product_id = p.id
+	JOIN warehouses w ON r.warehouse_id = w.id
+`
+
+func scanReservation(scanner interface{ Scan(...interface{}) error }) (*models.Reservation, error) {
+	var res models.Reservation
+	var note *string
+	var p models.Product
+	var w models.Warehouse
+
+	if err := scanner.Scan(
+		&res.ID, &res.ProductID, &res.WarehouseID, &res.Quantity, &res.OwnerRef, &note, &res.ExpiresAt,
+		&res.CreatedBy, &res.CreatedAt, &res.ReleasedAt, &res.Active,
+		&p.ID, &p.Code, &p.Name, &p.Unit,
+		&w.ID, &w.Name,
+	); err != nil {
+		return nil, err
+	}
+
+	if note != nil {
+		res.Note = *note
+	}
+
+	res.Product = &p
+	res.Warehouse = &w
+	return &res, nil
+}
+
+func (r *ReservationRepository) FindAll(filter models.ReservationFilter) ([]models.Reservation, error) {
+	query := reservationQuery + " WHERE 1=1"
+	var args []interface{}
+
+	if !filter.All {
+		query += " AND " + activeReservation
+	}
+
+	if filter.ProductID > 0 {
+		query += " AND r.product_id = ?"
+		args = append(args, filter.ProductID)
+	}
+
+	if filter.WarehouseID > 0 {
+		query += " AND r.warehouse_id = ?"
+		args = append(args, filter.WarehouseID)
+	}
+
+	if filter.OwnerRef != "" {
+		query += " AND r.owner_ref = ?"
+		args = append(args, filter.OwnerRef)
+	}
+
+	query += " ORDER BY r.created_at DESC, r.id DESC"
+
+	rows, err := conn(r.db, r.tx).Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reservations []models.Reservation
+	for rows.Next() {
+		res, err := scanReservation(rows)
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, *res)
+	}
+
+	return reservations, nil
+}
+
+func (r *ReservationRepository) FindByID(id int64) (*models.Reservation, error) {
+	return scanReservation(conn(r.db, r.tx).QueryRow(reservationQuery+" WHERE r.id = ?", id))
+}
+
+func (r *ReservationRepository) Create(req models.CreateReservationRequest, userID int64) (int64, error) {
+	var expiresAt interface{}
+	if req.ExpiresAt != nil {
+		// Stored in the same format as CURRENT_TIMESTAMP so the two compare
+		expiresAt = req.ExpiresAt.UTC().Format(time.DateTime)
+	}
+
+	var id int64
+	err := conn(r.db, r.tx).QueryRow(`
+		INSERT INTO reservations (product_id, warehouse_id, quantity, owner_ref, note, expires_at, created_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, req.ProductID, req.WarehouseID, req.Quantity, req.OwnerRef, req.Note, expiresAt, userID).Scan(&id)
+	return id, err
+}
+
+// Release ends an active reservation. It reports whether one was released.
+func (r *ReservationRepository) Release(id int64) (bool, error) {
+	result, err := conn(r.db, r.tx).Exec(
+		"UPDATE reservations SET released_at = CURRENT_TIMESTAMP WHERE id = ? AND released_at IS NULL",
+		id,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// ReservedQuantity returns the stock of a product in a warehouse that is
+// held by active reservations and open sales orders, leaving out whatever
+// belongs to exceptOwner.
+func (r *ReservationRepository) ReservedQuantity(productID, warehouseID int64, exceptOwner string) (int, error) {
+	var reserved int
+	err := conn(r.db, r.tx).QueryRow(`
+		SELECT
+			COALESCE((
+				SELECT SUM(r.quantity) FROM reservations r
+				WHERE r.product_id = ? AND r.warehouse_id = ? AND r.owner_ref != ? AND `+activeReservation+`
+			), 0) +
+			COALESCE((
+				SELECT SUM(l.allocated_quantity - l.shipped_quantity)
+				FROM sales_order_lines l
+				JOIN sales_orders so ON l.sales_order_id = so.id
+				WHERE l.product_id = ? AND so.warehouse_id = ? AND so.status IN `+openSalesOrderStatuses+`
+				  AND 'sales_order:' || so.id != ?
+			), 0)
+	`, productID, warehouseID, exceptOwner, productID, warehouseID, exceptOwner).Scan(&reserved)
+	return reserved, err
+}
+
+// Consume reduces the owner's active reservations of a product in a
+// warehouse by up to quantity, oldest first, and records what it took
+// against transactionID. Reservations used up in full are released.
+func (r *ReservationRepository) Consume(transactionID int64, ownerRef string, productID, warehouseID int64, quantity int) error {
+	rows, err := conn(r.db, r.tx).Query(`
+		SELECT r.id, r.quantity FROM reservations r
+		WHERE r.owner_ref = ? AND r.product_id = ? AND r.warehouse_id = ? AND `+activeReservation+`
+		ORDER BY r.created_at, r.id
+	`, ownerRef, productID, warehouseID)
+	if err != nil {
+		return err
+	}
+
+	type held struct {
+		id       int64
+		quantity int
+	}
+	var reservations []held
+	for rows.Next() {
+		var h held
+		if err := rows.Scan(&h.id, &h.quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		reservations = append(reservations, h)
+	}
+	rows.Close()
+
+	for _, h := range reservations {
+		if quantity == 0 {
+			break
+		}
+
+		used := min(h.quantity, quantity)
+		quantity -= used
+
+		query := "UPDATE reservations SET quantity = quantity - ? WHERE id = ?"
+		if used == h.quantity {
+			query = "UPDATE reservations SET quantity = quantity - ?, released_at = CURRENT_TIMESTAMP WHERE id = ?"
+		}
+		if _, err := conn(r.db, r.tx).Exec(query, used, h.id); err != nil {
+			return err
+		}
+		if _, err := conn(r.db, r.tx).Exec(
+			"INSERT INTO reservation_consumptions (transaction_id, reservation_id, quantity) VALUES (?, ?, ?)",
+			transactionID, h.id, used,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Restore gives back what Consume took against transactionID. A
+// reservation that the transaction used up is active again; one released
+// by hand since keeps its release.
+func (r *ReservationRepository) Restore(transactionID int64) error {
+	_, err := conn(r.db, r.tx).Exec(`
+		UPDATE reservations SET
+			released_at = CASE WHEN quantity = 0 THEN NULL ELSE released_at END,
+			quantity = quantity + (
+				SELECT c.quantity FROM reservation_consumptions c
+				WHERE c.transaction_id = ? AND c.reservation_id = reservations.id
+			)
+		WHERE id IN (SELECT reservation_id FROM reservation_consumptions WHERE transaction_id = ?)
+	`, transactionID, transactionID)
+	return err
+}
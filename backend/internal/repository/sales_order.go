@@ -0,0 +1,287 @@
+package repository
+
+import (
+	"database/sql"
+	"strings"
+
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+)
+
+// openSalesOrderStatuses are the statuses in which an order holds allocated
+// stock.
+const openSalesOrderStatuses = "('confirmed', 'partially_shipped')"
+
+type SalesOrderRepository struct {
+	db *database.DB
+	tx *sql.Tx
+}
+
+func NewSalesOrderRepository(db *database.DB) *SalesOrderRepository {
+	return &SalesOrderRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *SalesOrderRepository) WithTx(tx *sql.Tx) *SalesOrderRepository {
+	return &SalesOrderRepository{db: r.db, tx: tx}
+}
+
+const salesOrderQuery = `
+	SELECT so.id, so.customer_id, so.warehouse_id, so.status, so.note, so.created_by,
+	       so.created_at, so.confirmed_at, so.closed_at,
+	       c.id, c.name,
+	       w.id, w.name
+	FROM sales_orders so
+	JOIN customers c ON so.customer_id = c.id
+	JOIN warehouses w ON so.warehouse_id = w.id
+`
+
+func scanSalesOrder(scanner interface{ Scan(...interface{}) error }) (*models.SalesOrder, error) {
+	var so models.SalesOrder
+	var note *string
+	var c models.Customer
+	var w models.Warehouse
+
+	if err := scanner.Scan(
+		&so.ID, &so.CustomerID, &so.WarehouseID, &so.Status, &note, &so.CreatedBy,
+		&so.CreatedAt, &so.ConfirmedAt, &so.ClosedAt,
+		&c.ID, &c.Name,
+		&w.ID, &w.Name,
+	); err != nil {
+		return nil, err
+	}
+
+	if note != nil {
+		so.Note = *note
+	}
+
+	so.Customer = &c
+	so.Warehouse = &w
+	return &so, nil
+}
+
+func (r *SalesOrderRepository) FindAll(filter models.SalesOrderFilter) ([]models.SalesOrder, error) {
+	query := salesOrderQuery + " WHERE 1=1"
+	var args []interface{}
+
+	if filter.Status != "" {
+		query += " AND so.status = ?"
+		args = append(args, filter.Status)
+	}
+
+	if filter.CustomerID > 0 {
+		query += " AND so.customer_id = ?"
+		args = append(args, filter.CustomerID)
+	}
+
+	if filter.ProductID > 0 {
+		query += " AND so.id IN (SELECT sales_order_id FROM sales_order_lines WHERE product_id = ?)"
+		args = append(args, filter.ProductID)
+	}
+
+	query += " ORDER BY so.created_at DESC, so.id DESC"
+
+	rows, err := conn(r.db, r.tx).Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []models.SalesOrder
+	for rows.Next() {
+		so, err := scanSalesOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, *so)
+	}
+
+	return orders, nil
+}
+
+func (r *SalesOrderRepository) FindByID(id int64) (*models.SalesOrder, error) {
+	return scanSalesOrder(conn(r.db, r.tx).QueryRow(salesOrderQuery+" WHERE so.id = ?", id))
+}
+
+func (r *SalesOrderRepository) FindLines(salesOrderID int64) ([]models.SalesOrderLine, error) {
+	rows, err := conn(r.db, r.tx).Query(`
+		SELECT l.id, l.sales_order_id, l.product_id, l.quantity, l.allocated_quantity, l.shipped_quantity, l.unit_price,
+		       p.id, p.code, p.name, p.unit
+		FROM sales_order_lines l
+		JOIN products p ON l.product_id = p.id
+		WHERE l.sales_order_id = ?
+		ORDER BY l.id
+	`, salesOrderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []models.SalesOrderLine
+	for rows.Next() {
+		var l models.SalesOrderLine
+		var p models.Product
+		if err := rows.Scan(
+			&l.ID, &l.SalesOrderID, &l.ProductID, &l.Quantity, &l.AllocatedQuantity, &l.ShippedQuantity, &l.UnitPrice,
+			&p.ID, &p.Code, &p.Name, &p.Unit,
+		); err != nil {
+			return nil, err
+		}
+
+		l.BackorderedQuantity = l.Quantity - l.AllocatedQuantity
+		l.Product = &p
+		lines = append(lines, l)
+	}
+
+	return lines, nil
+}
+
+func (r *SalesOrderRepository) Create(req models.CreateSalesOrderRequest, userID int64) (int64, error) {
+	var id int64
+	err := conn(r.db, r.tx).QueryRow(`
+		INSERT INTO sales_orders (customer_id, warehouse_id, note, created_by)
+		VALUES (?, ?, ?, ?)
+		RETURNING id
+	`, req.CustomerID, req.WarehouseID, req.Note, userID).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, line := range req.Lines {
+		_, err := conn(r.db, r.tx).Exec(`
+			INSERT INTO sales_order_lines (sales_order_id, product_id, quantity, unit_price)
+			VALUES (?, ?, ?, ?)
+		`, id, line.ProductID, line.Quantity, line.UnitPrice)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return id, nil
+}
+
+// UpdateStatus moves an order to a new status if it is currently in one of
+// the given statuses. It reports whether the order was updated.
+func (r *SalesOrderRepository) UpdateStatus(id int64, status models.SalesOrderStatus, from ...models.SalesOrderStatus) (bool, error) {
+	query := "UPDATE sales_orders SET status = ?"
+	switch status {
+	case models.SalesOrderStatusConfirmed:
+		query += ", confirmed_at = CURRENT_TIMESTAMP"
+	case models.SalesOrderStatusShipped, models.SalesOrderStatusCancelled:
+		query += ", closed_at = CURRENT_TIMESTAMP"
+	}
+
+	placeholders := make([]string, len(from))
+	args := []interface{}{status, id}
+	for i, f := range from {
+		placeholders[i] = "?"
+		args = append(args, f)
+	}
+	query += " WHERE id = ? AND status IN (" + strings.Join(placeholders, ", ") + ")"
+
+	result, err := conn(r.db, r.tx).Exec(query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// HasStatus reports whether the order is in one of the given statuses. It
+// is a no-op update so that the calling transaction takes the write lock
+// before it reads stock levels.
+func (r *SalesOrderRepository) HasStatus(id int64, statuses ...models.SalesOrderStatus) (bool, error) {
+	placeholders := make([]string, len(statuses))
+	args := []interface{}{id}
+	for i, st := range statuses {
+		placeholders[i] = "?"
+		args = append(args, st)
+	}
+
+	result, err := conn(r.db, r.tx).Exec(
+		"UPDATE sales_orders SET status = status WHERE id = ? AND status IN ("+strings.Join(placeholders, ", ")+")",
+		args...,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// Allocate adds quantity to a line's allocation.
+func (r *SalesOrderRepository) Allocate(lineID int64, quantity int) error {
+	_, err := conn(r.db, r.tx).Exec(
+		"UPDATE sales_order_lines SET allocated_quantity = allocated_quantity + ? WHERE id = ?",
+		quantity, lineID,
+	)
+	return err
+}
+
+// Ship adds a shipped quantity to a line of the order, unless it would
+// exceed the allocated quantity. It reports whether the line was updated.
+func (r *SalesOrderRepository) Ship(salesOrderID, lineID int64, quantity int) (bool, error) {
+	result, err := conn(r.db, r.tx).Exec(`
+		UPDATE sales_order_lines SET shipped_quantity = shipped_quantity + ?
+		WHERE id = ? AND sales_order_id = ? AND shipped_quantity + ? <= allocated_quantity
+	`, quantity, lineID, salesOrderID, quantity)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *SalesOrderRepository) AddShipment(lineID, transactionID int64, quantity int) error {
+	_, err := conn(r.db, r.tx).Exec(
+		"INSERT INTO sales_order_shipments (line_id, transaction_id, quantity) VALUES (?, ?, ?)",
+		lineID, transactionID, quantity,
+	)
+	return err
+}
+
+// UnshippedQuantity returns the total quantity still to be shipped.
+func (r *SalesOrderRepository) UnshippedQuantity(salesOrderID int64) (int, error) {
+	var unshipped int
+	err := conn(r.db, r.tx).QueryRow(
+		"SELECT COALESCE(SUM(quantity - shipped_quantity), 0) FROM sales_order_lines WHERE sales_order_id = ?",
+		salesOrderID,
+	).Scan(&unshipped)
+	return unshipped, err
+}
+
+// DeleteDraft removes an order and its lines if it is still a draft. It
+// reports whether the order was deleted.
+func (r *SalesOrderRepository) DeleteDraft(id int64) (bool, error) {
+	// The lines go first so that they never refer to a missing order
+	_, err := conn(r.db, r.tx).Exec(`
+		DELETE FROM sales_order_lines
+		WHERE sales_order_id IN (SELECT id FROM sales_orders WHERE id = ? AND status = 'draft')
+	`, id)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := conn(r.db, r.tx).Exec("DELETE FROM sales_orders WHERE id = ? AND status = 'draft'", id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"database/sql"
+
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+)
+
+type SerialRepository struct {
+	db *database.DB
+	tx *sql.Tx
+}
+
+func NewSerialRepository(db *database.DB) *SerialRepository {
+	return &SerialRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *SerialRepository) WithTx(tx *sql.Tx) *SerialRepository {
+	return &SerialRepository{db: r.db, tx: tx}
+}
+
+func (r *SerialRepository) FindByNumber(productID int64, serialNumber string) (*models.SerialNumber, error) {
+	var sn models.SerialNumber
+	err := conn(r.db, r.tx).QueryRow(`
+		SELECT id, product_id, serial_number, warehouse_id, status, created_at, updated_at
+		FROM serial_numbers WHERE product_id = ? AND serial_number = ?
+	`, productID, serialNumber).Scan(
+		&sn.ID, &sn.ProductID, &sn.SerialNumber, &sn.WarehouseID, &sn.Status, &sn.CreatedAt, &sn.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+	return &sn, nil
+}
+
+// FindAllByNumber returns every product unit carrying the serial number.
+func (r *SerialRepository) FindAllByNumber(serialNumber string) ([]models.SerialNumber, error) {
+	rows, err := conn(r.db, r.tx).Query(`
+		SELECT s.id, s.product_id, s.serial_number, s.warehouse_id, s.status, s.created_at, s.updated_at,
+		       p.id, p.code, p.name, p.unit
+		FROM serial_numbers s
+		JOIN products p ON s.product_id = p.id
+		WHERE s.serial_number = ?
+		ORDER BY p.name
+	`, serialNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var serials []models.SerialNumber
+	for rows.Next() {
+		var sn models.SerialNumber
+		var p models.Product
+		if err := rows.Scan(
+			&sn.ID, &sn.ProductID, &sn.SerialNumber, &sn.WarehouseID, &sn.Status, &sn.CreatedAt, &sn.UpdatedAt,
+			&p.ID, &p.Code, &p.Name, &p.Unit,
+		); err != nil {
+			return nil, err
+		}
+		sn.Product = &p
+		serials = append(serials, sn)
+	}
+
+	return serials, nil
+}
+
+// FindMovements returns the transactions that moved a serial number, oldest
+// first. When warehouseIDs is not empty only movements in those warehouses
+// are returned.
+func (r *SerialRepository) FindMovements(serialID int64, warehouseIDs []int64) ([]models.Transaction, error) {
+	args := []interface{}{serialID}
+	where := "ts.serial_id = ?"
+	if len(warehouseIDs) > 0 {
+		where += " AND t.warehouse_id IN (" + questionMarks(len(warehouseIDs)) + ")"
+		for _, id := range warehouseIDs {
+			args = append(args, id)
+		}
+	}
+
+	rows, err := conn(r.db, r.tx).Query(`
+		SELECT t.id, t.product_id, t.warehouse_id, t.type, t.quantity, t.note, t.user_id, t.related_transaction_id, t.created_at,
+		       w.id, w.name,
+		       u.id, u.username
+		FROM transaction_serials ts
+		JOIN transactions t ON ts.transaction_id = t.id
+		JOIN warehouses w ON t.warehouse_id = w.id
+		JOIN users u ON t.user_id = u.id
+		WHERE `+where+`
+		ORDER BY t.created_at, t.id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []models.Transaction
+	for rows.Next() {
+		var t models.Transaction
+		var note *string
+		var w models.Warehouse
+		var u models.User
+
+		if err := rows.Scan(
+			&t.ID, &t.ProductID, &t.WarehouseID, &t.Type, &t.Quantity, &note, &t.UserID, &t.RelatedTransactionID, &t.CreatedAt,
+			&w.ID, &w.Name,
+			&u.ID, &u.Username,
+		); err != nil {
+			return nil, err
+		}
+
+		if note != nil {
+			t.Note = *note
+		}
+
+		t.Warehouse = &w
+		t.User = &u
+		transactions = append(transactions, t)
+	}
+
+	return transactions, nil
+}
+
+// Receive puts a serial number into stock at a warehouse, creating it if it
+// has never been seen before.
+func (r *SerialRepository) Receive(productID, warehouseID int64, serialNumber string) (int64, error) {
+	_, err := conn(r.db, r.tx).Exec(`
+		INSERT INTO serial_numbers (product_id, serial_number, warehouse_id, status)
+		VALUES (?, ?, ?, 'in_stock')
+		ON CONFLICT(product_id, serial_number) DO UPDATE SET
+			warehouse_id = excluded.warehouse_id,
+			status = 'in_stock',
+			updated_at = CURRENT_TIMESTAMP
+	`, productID, serialNumber, warehouseID)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = conn(r.db, r.tx).QueryRow(
+		"SELECT id FROM serial_numbers WHERE product_id = ? AND serial_number = ?",
+		productID, serialNumber,
+	).Scan(&id)
+	return id, err
+}
+
+// Move changes the location and status of a serial number. A nil warehouse
+// means the unit has left the company.
+func (r *SerialRepository) Move(id int64, warehouseID *int64, status models.SerialStatus) error {
+	_, err := conn(r.db, r.tx).Exec(`
+		UPDATE serial_numbers SET warehouse_id = ?, status = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, warehouseID, status, id)
+	return err
+}
+
+// FindByTransaction returns the serial numbers a transaction moved.
+func (r *SerialRepository) FindByTransaction(transactionID int64) ([]models.SerialNumber, error) {
+	rows, err := conn(r.db, r.tx).Query(`
+		SELECT s.id, s.product_id, s.serial_number, s.warehouse_id, s.status, s.created_at, s.updated_at
+		FROM transaction_serials ts
+		JOIN serial_numbers s ON ts.serial_id = s.id
+		WHERE ts.transaction_id = ?
+		ORDER BY s.serial_number
+	`, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var serials []models.SerialNumber
+	for rows.Next() {
+		var sn models.SerialNumber
+		if err := rows.Scan(&sn.ID, &sn.ProductID, &sn.SerialNumber, &sn.WarehouseID, &sn.Status, &sn.CreatedAt, &sn.UpdatedAt); err != nil {
+			return nil, err
+		}
+		serials = append(serials, sn)
+	}
+
+	return serials, rows.Err()
+}
+
+func (r *SerialRepository) AddToTransaction(transactionID, serialID int64) error {
+	_, err := conn(r.db, r.tx).Exec(
+		"INSERT INTO transaction_serials (transaction_id, serial_id) VALUES (?, ?)",
+		transactionID, serialID,
+	)
+	return err
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+)
+
+type SessionRepository struct {
+	db *database.DB
+	tx *sql.Tx
+}
+
+func NewSessionRepository(db *database.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *SessionRepository) WithTx(tx *sql.Tx) *SessionRepository {
+	return &SessionRepository{db: r.db, tx: tx}
+}
+
+func (r *SessionRepository) FindByID(id int64) (*models.Session, error) {
+	var s models.Session
+	var userAgent, ipAddress *string
+
+	err := conn(r.db, r.tx).QueryRow(`
+		SELECT id, user_id, user_agent, ip_address, two_factor, created_at, last_used_at, expires_at, revoked_at
+		FROM sessions WHERE id = ?
+	`, id).Scan(&s.ID, &s.UserID, &userAgent, &ipAddress, &s.TwoFactor, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt, &s.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if userAgent != nil {
+		s.UserAgent = *userAgent
+	}
+	if ipAddress != nil {
+		s.IPAddress = *ipAddress
+	}
+
+	return &s, nil
+}
+
+// Create starts a session with its first refresh token.
+func (r *SessionRepository) Create(userID int64, userAgent, ipAddress string, twoFactor bool, expiresAt time.Time, tokenHash string) (int64, error) {
+	var id int64
+	err := inTx(r.db, r.tx, func(tx *sql.Tx) error {
+		err := tx.QueryRow(
+			"INSERT INTO sessions (user_id, user_agent, ip_address, two_factor, expires_at) VALUES (?, ?, ?, ?, ?) RETURNING id",
+			userID, userAgent, ipAddress, twoFactor, expiresAt.UTC().Format(time.DateTime),
+		).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			"INSERT INTO refresh_tokens (session_id, token_hash) VALUES (?, ?)",
+			id, tokenHash,
+		)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// FindRefreshToken returns the session a refresh token belongs to and
+// whether the token has already been used.
+func (r *SessionRepository) FindRefreshToken(tokenHash string) (sessionID int64, used bool, err error) {
+	var usedAt *time.Time
+	err = conn(r.db, r.tx).QueryRow(
+		"SELECT session_id, used_at FROM refresh_tokens WHERE token_hash = ?",
+		tokenHash,
+	).Scan(&sessionID, &usedAt)
+	return sessionID, usedAt != nil, err
+}
+
+// Rotate marks a refresh token as used and stores its successor. It reports
+// false if the token was used concurrently.
+func (r *SessionRepository) Rotate(sessionID int64, oldHash, newHash string) (bool, error) {
+	rotated := false
+	err := inTx(r.db, r.tx, func(tx *sql.Tx) error {
+		result, err := tx.Exec(
+			"UPDATE refresh_tokens SET used_at = CURRENT_TIMESTAMP WHERE token_hash = ? AND used_at IS NULL",
+			oldHash,
+		)
+		if err != nil {
+			return err
+		}
+		if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+			return err
+		}
+
+		if _, err := tx.Exec(
+			"INSERT INTO refresh_tokens (session_id, token_hash) VALUES (?, ?)",
+			sessionID, newHash,
+		); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			"UPDATE sessions SET last_used_at = CURRENT_TIMESTAMP WHERE id = ?",
+			sessionID,
+		); err != nil {
+			return err
+		}
+
+		rotated = true
+		return nil
+	})
+	return rotated, err
+}
+
+// SetTwoFactor records that the session's user has just confirmed a
+// two-factor code.
+func (r *SessionRepository) SetTwoFactor(id int64) error {
+	_, err := conn(r.db, r.tx).Exec("UPDATE sessions SET two_factor = ? WHERE id = ?", true, id)
+	return err
+}
+
+func (r *SessionRepository) Revoke(id int64) error {
+	_, err := conn(r.db, r.tx).Exec(
+		"UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE id = ? AND revoked_at IS NULL",
+		id,
+	)
+	return err
+}
+
+// RevokeAllForUser revokes every session of a user and returns the number
+// of sessions revoked.
+func (r *SessionRepository) RevokeAllForUser(userID int64) (int64, error) {
+	result, err := conn(r.db, r.tx).Exec(
+		"UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = ? AND revoked_at IS NULL",
+		userID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -1,24 +1,51 @@
 package repository
 
 import (
+	"database/sql"
+	"strings"
+
 	"zaiko/internal/database"
 	"zaiko/internal/models"
 )
 
-type StockRepository struct{}
+type StockRepository struct {
+	db *database.DB
+	tx *sql.Tx
+}
+
+func NewStockRepository(db *database.DB) *StockRepository {
+	return &StockRepository{db: db}
+}
 
-func NewStockRepository() *StockRepository {
-	return &StockRepository{}
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *StockRepository) WithTx(tx *sql.Tx) *StockRepository {
+	return &StockRepository{db: r.db, tx: tx}
 }
 
 func (r *StockRepository) FindAll(filter models.StockFilter) ([]models.Stock, error) {
 	query := `
-		SELECT s.id, s.product_id, s.warehouse_id, s.quantity, s.updated_at,
+		SELECT s.id, s.product_id, s.warehouse_id, s.quantity, s.value, s.updated_at,
+		       COALESCE(o.allocated, 0), COALESCE(o.backordered, 0), COALESCE(rs.reserved, 0),
 		       p.id, p.code, p.name, p.unit,
 		       w.id, w.name, w.location
 		FROM stock s
 		JOIN products p ON s.product_id = p.id
 		JOIN warehouses w ON s.warehouse_id = w.id
+		LEFT JOIN (
+			SELECT l.product_id, so.warehouse_id,
+			       SUM(l.allocated_quantity - l.shipped_quantity) AS allocated,
+			       SUM(l.quantity - l.allocated_quantity) AS backordered
+			FROM sales_order_lines l
+			JOIN sales_orders so ON l.sales_order_id = so.id
+			WHERE so.status IN ` + openSalesOrderStatuses + `
+			GROUP BY l.product_id, so.warehouse_id
+		) o ON o.product_id = s.product_id AND o.warehouse_id = s.warehouse_id
+		LEFT JOIN (
+			SELECT r.product_id, r.warehouse_id, SUM(r.quantity) AS reserved
+			FROM reservations r
+			WHERE ` + activeReservation + `
+			GROUP BY r.product_id, r.warehouse_id
+		) rs ON rs.product_id = s.product_id AND rs.warehouse_id = s.warehouse_id
 		WHERE 1=1
 	`
 	var args []interface{}
@@ -33,15 +60,22 @@ func (r *StockRepository) FindAll(filter models.StockFilter) ([]models.Stock, er
 		args = append(args, filter.WarehouseID)
 	}
 
+	if len(filter.WarehouseIDs) > 0 {
+		query += " AND s.warehouse_id IN (" + questionMarks(len(filter.WarehouseIDs)) + ")"
+		for _, id := range filter.WarehouseIDs {
+			args = append(args, id)
+		}
+	}
+
 	if filter.Search != "" {
-		query += " AND (p.name LIKE ? OR p.code LIKE ?)"
-		searchTerm := "%" + filter.Search + "%"
+		query += " AND (LOWER(p.name) LIKE ? OR LOWER(p.code) LIKE ?)"
+		searchTerm := "%" + strings.ToLower(filter.Search) + "%"
 		args = append(args, searchTerm, searchTerm)
 	}
 
 	query += " ORDER BY p.name, w.name"
 
-	rows, err := database.DB.Query(query, args...)
+	rows, err := conn(r.db, r.tx).Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -55,7 +89,8 @@ func (r *StockRepository) FindAll(filter models.StockFilter) ([]models.Stock, er
 		var wLocation *string
 
 		if err := rows.Scan(
-			&s.ID, &s.ProductID, &s.WarehouseID, &s.Quantity, &s.UpdatedAt,
+			&s.ID, &s.ProductID, &s.WarehouseID, &s.Quantity, &s.Value, &s.UpdatedAt,
+			&s.Allocated, &s.Backordered, &s.Reserved,
 			&p.ID, &p.Code, &p.Name, &p.Unit,
 			&w.ID, &w.Name, &wLocation,
 		); err != nil {
@@ -66,6 +101,92 @@ func (r *StockRepository) FindAll(filter models.StockFilter) ([]models.Stock, er
 			w.Location = *wLocation
 		}
 
+		s.OnHand = s.Quantity
+		s.Reserved += s.Allocated
+		s.Available = s.OnHand - s.Reserved
+		s.Product = &p
+		s.Warehouse = &w
+		stocks = append(stocks, s)
+	}
+
+	return stocks, nil
+}
+
+// FindAsOf rebuilds the quantity and value of stock from the transactions
+// created before the given time. UpdatedAt is the time of the last of
+// them; reservations and allocations are not historical and are left out.
+func (r *StockRepository) FindAsOf(filter models.StockFilter, before string) ([]models.Stock, error) {
+	query := `
+		SELECT COALESCE(s.id, 0), h.product_id, h.warehouse_id, h.quantity, h.value, lt.created_at,
+		       p.id, p.code, p.name, p.unit,
+		       w.id, w.name, w.location
+		FROM (
+			SELECT t.product_id, t.warehouse_id,
+			       SUM(` + signedQuantity + `) AS quantity, SUM(` + signedCost + `) AS value, MAX(t.id) AS last_id
+			FROM transactions t
+			WHERE t.created_at < ?
+			GROUP BY t.product_id, t.warehouse_id
+		) h
+		JOIN transactions lt ON lt.id = h.last_id
+		JOIN products p ON h.product_id = p.id
+		JOIN warehouses w ON h.warehouse_id = w.id
+		LEFT JOIN stock s ON s.product_id = h.product_id AND s.warehouse_id = h.warehouse_id
+		WHERE 1=1
+	`
+	args := []interface{}{before}
+
+	if filter.ProductID > 0 {
+		query += " AND h.product_id = ?"
+		args = append(args, filter.ProductID)
+	}
+
+	if filter.WarehouseID > 0 {
+		query += " AND h.warehouse_id = ?"
+		args = append(args, filter.WarehouseID)
+	}
+
+	if len(filter.WarehouseIDs) > 0 {
+		query += " AND h.warehouse_id IN (" + questionMarks(len(filter.WarehouseIDs)) + ")"
+		for _, id := range filter.WarehouseIDs {
+			args = append(args, id)
+		}
+	}
+
+	if filter.Search != "" {
+		query += " AND (LOWER(p.name) LIKE ? OR LOWER(p.code) LIKE ?)"
+		searchTerm := "%" + strings.ToLower(filter.Search) + "%"
+		args = append(args, searchTerm, searchTerm)
+	}
+
+	query += " ORDER BY p.name, w.name"
+
+	rows, err := conn(r.db, r.tx).Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stocks []models.Stock
+	for rows.Next() {
+		var s models.Stock
+		var p models.Product
+		var w models.Warehouse
+		var wLocation *string
+
+		if err := rows.Scan(
+			&s.ID, &s.ProductID, &s.WarehouseID, &s.Quantity, &s.Value, &s.UpdatedAt,
+			&p.ID, &p.Code, &p.Name, &p.Unit,
+			&w.ID, &w.Name, &wLocation,
+		); err != nil {
+			return nil, err
+		}
+
+		if wLocation != nil {
+			w.Location = *wLocation
+		}
+
+		s.OnHand = s.Quantity
+		s.Available = s.OnHand
 		s.Product = &p
 		s.Warehouse = &w
 		stocks = append(stocks, s)
@@ -76,11 +197,11 @@ func (r *StockRepository) FindAll(filter models.StockFilter) ([]models.Stock, er
 
 func (r *StockRepository) FindByProductAndWarehouse(productID, warehouseID int64) (*models.Stock, error) {
 	var s models.Stock
-	err := database.DB.QueryRow(`
-		SELECT id, product_id, warehouse_id, quantity, updated_at
+	err := conn(r.db, r.tx).QueryRow(`
+		SELECT id, product_id, warehouse_id, quantity, value, updated_at
 		FROM stock WHERE product_id = ? AND warehouse_id = ?
 	`, productID, warehouseID).Scan(
-		&s.ID, &s.ProductID, &s.WarehouseID, &s.Quantity, &s.UpdatedAt,
+		&s.ID, &s.ProductID, &s.WarehouseID, &s.Quantity, &s.Value, &s.UpdatedAt,
 	)
 
 	if err != nil {
@@ -90,28 +211,100 @@ func (r *StockRepository) FindByProductAndWarehouse(productID, warehouseID int64
 }
 
 func (r *StockRepository) UpdateQuantity(productID, warehouseID int64, delta int) error {
-	_, err := database.DB.Exec(`
+	_, err := conn(r.db, r.tx).Exec(`
 		INSERT INTO stock (product_id, warehouse_id, quantity, updated_at)
 		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
 		ON CONFLICT(product_id, warehouse_id) DO UPDATE SET
-			quantity = quantity + ?,
+			quantity = stock.quantity + ?,
 			updated_at = CURRENT_TIMESTAMP
 	`, productID, warehouseID, delta, delta)
 
 	return err
 }
 
-func (r *StockRepository) GetTotalQuantity() (int, error) {
-	var total int
-	err := database.DB.QueryRow("SELECT COALESCE(SUM(quantity), 0) FROM stock").Scan(&total)
-	return total, err
+// AddValue changes the value of a stock row by delta. The row must exist.
+func (r *StockRepository) AddValue(productID, warehouseID int64, delta float64) error {
+	_, err := conn(r.db, r.tx).Exec(
+		"UPDATE stock SET value = value + ? WHERE product_id = ? AND warehouse_id = ?",
+		delta, productID, warehouseID,
+	)
+	return err
+}
+
+// Decrement lowers the quantity only if enough stock is on hand, so the check
+// and the update happen in a single statement. It reports whether a row was
+// updated.
+func (r *StockRepository) Decrement(productID, warehouseID int64, quantity int) (bool, error) {
+	result, err := conn(r.db, r.tx).Exec(`
+		UPDATE stock SET
+			quantity = quantity - ?,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE product_id = ? AND warehouse_id = ? AND quantity >= ?
+	`, quantity, productID, warehouseID, quantity)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// GetTotals returns the quantity of all stock and its value at cost.
+func (r *StockRepository) GetTotals() (quantity int, value float64, err error) {
+	err = conn(r.db, r.tx).QueryRow("SELECT COALESCE(SUM(quantity), 0), COALESCE(SUM(value), 0) FROM stock").Scan(&quantity, &value)
+	return quantity, value, err
 }
 
-func (r *StockRepository) GetLowStockCount(threshold int) (int, error) {
-	var count int
-	err := database.DB.QueryRow(
-		"SELECT COUNT(DISTINCT product_id) FROM stock WHERE quantity <= ?",
-		threshold,
-	).Scan(&count)
-	return count, err
+// FindLedgerMismatches returns every (product, warehouse) pair whose stock
+// quantity differs from the sum of its transactions, including pairs with
+// only a stock row or only transactions.
+func (r *StockRepository) FindLedgerMismatches() ([]models.StockMismatch, error) {
+	rows, err := conn(r.db, r.tx).Query(`
+		SELECT k.product_id, k.warehouse_id,
+		       COALESCE(p.code, ''), COALESCE(p.name, ''), COALESCE(w.name, ''),
+		       p.id IS NULL OR w.id IS NULL,
+		       COALESCE(s.quantity, 0), COALESCE(s.value, 0),
+		       COALESCE(l.quantity, 0), COALESCE(l.value, 0)
+		FROM (
+			SELECT product_id, warehouse_id FROM stock
+			UNION
+			SELECT product_id, warehouse_id FROM transactions
+		) k
+		LEFT JOIN stock s ON s.product_id = k.product_id AND s.warehouse_id = k.warehouse_id
+		LEFT JOIN (
+			SELECT t.product_id, t.warehouse_id,
+			       SUM(` + signedQuantity + `) AS quantity, SUM(` + signedCost + `) AS value
+			FROM transactions t
+			GROUP BY t.product_id, t.warehouse_id
+		) l ON l.product_id = k.product_id AND l.warehouse_id = k.warehouse_id
+		LEFT JOIN products p ON p.id = k.product_id
+		LEFT JOIN warehouses w ON w.id = k.warehouse_id
+		WHERE COALESCE(s.quantity, 0) <> COALESCE(l.quantity, 0)
+		ORDER BY k.product_id, k.warehouse_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mismatches []models.StockMismatch
+	for rows.Next() {
+		var m models.StockMismatch
+		if err := rows.Scan(
+			&m.ProductID, &m.WarehouseID,
+			&m.ProductCode, &m.ProductName, &m.WarehouseName,
+			&m.Orphaned,
+			&m.StockQuantity, &m.StockValue,
+			&m.LedgerQuantity, &m.LedgerValue,
+		); err != nil {
+			return nil, err
+		}
+		m.Difference = m.StockQuantity - m.LedgerQuantity
+		mismatches = append(mismatches, m)
+	}
+
+	return mismatches, rows.Err()
 }
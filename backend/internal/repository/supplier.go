@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+)
+
+type SupplierRepository struct {
+	db *database.DB
+	tx *sql.Tx
+}
+
+func NewSupplierRepository(db *database.DB) *SupplierRepository {
+	return &SupplierRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *SupplierRepository) WithTx(tx *sql.Tx) *SupplierRepository {
+	return &SupplierRepository{db: r.db, tx: tx}
+}
+
+func (r *SupplierRepository) FindAll() ([]models.Supplier, error) {
+	rows, err := conn(r.db, r.tx).Query(
+		"SELECT id, name, contact, lead_time_days, created_at FROM suppliers ORDER BY name",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var suppliers []models.Supplier
+	for rows.Next() {
+		var s models.Supplier
+		var contact *string
+		if err := rows.Scan(&s.ID, &s.Name, &contact, &s.LeadTimeDays, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		if contact != nil {
+			s.Contact = *contact
+		}
+		suppliers = append(suppliers, s)
+	}
+
+	return suppliers, nil
+}
+
+func (r *SupplierRepository) FindByID(id int64) (*models.Supplier, error) {
+	var s models.Supplier
+	var contact *string
+
+	err := conn(r.db, r.tx).QueryRow(
+		"SELECT id, name, contact, lead_time_days, created_at FROM suppliers WHERE id = ?",
+		id,
+	).Scan(&s.ID, &s.Name, &contact, &s.LeadTimeDays, &s.CreatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if contact != nil {
+		s.Contact = *contact
+	}
+
+	return &s, nil
+}
+
+func (r *SupplierRepository) Create(req models.CreateSupplierRequest) (*models.Supplier, error) {
+	var id int64
+	err := conn(r.db, r.tx).QueryRow(
+		"INSERT INTO suppliers (name, contact, lead_time_days) VALUES (?, ?, ?) RETURNING id",
+		req.Name, req.Contact, req.LeadTimeDays,
+	).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.FindByID(id)
+}
+
+func (r *SupplierRepository) Update(id int64, req models.UpdateSupplierRequest) (*models.Supplier, error) {
+	var updates []string
+	var args []interface{}
+
+	if req.Name != "" {
+		updates = append(updates, "name = ?")
+		args = append(args, req.Name)
+	}
+	if req.Contact != "" {
+		updates = append(updates, "contact = ?")
+		args = append(args, req.Contact)
+	}
+	if req.LeadTimeDays != nil {
+		updates = append(updates, "lead_time_days = ?")
+		args = append(args, *req.LeadTimeDays)
+	}
+
+	if len(updates) == 0 {
+		return r.FindByID(id)
+	}
+
+	args = append(args, id)
+	query := fmt.Sprintf("UPDATE suppliers SET %s WHERE id = ?", strings.Join(updates, ", "))
+
+	_, err := conn(r.db, r.tx).Exec(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.FindByID(id)
+}
+
+// Delete removes a supplier. It returns an *InUseError if purchase orders
+// still refer to it.
+func (r *SupplierRepository) Delete(id int64) error {
+	return deleteUnreferenced(r.db, r.tx, "suppliers", id, []reference{{"purchase_orders", "supplier_id"}})
+}
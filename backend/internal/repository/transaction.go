@@ -1,14 +1,24 @@
 package repository
 
 import (
+	"database/sql"
+
 	"zaiko/internal/database"
 	"zaiko/internal/models"
 )
 
-type TransactionRepository struct{}
+type TransactionRepository struct {
+	db *database.DB
+	tx *sql.Tx
+}
 
-func NewTransactionRepository() *TransactionRepository {
-	return &TransactionRepository{}
+func NewTransactionRepository(db *database.DB) *TransactionRepository {
+	return &TransactionRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *TransactionRepository) WithTx(tx *sql.Tx) *TransactionRepository {
+	return &TransactionRepository{db: r.db, tx: tx}
 }
 
 func (r *TransactionRepository) Create(
@@ -18,16 +28,12 @@ func (r *TransactionRepository) Create(
 	note string,
 	userID int64,
 ) (*models.Transaction, error) {
-	result, err := database.DB.Exec(`
+	var id int64
+	err := conn(r.db, r.tx).QueryRow(`
 		INSERT INTO transactions (product_id, warehouse_id, type, quantity, note, user_id)
 		VALUES (?, ?, ?, ?, ?, ?)
-	`, productID, warehouseID, txType, quantity, note, userID)
-
-	if err != nil {
-		return nil, err
-	}
-
-	id, err := result.LastInsertId()
+		RETURNING id
+	`, productID, warehouseID, txType, quantity, note, userID).Scan(&id)
 	if err != nil {
 		return nil, err
 	}
@@ -35,15 +41,61 @@ func (r *TransactionRepository) Create(
 	return r.FindByID(id)
 }
 
+// Link marks two transactions as the legs of the same movement.
+func (r *TransactionRepository) Link(id, relatedID int64) error {
+	_, err := conn(r.db, r.tx).Exec(`
+		UPDATE transactions SET related_transaction_id = CASE id WHEN ? THEN ? ELSE ? END
+		WHERE id IN (?, ?)
+	`, id, relatedID, id, id, relatedID)
+
+	return err
+}
+
+// SetCost records the cost of a transaction.
+func (r *TransactionRepository) SetCost(id int64, unitCost, totalCost float64) error {
+	_, err := conn(r.db, r.tx).Exec(
+		"UPDATE transactions SET unit_cost = ?, total_cost = ? WHERE id = ?",
+		unitCost, totalCost, id,
+	)
+	return err
+}
+
+// SetReason records the reason of an adjustment transaction.
+func (r *TransactionRepository) SetReason(id, reasonID int64) error {
+	_, err := conn(r.db, r.tx).Exec("UPDATE transactions SET reason_id = ? WHERE id = ?", reasonID, id)
+	return err
+}
+
+// SetReversal records that a transaction reverses another one.
+func (r *TransactionRepository) SetReversal(id, reversalOf int64) error {
+	_, err := conn(r.db, r.tx).Exec("UPDATE transactions SET reversal_of = ? WHERE id = ?", reversalOf, id)
+	return err
+}
+
+// IsOrderMovement reports whether a transaction was posted by a purchase
+// order receipt or a sales order shipment.
+func (r *TransactionRepository) IsOrderMovement(id int64) (bool, error) {
+	var exists bool
+	err := conn(r.db, r.tx).QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM purchase_order_receipts WHERE transaction_id = ?)
+		    OR EXISTS (SELECT 1 FROM sales_order_shipments WHERE transaction_id = ?)
+	`, id, id).Scan(&exists)
+	return exists, err
+}
+
 func (r *TransactionRepository) FindByID(id int64) (*models.Transaction, error) {
 	var t models.Transaction
 	var note *string
 
-	err := database.DB.QueryRow(`
-		SELECT id, product_id, warehouse_id, type, quantity, note, user_id, created_at
-		FROM transactions WHERE id = ?
+	err := conn(r.db, r.tx).QueryRow(`
+		SELECT t.id, t.product_id, t.warehouse_id, t.type, t.quantity, t.unit_cost, t.total_cost, t.note, t.reason_id, t.user_id,
+		       t.related_transaction_id, t.reversal_of, rv.id, t.created_at
+		FROM transactions t
+		LEFT JOIN transactions rv ON rv.reversal_of = t.id
+		WHERE t.id = ?
 	`, id).Scan(
-		&t.ID, &t.ProductID, &t.WarehouseID, &t.Type, &t.Quantity, &note, &t.UserID, &t.CreatedAt,
+		&t.ID, &t.ProductID, &t.WarehouseID, &t.Type, &t.Quantity, &t.UnitCost, &t.TotalCost, &note, &t.ReasonID, &t.UserID,
+		&t.RelatedTransactionID, &t.ReversalOfID, &t.ReversedByID, &t.CreatedAt,
 	)
 
 	if err != nil {
@@ -57,18 +109,26 @@ func (r *TransactionRepository) FindByID(id int64) (*models.Transaction, error)
 	return &t, nil
 }
 
+// transactionListQuery selects transactions with their product, warehouse,
+// user, adjustment reason and reversal for scanTransactions.
+const transactionListQuery = `
+	SELECT t.id, t.product_id, t.warehouse_id, t.type, t.quantity, t.unit_cost, t.total_cost, t.note, t.user_id,
+	       t.related_transaction_id, t.reversal_of, rv.id, t.created_at,
+	       p.id, p.code, p.name, p.unit,
+	       w.id, w.name,
+	       u.id, u.username,
+	       ar.id, ar.code, ar.name
+	FROM transactions t
+	JOIN products p ON t.product_id = p.id
+	JOIN warehouses w ON t.warehouse_id = w.id
+	JOIN users u ON t.user_id = u.id
+	LEFT JOIN adjustment_reasons ar ON t.reason_id = ar.id
+	LEFT JOIN transactions rv ON rv.reversal_of = t.id
+	WHERE 1=1
+`
+
 func (r *TransactionRepository) FindAll(filter models.TransactionFilter) ([]models.Transaction, error) {
-	query := `
-		SELECT t.id, t.product_id, t.warehouse_id, t.type, t.quantity, t.note, t.user_id, t.created_at,
-		       p.id, p.code, p.name, p.unit,
-		       w.id, w.name,
-		       u.id, u.username
-		FROM transactions t
-		JOIN products p ON t.product_id = p.id
-		JOIN warehouses w ON t.warehouse_id = w.id
-		JOIN users u ON t.user_id = u.id
-		WHERE 1=1
-	`
+	query := transactionListQuery
 	var args []interface{}
 
 	if filter.ProductID > 0 {
@@ -81,19 +141,65 @@ func (r *TransactionRepository) FindAll(filter models.TransactionFilter) ([]mode
 		args = append(args, filter.WarehouseID)
 	}
 
+	query, args = inWarehouses(query, args, filter.WarehouseIDs)
+
 	if filter.Type != "" {
 		query += " AND t.type = ?"
 		args = append(args, filter.Type)
 	}
 
-	query += " ORDER BY t.created_at DESC"
+	if filter.ReasonID > 0 {
+		query += " AND t.reason_id = ?"
+		args = append(args, filter.ReasonID)
+	}
+
+	// Rows created in the same second keep their insertion order
+	query += " ORDER BY t.created_at DESC, t.id DESC"
 
 	if filter.Limit > 0 {
 		query += " LIMIT ?"
 		args = append(args, filter.Limit)
 	}
 
-	rows, err := database.DB.Query(query, args...)
+	return r.scanTransactions(query, args...)
+}
+
+// FindForLedger returns the transactions of a product created in [from,
+// before), oldest first. Empty bounds are open; warehouseIDs limits the
+// warehouses if it is not empty.
+func (r *TransactionRepository) FindForLedger(productID int64, warehouseIDs []int64, from, before string) ([]models.Transaction, error) {
+	query := transactionListQuery + " AND t.product_id = ?"
+	args := []interface{}{productID}
+	query, args = inWarehouses(query, args, warehouseIDs)
+
+	if from != "" {
+		query += " AND t.created_at >= ?"
+		args = append(args, from)
+	}
+	if before != "" {
+		query += " AND t.created_at < ?"
+		args = append(args, before)
+	}
+
+	query += " ORDER BY t.created_at, t.id"
+
+	return r.scanTransactions(query, args...)
+}
+
+// Balance returns the quantity and value of a product after all its
+// transactions created before the given time.
+func (r *TransactionRepository) Balance(productID int64, warehouseIDs []int64, before string) (quantity int, value float64, err error) {
+	query := "SELECT COALESCE(SUM(" + signedQuantity + "), 0), COALESCE(SUM(" + signedCost + "), 0) FROM transactions t WHERE t.product_id = ? AND t.created_at < ?"
+	args := []interface{}{productID, before}
+	query, args = inWarehouses(query, args, warehouseIDs)
+
+	err = conn(r.db, r.tx).QueryRow(query, args...).Scan(&quantity, &value)
+	return quantity, value, err
+}
+
+// scanTransactions runs a query built on transactionListQuery.
+func (r *TransactionRepository) scanTransactions(query string, args ...interface{}) ([]models.Transaction, error) {
+	rows, err := conn(r.db, r.tx).Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -106,12 +212,16 @@ func (r *TransactionRepository) FindAll(filter models.TransactionFilter) ([]mode
 		var p models.Product
 		var w models.Warehouse
 		var u models.User
+		var reasonID *int64
+		var reasonCode, reasonName *string
 
 		if err := rows.Scan(
-			&t.ID, &t.ProductID, &t.WarehouseID, &t.Type, &t.Quantity, &note, &t.UserID, &t.CreatedAt,
+			&t.ID, &t.ProductID, &t.WarehouseID, &t.Type, &t.Quantity, &t.UnitCost, &t.TotalCost, &note, &t.UserID,
+			&t.RelatedTransactionID, &t.ReversalOfID, &t.ReversedByID, &t.CreatedAt,
 			&p.ID, &p.Code, &p.Name, &p.Unit,
 			&w.ID, &w.Name,
 			&u.ID, &u.Username,
+			&reasonID, &reasonCode, &reasonName,
 		); err != nil {
 			return nil, err
 		}
@@ -123,6 +233,10 @@ func (r *TransactionRepository) FindAll(filter models.TransactionFilter) ([]mode
 		t.Product = &p
 		t.Warehouse = &w
 		t.User = &u
+		if reasonID != nil {
+			t.ReasonID = reasonID
+			t.Reason = &models.AdjustmentReason{ID: *reasonID, Code: *reasonCode, Name: *reasonName}
+		}
 		transactions = append(transactions, t)
 	}
 
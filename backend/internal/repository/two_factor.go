@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+)
+
+type TwoFactorRepository struct {
+	db *database.DB
+	tx *sql.Tx
+}
+
+func NewTwoFactorRepository(db *database.DB) *TwoFactorRepository {
+	return &TwoFactorRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *TwoFactorRepository) WithTx(tx *sql.Tx) *TwoFactorRepository {
+	return &TwoFactorRepository{db: r.db, tx: tx}
+}
+
+// SetSecret stores a secret for a user who has not enabled two-factor
+// authentication yet. It reports whether the secret was stored.
+func (r *TwoFactorRepository) SetSecret(userID int64, secret string) (bool, error) {
+	result, err := conn(r.db, r.tx).Exec(
+		"UPDATE users SET totp_secret = ? WHERE id = ? AND totp_enabled = FALSE",
+		secret, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// Enable turns on two-factor authentication, recording the step of the
+// code that confirmed it, and replaces the user's recovery codes.
+func (r *TwoFactorRepository) Enable(userID, step int64, recoveryCodeHashes []string) (bool, error) {
+	enabled := false
+	err := inTx(r.db, r.tx, func(tx *sql.Tx) error {
+		result, err := tx.Exec(
+			"UPDATE users SET totp_enabled = TRUE, totp_last_step = ? WHERE id = ? AND totp_enabled = FALSE AND totp_secret IS NOT NULL",
+			step, userID,
+		)
+		if err != nil {
+			return err
+		}
+		if affected, err := result.RowsAffected(); affected == 0 || err != nil {
+			return err
+		}
+
+		if err := replaceRecoveryCodes(tx, userID, recoveryCodeHashes); err != nil {
+			return err
+		}
+
+		enabled = true
+		return nil
+	})
+	return enabled, err
+}
+
+// Disable turns off two-factor authentication and removes the secret and
+// recovery codes.
+func (r *TwoFactorRepository) Disable(userID int64) error {
+	return inTx(r.db, r.tx, func(tx *sql.Tx) error {
+		statements := []string{
+			"UPDATE users SET totp_enabled = FALSE, totp_secret = NULL, totp_last_step = 0 WHERE id = ?",
+			"DELETE FROM recovery_codes WHERE user_id = ?",
+		}
+		for _, stmt := range statements {
+			if _, err := tx.Exec(stmt, userID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UseStep records the time step of an accepted code unless that step or a
+// later one has been used already. It reports whether the step was new.
+func (r *TwoFactorRepository) UseStep(userID, step int64) (bool, error) {
+	result, err := conn(r.db, r.tx).Exec(
+		"UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?",
+		step, userID, step,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *TwoFactorRepository) ReplaceRecoveryCodes(userID int64, codeHashes []string) error {
+	return inTx(r.db, r.tx, func(tx *sql.Tx) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+func replaceRecoveryCodes(tx dbtx, userID int64, codeHashes []string) error {
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec("INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)", userID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code of the user as used. It
+// reports whether one matched.
+func (r *TwoFactorRepository) UseRecoveryCode(userID int64, codeHash string) (bool, error) {
+	result, err := conn(r.db, r.tx).Exec(
+		"UPDATE recovery_codes SET used_at = CURRENT_TIMESTAMP WHERE user_id = ? AND code_hash = ? AND used_at IS NULL",
+		userID, codeHash,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *TwoFactorRepository) CreateChallenge(userID int64, tokenHash string, expiresAt time.Time) error {
+	_, err := conn(r.db, r.tx).Exec(
+		"INSERT INTO two_factor_challenges (user_id, token_hash, expires_at) VALUES (?, ?, ?)",
+		userID, tokenHash, expiresAt.UTC().Format(time.DateTime),
+	)
+	return err
+}
+
+func (r *TwoFactorRepository) FindChallenge(tokenHash string) (*models.TwoFactorChallenge, error) {
+	var ch models.TwoFactorChallenge
+	err := conn(r.db, r.tx).QueryRow(
+		"SELECT id, user_id, attempts, expires_at, completed_at FROM two_factor_challenges WHERE token_hash = ?",
+		tokenHash,
+	).Scan(&ch.ID, &ch.UserID, &ch.Attempts, &ch.ExpiresAt, &ch.CompletedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &ch, nil
+}
+
+// AttemptChallenge counts an attempt to complete an open challenge, unless
+// it has run out of attempts. It reports whether the attempt may go ahead.
+func (r *TwoFactorRepository) AttemptChallenge(id int64, maxAttempts int) (bool, error) {
+	result, err := conn(r.db, r.tx).Exec(`
+		UPDATE two_factor_challenges SET attempts = attempts + 1
+		WHERE id = ? AND completed_at IS NULL AND attempts < ? AND expires_at > CURRENT_TIMESTAMP
+	`, id, maxAttempts)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// CompleteChallenge closes a challenge. It reports whether it was still
+// open, so that a challenge cannot be completed twice.
+func (r *TwoFactorRepository) CompleteChallenge(id int64) (bool, error) {
+	result, err := conn(r.db, r.tx).Exec(
+		"UPDATE two_factor_challenges SET completed_at = CURRENT_TIMESTAMP WHERE id = ? AND completed_at IS NULL",
+		id,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
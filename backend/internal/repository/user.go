@@ -1,38 +1,100 @@
 package repository
 
 import (
+	"database/sql"
+
 	"zaiko/internal/database"
 	"zaiko/internal/models"
 )
 
-type UserRepository struct{}
+const userColumns = "id, username, password_hash, role, disabled, must_change_password, " +
+	"totp_enabled, COALESCE(totp_secret, ''), totp_last_step, created_at"
 
-func NewUserRepository() *UserRepository {
-	return &UserRepository{}
+type UserRepository struct {
+	db *database.DB
+	tx *sql.Tx
 }
 
-func (r *UserRepository) FindByUsername(username string) (*models.User, error) {
-	user := &models.User{}
-	err := database.DB.QueryRow(
-		"SELECT id, username, password_hash, created_at FROM users WHERE username = ?",
-		username,
-	).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.CreatedAt)
+func NewUserRepository(db *database.DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *UserRepository) WithTx(tx *sql.Tx) *UserRepository {
+	return &UserRepository{db: r.db, tx: tx}
+}
 
+func scanUser(scanner interface{ Scan(...interface{}) error }) (*models.User, error) {
+	user := &models.User{}
+	err := scanner.Scan(
+		&user.ID, &user.Username, &user.PasswordHash, &user.Role,
+		&user.Disabled, &user.MustChangePassword,
+		&user.TwoFactorEnabled, &user.TOTPSecret, &user.TOTPLastStep, &user.CreatedAt,
+	)
 	if err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
+func (r *UserRepository) FindAll() ([]models.User, error) {
+	rows, err := conn(r.db, r.tx).Query("SELECT " + userColumns + " FROM users ORDER BY username")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+
+	return users, nil
+}
+
+func (r *UserRepository) FindByUsername(username string) (*models.User, error) {
+	return scanUser(conn(r.db, r.tx).QueryRow(
+		"SELECT "+userColumns+" FROM users WHERE username = ?",
+		username,
+	))
+}
+
 func (r *UserRepository) FindByID(id int64) (*models.User, error) {
-	user := &models.User{}
-	err := database.DB.QueryRow(
-		"SELECT id, username, password_hash, created_at FROM users WHERE id = ?",
+	return scanUser(conn(r.db, r.tx).QueryRow(
+		"SELECT "+userColumns+" FROM users WHERE id = ?",
 		id,
-	).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.CreatedAt)
+	))
+}
 
+// Create adds a user who must change the given password at first login.
+func (r *UserRepository) Create(username, passwordHash string, role models.Role) (*models.User, error) {
+	var id int64
+	err := conn(r.db, r.tx).QueryRow(
+		"INSERT INTO users (username, password_hash, role, must_change_password) VALUES (?, ?, ?, TRUE) RETURNING id",
+		username, passwordHash, role,
+	).Scan(&id)
 	if err != nil {
 		return nil, err
 	}
-	return user, nil
+
+	return r.FindByID(id)
+}
+
+func (r *UserRepository) SetDisabled(id int64, disabled bool) error {
+	_, err := conn(r.db, r.tx).Exec("UPDATE users SET disabled = ? WHERE id = ?", disabled, id)
+	return err
+}
+
+// UpdatePassword replaces the password hash. mustChange forces another
+// change at the next login.
+func (r *UserRepository) UpdatePassword(id int64, passwordHash string, mustChange bool) error {
+	_, err := conn(r.db, r.tx).Exec(
+		"UPDATE users SET password_hash = ?, must_change_password = ? WHERE id = ?",
+		passwordHash, mustChange, id,
+	)
+	return err
 }
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"database/sql"
 	"fmt"
 	"strings"
 
@@ -8,14 +9,22 @@ import (
 	"zaiko/internal/models"
 )
 
-type WarehouseRepository struct{}
+type WarehouseRepository struct {
+	db *database.DB
+	tx *sql.Tx
+}
+
+func NewWarehouseRepository(db *database.DB) *WarehouseRepository {
+	return &WarehouseRepository{db: db}
+}
 
-func NewWarehouseRepository() *WarehouseRepository {
-	return &WarehouseRepository{}
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *WarehouseRepository) WithTx(tx *sql.Tx) *WarehouseRepository {
+	return &WarehouseRepository{db: r.db, tx: tx}
 }
 
 func (r *WarehouseRepository) FindAll() ([]models.Warehouse, error) {
-	rows, err := database.DB.Query("SELECT id, name, location FROM warehouses ORDER BY name")
+	rows, err := conn(r.db, r.tx).Query("SELECT id, name, location FROM warehouses ORDER BY name")
 	if err != nil {
 		return nil, err
 	}
@@ -41,7 +50,7 @@ func (r *WarehouseRepository) FindByID(id int64) (*models.Warehouse, error) {
 	var w models.Warehouse
 	var location *string
 
-	err := database.DB.QueryRow(
+	err := conn(r.db, r.tx).QueryRow(
 		"SELECT id, name, location FROM warehouses WHERE id = ?",
 		id,
 	).Scan(&w.ID, &w.Name, &location)
@@ -58,15 +67,11 @@ func (r *WarehouseRepository) FindByID(id int64) (*models.Warehouse, error) {
 }
 
 func (r *WarehouseRepository) Create(req models.CreateWarehouseRequest) (*models.Warehouse, error) {
-	result, err := database.DB.Exec(
-		"INSERT INTO warehouses (name, location) VALUES (?, ?)",
+	var id int64
+	err := conn(r.db, r.tx).QueryRow(
+		"INSERT INTO warehouses (name, location) VALUES (?, ?) RETURNING id",
 		req.Name, req.Location,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	id, err := result.LastInsertId()
+	).Scan(&id)
 	if err != nil {
 		return nil, err
 	}
@@ -94,7 +99,7 @@ func (r *WarehouseRepository) Update(id int64, req models.UpdateWarehouseRequest
 	args = append(args, id)
 	query := fmt.Sprintf("UPDATE warehouses SET %s WHERE id = ?", strings.Join(updates, ", "))
 
-	_, err := database.DB.Exec(query, args...)
+	_, err := conn(r.db, r.tx).Exec(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -102,7 +107,24 @@ func (r *WarehouseRepository) Update(id int64, req models.UpdateWarehouseRequest
 	return r.FindByID(id)
 }
 
+// warehouseReferences are the rows that keep a warehouse from being deleted.
+var warehouseReferences = []reference{
+	{"stock", "warehouse_id"},
+	{"transactions", "warehouse_id"},
+	{"lots", "warehouse_id"},
+	{"serial_numbers", "warehouse_id"},
+	{"count_sessions", "warehouse_id"},
+	{"count_lines", "warehouse_id"},
+	{"purchase_orders", "warehouse_id"},
+	{"sales_orders", "warehouse_id"},
+	{"reservations", "warehouse_id"},
+	{"api_key_warehouses", "warehouse_id"},
+}
+
+// Delete removes a warehouse and its reorder overrides. It returns an
+// *InUseError if the warehouse has stock, history or orders.
 func (r *WarehouseRepository) Delete(id int64) error {
-	_, err := database.DB.Exec("DELETE FROM warehouses WHERE id = ?", id)
-	return err
+	return deleteUnreferenced(r.db, r.tx, "warehouses", id, warehouseReferences,
+		"DELETE FROM reorder_settings WHERE warehouse_id = ?",
+	)
 }
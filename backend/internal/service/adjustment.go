@@ -0,0 +1,78 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+
+	"zaiko/internal/models"
+)
+
+var (
+	ErrReasonNotFound      = errors.New("adjustment reason not found")
+	ErrAdjustmentQuantity  = errors.New("give either quantity or target_quantity")
+	ErrAdjustmentUnchanged = errors.New("adjustment does not change the quantity on hand")
+)
+
+// Adjust corrects the quantity on hand for a reason and records an
+// adjustment transaction through postAdjustment, the same path inventory
+// count variances take. Unlike a count line, the request can name the lots
+// and serial numbers gained or lost.
+func (s *StockService) Adjust(req models.StockAdjustmentRequest, userID int64) (*models.Transaction, error) {
+	if (req.Quantity == nil) == (req.TargetQuantity == nil) {
+		return nil, ErrAdjustmentQuantity
+	}
+
+	var transaction *models.Transaction
+	err := s.db.InStockTx(func(tx *sql.Tx) error {
+		reason, err := s.reasonRepo.WithTx(tx).FindByID(req.ReasonID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrReasonNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var delta int
+		if req.Quantity != nil {
+			delta = *req.Quantity
+		} else {
+			onHand := 0
+			stock, err := s.stockRepo.WithTx(tx).FindByProductAndWarehouse(req.ProductID, req.WarehouseID)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			if stock != nil {
+				onHand = stock.Quantity
+			}
+			delta = *req.TargetQuantity - onHand
+		}
+		if delta == 0 {
+			return ErrAdjustmentUnchanged
+		}
+
+		transaction, err = s.postAdjustment(tx, models.StockMovementRequest{
+			ProductID:   req.ProductID,
+			WarehouseID: req.WarehouseID,
+			Note:        req.Note,
+			UnitCost:    req.UnitCost,
+			Lot:         req.Lot,
+			Lots:        req.Lots,
+			Serials:     req.Serials,
+		}, delta, userID)
+		if err != nil {
+			return err
+		}
+
+		if err := s.transactionRepo.WithTx(tx).SetReason(transaction.ID, reason.ID); err != nil {
+			return err
+		}
+		transaction.ReasonID = &reason.ID
+		transaction.Reason = reason
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
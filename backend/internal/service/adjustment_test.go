@@ -0,0 +1,73 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"zaiko/internal/models"
+)
+
+func TestAdjust(t *testing.T) {
+	svc := newCostingService(t, models.CostingMovingAverage)
+	receive(t, svc, 10, cost(10))
+
+	quantity := func(v int) *int { return &v }
+
+	// A loss leaves at cost like an issue
+	loss, err := svc.Adjust(models.StockAdjustmentRequest{ProductID: 1, WarehouseID: 1, Quantity: quantity(-2), ReasonID: 1}, 1)
+	if err != nil {
+		t.Fatalf("adjust by -2: %v", err)
+	}
+	if loss.Type != models.TransactionTypeAdjustment || loss.Quantity != -2 || loss.TotalCost != -20 {
+		t.Errorf("loss = %s %d costing %v, want adjustment -2 costing -20", loss.Type, loss.Quantity, loss.TotalCost)
+	}
+	if loss.Reason == nil || loss.Reason.Code != "DAMAGE" {
+		t.Errorf("loss reason = %+v, want DAMAGE", loss.Reason)
+	}
+
+	// A target quantity adjusts by the difference to the quantity on hand
+	found, err := svc.Adjust(models.StockAdjustmentRequest{ProductID: 1, WarehouseID: 1, TargetQuantity: quantity(11), ReasonID: 3}, 1)
+	if err != nil {
+		t.Fatalf("adjust to 11: %v", err)
+	}
+	if found.Quantity != 3 || found.UnitCost != 10 {
+		t.Errorf("found = %d at %v, want 3 at 10", found.Quantity, found.UnitCost)
+	}
+
+	stock, err := svc.stockRepo.FindByProductAndWarehouse(1, 1)
+	if err != nil {
+		t.Fatalf("find stock: %v", err)
+	}
+	if stock.Quantity != 11 || stock.Value != 110 {
+		t.Errorf("stock = %d worth %v, want 11 worth 110", stock.Quantity, stock.Value)
+	}
+
+	transactions, err := svc.transactionRepo.FindAll(models.TransactionFilter{ReasonID: 1})
+	if err != nil {
+		t.Fatalf("find transactions: %v", err)
+	}
+	if len(transactions) != 1 || transactions[0].ID != loss.ID || transactions[0].Reason == nil || transactions[0].Reason.Name != "破損" {
+		t.Errorf("transactions with reason 1 = %+v, want the loss only", transactions)
+	}
+
+	tests := []struct {
+		name string
+		req  models.StockAdjustmentRequest
+		want error
+	}{
+		{"no quantity", models.StockAdjustmentRequest{ProductID: 1, WarehouseID: 1, ReasonID: 1}, ErrAdjustmentQuantity},
+		{"both quantities", models.StockAdjustmentRequest{ProductID: 1, WarehouseID: 1, Quantity: quantity(1), TargetQuantity: quantity(1), ReasonID: 1}, ErrAdjustmentQuantity},
+		{"unchanged", models.StockAdjustmentRequest{ProductID: 1, WarehouseID: 1, TargetQuantity: quantity(11), ReasonID: 1}, ErrAdjustmentUnchanged},
+		{"unknown reason", models.StockAdjustmentRequest{ProductID: 1, WarehouseID: 1, Quantity: quantity(1), ReasonID: 99}, ErrReasonNotFound},
+	}
+	for _, tt := range tests {
+		if _, err := svc.Adjust(tt.req, 1); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	var insufficient *InsufficientStockError
+	if _, err := svc.Adjust(models.StockAdjustmentRequest{ProductID: 1, WarehouseID: 1, Quantity: quantity(-12), ReasonID: 2}, 1); !errors.As(err, &insufficient) {
+		t.Errorf("adjust below zero: err = %v, want InsufficientStockError", err)
+	}
+}
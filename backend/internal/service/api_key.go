@@ -0,0 +1,115 @@
+package service
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+	"zaiko/internal/repository"
+)
+
+// apiKeyPrefix marks API keys so that they can be told apart from access
+// tokens and recognized by secret scanners.
+const apiKeyPrefix = "zk_"
+
+var (
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrInvalidExpiry  = errors.New("expiry must be in the future")
+)
+
+// PermissionNotGrantedError is returned when a key asks for a permission
+// that the owner's role does not have.
+type PermissionNotGrantedError struct {
+	Permission models.Permission
+}
+
+func (e *PermissionNotGrantedError) Error() string {
+	return fmt.Sprintf("role does not grant %s", e.Permission)
+}
+
+// APIKeyService manages API keys for clients that cannot log in
+// interactively.
+type APIKeyService struct {
+	apiKeyRepo    *repository.APIKeyRepository
+	warehouseRepo *repository.WarehouseRepository
+}
+
+func NewAPIKeyService(db *database.DB) *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepo:    repository.NewAPIKeyRepository(db),
+		warehouseRepo: repository.NewWarehouseRepository(db),
+	}
+}
+
+// WithTx returns a copy of the service that runs its queries in tx.
+func (s *APIKeyService) WithTx(tx *sql.Tx) *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepo:    s.apiKeyRepo.WithTx(tx),
+		warehouseRepo: s.warehouseRepo.WithTx(tx),
+	}
+}
+
+// FindAll lists the keys of a user, or of all users when userID is 0.
+func (s *APIKeyService) FindAll(userID int64) ([]models.APIKey, error) {
+	return s.apiKeyRepo.FindAll(userID)
+}
+
+// Create issues a key owned by user. The key can only grant permissions
+// that the user's role has.
+func (s *APIKeyService) Create(user *models.User, req models.CreateAPIKeyRequest) (*models.CreateAPIKeyResponse, error) {
+	for _, p := range req.Permissions {
+		if !user.Role.Can(p) {
+			return nil, &PermissionNotGrantedError{Permission: p}
+		}
+	}
+	for _, id := range req.WarehouseIDs {
+		if _, err := s.warehouseRepo.FindByID(id); errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWarehouseNotFound
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidExpiry
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	id, err := s.apiKeyRepo.Create(user.ID, req, key, key[:len(apiKeyPrefix)+8])
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey, err := s.apiKeyRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.CreateAPIKeyResponse{Key: key, APIKey: *apiKey}, nil
+}
+
+// Revoke revokes a key. Unless asAdmin is set, only the owner can revoke it.
+func (s *APIKeyService) Revoke(id, userID int64, asAdmin bool) error {
+	apiKey, err := s.apiKeyRepo.FindByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if apiKey.UserID != userID && !asAdmin {
+		return ErrAPIKeyNotFound
+	}
+
+	_, err = s.apiKeyRepo.Revoke(id)
+	return err
+}
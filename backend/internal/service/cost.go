@@ -0,0 +1,210 @@
+package service
+
+import (
+	"database/sql"
+	"math"
+	"sort"
+	"time"
+
+	"zaiko/internal/models"
+)
+
+// costPortion is a quantity moved at one unit cost.
+type costPortion struct {
+	quantity int
+	unitCost float64
+}
+
+// receiveCost adds the received portions to the cost layers and the value
+// of the transaction's stock row, and records the cost on the transaction.
+func (s *StockService) receiveCost(tx *sql.Tx, transaction *models.Transaction, portions []costPortion) error {
+	costRepo := s.costRepo.WithTx(tx)
+
+	quantity := 0
+	total := 0.0
+	for _, p := range portions {
+		if err := costRepo.AddLayer(transaction.ProductID, transaction.WarehouseID, transaction.ID, p.unitCost, p.quantity); err != nil {
+			return err
+		}
+		quantity += p.quantity
+		total += float64(p.quantity) * p.unitCost
+	}
+	total = roundCost(total)
+
+	if err := s.stockRepo.WithTx(tx).AddValue(transaction.ProductID, transaction.WarehouseID, total); err != nil {
+		return err
+	}
+	return s.setCost(tx, transaction, quantity, total)
+}
+
+// issueCost takes quantity out of the cost layers and the value of the
+// transaction's stock row, whose quantity has already been lowered, and
+// records the cost on the transaction. Layers are consumed oldest first
+// under either method; the method decides the cost. It returns the issued
+// portions so that a transfer can receive them at the same cost.
+func (s *StockService) issueCost(tx *sql.Tx, transaction *models.Transaction, quantity int) ([]costPortion, error) {
+	stock, err := s.stockRepo.WithTx(tx).FindByProductAndWarehouse(transaction.ProductID, transaction.WarehouseID)
+	if err != nil {
+		return nil, err
+	}
+	average := 0.0
+	if onHand := stock.Quantity + quantity; onHand > 0 {
+		average = stock.Value / float64(onHand)
+	}
+
+	portions, err := s.consumeLayers(tx, transaction.ProductID, transaction.WarehouseID, quantity, average, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var total float64
+	switch {
+	case stock.Quantity == 0:
+		// The last units take whatever value is left, so none lingers
+		total = stock.Value
+	case s.costingMethod == models.CostingFIFO:
+		for _, p := range portions {
+			total += float64(p.quantity) * p.unitCost
+		}
+		total = roundCost(total)
+	default:
+		total = roundCost(average * float64(quantity))
+	}
+
+	if s.costingMethod != models.CostingFIFO || stock.Quantity == 0 {
+		portions = []costPortion{{quantity: quantity, unitCost: total / float64(quantity)}}
+	}
+
+	if err := s.stockRepo.WithTx(tx).AddValue(transaction.ProductID, transaction.WarehouseID, -total); err != nil {
+		return nil, err
+	}
+	if err := s.setCost(tx, transaction, quantity, total); err != nil {
+		return nil, err
+	}
+	return portions, nil
+}
+
+// consumeLayers takes quantity out of the open cost layers of a stock row,
+// oldest first, and returns the consumed portions. The layers received by
+// the transaction first, if any, are consumed before the others. Stock
+// moved outside costing has no layer; it leaves at the average.
+func (s *StockService) consumeLayers(tx *sql.Tx, productID, warehouseID int64, quantity int, average float64, first int64) ([]costPortion, error) {
+	costRepo := s.costRepo.WithTx(tx)
+
+	layers, err := costRepo.OpenLayers(productID, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	if first > 0 {
+		sort.SliceStable(layers, func(i, j int) bool {
+			return isLayerOf(layers[i], first) && !isLayerOf(layers[j], first)
+		})
+	}
+
+	var portions []costPortion
+	remaining := quantity
+	for _, layer := range layers {
+		if remaining == 0 {
+			break
+		}
+
+		used := min(layer.Remaining, remaining)
+		if err := costRepo.Consume(layer.ID, used); err != nil {
+			return nil, err
+		}
+		portions = append(portions, costPortion{quantity: used, unitCost: layer.UnitCost})
+		remaining -= used
+	}
+	if remaining > 0 {
+		portions = append(portions, costPortion{quantity: remaining, unitCost: average})
+	}
+
+	return portions, nil
+}
+
+func isLayerOf(layer models.CostLayer, transactionID int64) bool {
+	return layer.TransactionID != nil && *layer.TransactionID == transactionID
+}
+
+// setCost records the cost of quantity units on the transaction, signed
+// like the transaction quantity.
+func (s *StockService) setCost(tx *sql.Tx, transaction *models.Transaction, quantity int, total float64) error {
+	unitCost := 0.0
+	if quantity > 0 {
+		unitCost = roundCost(total / float64(quantity))
+	}
+	if transaction.Quantity < 0 && total != 0 {
+		total = -total
+	}
+
+	if err := s.transactionRepo.WithTx(tx).SetCost(transaction.ID, unitCost, total); err != nil {
+		return err
+	}
+	transaction.UnitCost = unitCost
+	transaction.TotalCost = total
+	return nil
+}
+
+// defaultUnitCost is the cost of stock received without one: the average
+// cost of what was on hand in the warehouse before the received quantity
+// was added, or else the cost of the latest receipt of the product.
+func (s *StockService) defaultUnitCost(tx *sql.Tx, productID, warehouseID int64, received int) (float64, error) {
+	stock, err := s.stockRepo.WithTx(tx).FindByProductAndWarehouse(productID, warehouseID)
+	if err != nil {
+		return 0, err
+	}
+	if before := stock.Quantity - received; before > 0 && stock.Value > 0 {
+		return roundCost(stock.Value / float64(before)), nil
+	}
+
+	unitCost, _, err := s.costRepo.WithTx(tx).LastUnitCost(productID)
+	return unitCost, err
+}
+
+// Valuation reports the quantity and value of stock at the end of the day
+// filter.AsOf, or today when it is empty.
+func (s *StockService) Valuation(filter models.ValuationFilter) (*models.ValuationReport, error) {
+	asOf := filter.AsOf
+	if asOf == "" {
+		asOf = time.Now().UTC().Format(time.DateOnly)
+	}
+	before, err := nextDay(asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	byWarehouse, err := s.costRepo.ValuationByWarehouse(before, filter.WarehouseIDs)
+	if err != nil {
+		return nil, err
+	}
+	byCategory, err := s.costRepo.ValuationByCategory(before, filter.WarehouseIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.ValuationReport{
+		AsOf:        asOf,
+		Method:      s.costingMethod,
+		ByWarehouse: []models.WarehouseValuation{},
+		ByCategory:  []models.CategoryValuation{},
+	}
+	for _, v := range byWarehouse {
+		v.Value = roundCost(v.Value)
+		report.TotalQuantity += v.Quantity
+		report.TotalValue += v.Value
+		report.ByWarehouse = append(report.ByWarehouse, v)
+	}
+	for _, v := range byCategory {
+		v.Value = roundCost(v.Value)
+		report.ByCategory = append(report.ByCategory, v)
+	}
+	report.TotalValue = roundCost(report.TotalValue)
+
+	return report, nil
+}
+
+// roundCost rounds an amount to four decimal places, which keeps the
+// floating point error of repeated averaging out of stored values.
+func roundCost(amount float64) float64 {
+	return math.Round(amount*10000) / 10000
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"zaiko/internal/database/dbtest"
+	"zaiko/internal/models"
+)
+
+func newCostingService(t *testing.T, method models.CostingMethod) *StockService {
+	t.Helper()
+
+	db := dbtest.New(t)
+	dbtest.Exec(t, db,
+		"INSERT INTO products (code, name, unit, category_id) VALUES ('P-001', 'Test product', 'pcs', 1)",
+		"INSERT INTO warehouses (name) VALUES ('Main')",
+		"INSERT INTO warehouses (name) VALUES ('Sub')",
+	)
+	return NewStockService(db, method)
+}
+
+func receive(t *testing.T, svc *StockService, quantity int, unitCost *float64) *models.Transaction {
+	t.Helper()
+
+	transaction, err := svc.StockIn(models.StockMovementRequest{ProductID: 1, WarehouseID: 1, Quantity: quantity, UnitCost: unitCost}, 1)
+	if err != nil {
+		t.Fatalf("stock in: %v", err)
+	}
+	return transaction
+}
+
+func cost(v float64) *float64 { return &v }
+
+func TestCostOfGoodsIssued(t *testing.T) {
+	tests := []struct {
+		method       models.CostingMethod
+		issued       float64
+		transferred  float64
+		defaultCost  float64
+		mainValueEnd float64
+	}{
+		// Average 150: 5 out at 150, 5 transferred at 150, 10 left worth 1500
+		{models.CostingMovingAverage, 750, 750, 150, 1500 + 150*10},
+		// Oldest first: 5 out at 100, 5 transferred at 100, 10 left at 200
+		{models.CostingFIFO, 500, 500, 200, 2000 + 200*10},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.method), func(t *testing.T) {
+			svc := newCostingService(t, tt.method)
+
+			receive(t, svc, 10, cost(100))
+			receive(t, svc, 10, cost(200))
+
+			out, err := svc.StockOut(models.StockMovementRequest{ProductID: 1, WarehouseID: 1, Quantity: 5}, 1)
+			if err != nil {
+				t.Fatalf("stock out: %v", err)
+			}
+			if out.TotalCost != tt.issued {
+				t.Errorf("cost of goods issued = %v, want %v", out.TotalCost, tt.issued)
+			}
+
+			from, to, err := svc.Transfer(models.StockTransferRequest{ProductID: 1, FromWarehouseID: 1, ToWarehouseID: 2, Quantity: 5}, 1)
+			if err != nil {
+				t.Fatalf("transfer: %v", err)
+			}
+			if from.TotalCost != -tt.transferred || to.TotalCost != tt.transferred {
+				t.Errorf("transfer cost = %v/%v, want -%v/%v", from.TotalCost, to.TotalCost, tt.transferred, tt.transferred)
+			}
+
+			// Without a unit cost stock comes in at the average on hand
+			in := receive(t, svc, 10, nil)
+			if in.UnitCost != tt.defaultCost {
+				t.Errorf("default unit cost = %v, want %v", in.UnitCost, tt.defaultCost)
+			}
+
+			stock, err := svc.stockRepo.FindByProductAndWarehouse(1, 1)
+			if err != nil {
+				t.Fatalf("find stock: %v", err)
+			}
+			if stock.Quantity != 20 || stock.Value != tt.mainValueEnd {
+				t.Errorf("main stock = %d worth %v, want 20 worth %v", stock.Quantity, stock.Value, tt.mainValueEnd)
+			}
+
+			report, err := svc.Valuation(models.ValuationFilter{})
+			if err != nil {
+				t.Fatalf("valuation: %v", err)
+			}
+			if want := tt.mainValueEnd + tt.transferred; report.TotalValue != want || report.TotalQuantity != 25 {
+				t.Errorf("valuation = %d worth %v, want 25 worth %v", report.TotalQuantity, report.TotalValue, want)
+			}
+			if len(report.ByWarehouse) != 2 || len(report.ByCategory) != 1 || report.ByCategory[0].Value != report.TotalValue {
+				t.Errorf("valuation breakdown = %+v / %+v", report.ByWarehouse, report.ByCategory)
+			}
+		})
+	}
+}
+
+func TestIssuingEverythingClearsValue(t *testing.T) {
+	svc := newCostingService(t, models.CostingMovingAverage)
+
+	receive(t, svc, 3, cost(10))
+	receive(t, svc, 3, cost(20))
+	for i := 0; i < 3; i++ {
+		if _, err := svc.StockOut(models.StockMovementRequest{ProductID: 1, WarehouseID: 1, Quantity: 2}, 1); err != nil {
+			t.Fatalf("stock out: %v", err)
+		}
+	}
+
+	stock, err := svc.stockRepo.FindByProductAndWarehouse(1, 1)
+	if err != nil {
+		t.Fatalf("find stock: %v", err)
+	}
+	if stock.Quantity != 0 || stock.Value != 0 {
+		t.Errorf("stock = %d worth %v, want nothing", stock.Quantity, stock.Value)
+	}
+}
+
+func TestValuationAsOf(t *testing.T) {
+	svc := newCostingService(t, models.CostingFIFO)
+	receive(t, svc, 4, cost(25))
+
+	yesterday := time.Now().UTC().AddDate(0, 0, -1).Format(time.DateOnly)
+	report, err := svc.Valuation(models.ValuationFilter{AsOf: yesterday})
+	if err != nil {
+		t.Fatalf("valuation: %v", err)
+	}
+	if report.TotalQuantity != 0 || report.TotalValue != 0 || len(report.ByWarehouse) != 0 {
+		t.Errorf("valuation as of %s = %+v, want empty", yesterday, report)
+	}
+
+	report, err = svc.Valuation(models.ValuationFilter{})
+	if err != nil {
+		t.Fatalf("valuation: %v", err)
+	}
+	if report.TotalQuantity != 4 || report.TotalValue != 100 || report.Method != models.CostingFIFO {
+		t.Errorf("valuation today = %+v, want 4 worth 100 by fifo", report)
+	}
+}
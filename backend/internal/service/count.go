@@ -0,0 +1,342 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+	"zaiko/internal/repository"
+)
+
+var (
+	ErrCountNotFound      = errors.New("count session not found")
+	ErrCountNotOpen       = errors.New("count session is not open")
+	ErrCountScopeRequired = errors.New("warehouse_id or category_id is required")
+	ErrCountOverlap       = errors.New("an open count session already covers this scope")
+)
+
+// CountOutOfScopeError is returned when a counted item is not covered by the
+// session's warehouse or category.
+type CountOutOfScopeError struct {
+	ProductID   int64
+	WarehouseID int64
+}
+
+func (e *CountOutOfScopeError) Error() string {
+	return fmt.Sprintf("product %d in warehouse %d is outside the count scope", e.ProductID, e.WarehouseID)
+}
+
+// CountIncompleteError is returned when finalizing a session that still has
+// uncounted lines.
+type CountIncompleteError struct {
+	Uncounted int
+}
+
+func (e *CountIncompleteError) Error() string {
+	return fmt.Sprintf("%d lines have not been counted", e.Uncounted)
+}
+
+// CountSerialsError is returned when finalizing a variance on a serialized
+// product whose count line does not name one serial number for each unit
+// missing or found.
+type CountSerialsError struct {
+	ProductID   int64
+	WarehouseID int64
+	Variance    int
+	Serials     int
+}
+
+func (e *CountSerialsError) Error() string {
+	return fmt.Sprintf("product %d in warehouse %d has a variance of %d but %d serial numbers", e.ProductID, e.WarehouseID, e.Variance, e.Serials)
+}
+
+// CountService manages physical inventory count sessions.
+type CountService struct {
+	db           *database.DB
+	countRepo    *repository.CountRepository
+	productRepo  *repository.ProductRepository
+	stockRepo    *repository.StockRepository
+	stockService *StockService
+}
+
+func NewCountService(db *database.DB, stockService *StockService) *CountService {
+	return &CountService{
+		db:           db,
+		countRepo:    repository.NewCountRepository(db),
+		productRepo:  repository.NewProductRepository(db),
+		stockRepo:    repository.NewStockRepository(db),
+		stockService: stockService,
+	}
+}
+
+func (s *CountService) FindAll(filter models.CountFilter) ([]models.CountSession, error) {
+	return s.countRepo.FindAll(filter)
+}
+
+// Get returns a session with its lines. When varianceOnly is set only
+// counted lines that differ from the expected quantity are included.
+func (s *CountService) Get(id int64, varianceOnly bool) (*models.CountSession, error) {
+	session, err := s.countRepo.FindByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lines, err := s.countRepo.FindLines(id)
+	if err != nil {
+		return nil, err
+	}
+
+	session.Lines = []models.CountLine{}
+	for _, line := range lines {
+		if varianceOnly && (line.Variance == nil || *line.Variance == 0) {
+			continue
+		}
+		session.Lines = append(session.Lines, line)
+	}
+
+	return session, nil
+}
+
+// Create opens a session and snapshots the expected quantities. Stock in
+// the session's scope is locked until it is finalized or cancelled.
+func (s *CountService) Create(req models.CreateCountSessionRequest, userID int64) (*models.CountSession, error) {
+	if req.WarehouseID == 0 && req.CategoryID == 0 {
+		return nil, ErrCountScopeRequired
+	}
+
+	var id int64
+	err := s.db.InStockTx(func(tx *sql.Tx) error {
+		countRepo := s.countRepo.WithTx(tx)
+
+		// Insert first so the transaction holds the write lock before the
+		// overlap check; the insert is rolled back if the check fails.
+		var err error
+		id, err = countRepo.CreateSession(req, userID)
+		if err != nil {
+			return err
+		}
+
+		sessions, err := countRepo.FindAll(models.CountFilter{Status: string(models.CountStatusOpen)})
+		if err != nil {
+			return err
+		}
+		for _, other := range sessions {
+			if other.ID != id && scopesOverlap(other, req) {
+				return ErrCountOverlap
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Get(id, false)
+}
+
+// Submit records counted quantities for an open session.
+func (s *CountService) Submit(id int64, req models.SubmitCountRequest, userID int64) (*models.CountSession, error) {
+	err := s.db.InStockTx(func(tx *sql.Tx) error {
+		countRepo := s.countRepo.WithTx(tx)
+		productRepo := s.productRepo.WithTx(tx)
+
+		session, err := countRepo.FindByID(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrCountNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if session.Status != models.CountStatusOpen {
+			return ErrCountNotOpen
+		}
+
+		for _, entry := range req.Lines {
+			covered, err := inScope(productRepo, session, entry)
+			if err != nil {
+				return err
+			}
+			if !covered {
+				return &CountOutOfScopeError{ProductID: entry.ProductID, WarehouseID: entry.WarehouseID}
+			}
+			if len(entry.Serials) > 0 {
+				product, err := productRepo.FindByID(entry.ProductID)
+				if err != nil {
+					return err
+				}
+				if !product.Serialized {
+					return ErrSerialsNotAllowed
+				}
+			}
+
+			if err := countRepo.SaveCount(id, entry, userID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Get(id, false)
+}
+
+// Finalize posts an adjustment transaction for every line whose counted
+// quantity differs from the stock on hand, then releases the lock. A
+// shortage is issued like a stock-out, taking lots by FEFO, and fails if it
+// would consume stock reserved for someone. A gain is booked outside any
+// lot. A variance on a serialized product moves the serial numbers named on
+// its line, and is refused with a CountSerialsError unless there is one for
+// each unit. Nothing is posted if any line fails, and the session stays
+// open so that the lines can be corrected.
+func (s *CountService) Finalize(id int64, userID int64) (*models.CountSession, error) {
+	err := s.db.InStockTx(func(tx *sql.Tx) error {
+		countRepo := s.countRepo.WithTx(tx)
+		stockRepo := s.stockRepo.WithTx(tx)
+		productRepo := s.productRepo.WithTx(tx)
+
+		closed, err := countRepo.Close(id, models.CountStatusFinalized)
+		if err != nil {
+			return err
+		}
+		if !closed {
+			return s.closeError(countRepo, id)
+		}
+
+		lines, err := countRepo.FindLines(id)
+		if err != nil {
+			return err
+		}
+
+		uncounted := 0
+		for _, line := range lines {
+			if line.CountedQuantity == nil {
+				uncounted++
+			}
+		}
+		if uncounted > 0 {
+			return &CountIncompleteError{Uncounted: uncounted}
+		}
+
+		for _, line := range lines {
+			// The scope was locked while counting, so the stock on hand still
+			// matches the snapshot unless the line was added during the count.
+			onHand := 0
+			stock, err := stockRepo.FindByProductAndWarehouse(line.ProductID, line.WarehouseID)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			if stock != nil {
+				onHand = stock.Quantity
+			}
+
+			delta := *line.CountedQuantity - onHand
+			if delta == 0 {
+				continue
+			}
+
+			product, err := productRepo.FindByID(line.ProductID)
+			if err != nil {
+				return err
+			}
+			units := delta
+			if units < 0 {
+				units = -units
+			}
+			if product.Serialized && len(line.Serials) != units {
+				return &CountSerialsError{
+					ProductID:   line.ProductID,
+					WarehouseID: line.WarehouseID,
+					Variance:    delta,
+					Serials:     len(line.Serials),
+				}
+			}
+
+			transaction, err := s.stockService.postAdjustment(tx, models.StockMovementRequest{
+				ProductID:   line.ProductID,
+				WarehouseID: line.WarehouseID,
+				Serials:     line.Serials,
+				Note:        fmt.Sprintf("Inventory count #%d", id),
+			}, delta, userID)
+			if err != nil {
+				return err
+			}
+
+			if err := countRepo.SetAdjustment(line.ID, transaction.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Get(id, false)
+}
+
+// Cancel closes a session without posting any adjustments.
+func (s *CountService) Cancel(id int64) (*models.CountSession, error) {
+	err := s.db.InTx(func(tx *sql.Tx) error {
+		countRepo := s.countRepo.WithTx(tx)
+
+		closed, err := countRepo.Close(id, models.CountStatusCancelled)
+		if err != nil {
+			return err
+		}
+		if !closed {
+			return s.closeError(countRepo, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Get(id, false)
+}
+
+func (s *CountService) closeError(countRepo *repository.CountRepository, id int64) error {
+	if _, err := countRepo.FindByID(id); errors.Is(err, sql.ErrNoRows) {
+		return ErrCountNotFound
+	} else if err != nil {
+		return err
+	}
+	return ErrCountNotOpen
+}
+
+// inScope reports whether an entry is covered by the session. It reads
+// through productRepo so that it sees the caller's transaction.
+func inScope(productRepo *repository.ProductRepository, session *models.CountSession, entry models.CountEntry) (bool, error) {
+	if session.WarehouseID != nil && *session.WarehouseID != entry.WarehouseID {
+		return false, nil
+	}
+
+	product, err := productRepo.FindByID(entry.ProductID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if session.CategoryID == nil {
+		return true, nil
+	}
+	return product.CategoryID == *session.CategoryID, nil
+}
+
+func scopesOverlap(session models.CountSession, req models.CreateCountSessionRequest) bool {
+	if session.WarehouseID != nil && req.WarehouseID != 0 && *session.WarehouseID != req.WarehouseID {
+		return false
+	}
+	if session.CategoryID != nil && req.CategoryID != 0 && *session.CategoryID != req.CategoryID {
+		return false
+	}
+	return true
+}
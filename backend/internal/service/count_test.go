@@ -0,0 +1,345 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"zaiko/internal/database/dbtest"
+	"zaiko/internal/models"
+)
+
+func newCountService(t *testing.T) (*CountService, *StockService) {
+	t.Helper()
+
+	svc := newCostingService(t, models.CostingMovingAverage)
+	return NewCountService(svc.db, svc), svc
+}
+
+// count opens a session over warehouse 1 and records counted for product 1.
+func count(t *testing.T, counts *CountService, counted int) *models.CountSession {
+	t.Helper()
+
+	session, err := counts.Create(models.CreateCountSessionRequest{WarehouseID: 1}, 1)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	_, err = counts.Submit(session.ID, models.SubmitCountRequest{
+		Lines: []models.CountEntry{{ProductID: 1, WarehouseID: 1, CountedQuantity: &counted}},
+	}, 1)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	return session
+}
+
+func TestFinalizeLottedVariance(t *testing.T) {
+	counts, svc := newCountService(t)
+
+	for _, lot := range []models.LotRequest{
+		{LotNumber: "L-LATE", ExpiresOn: "2030-06-30"},
+		{LotNumber: "L-EARLY", ExpiresOn: "2030-01-31"},
+	} {
+		_, err := svc.StockIn(models.StockMovementRequest{
+			ProductID: 1, WarehouseID: 1, Quantity: 5, UnitCost: cost(100), Lot: &lot,
+		}, 1)
+		if err != nil {
+			t.Fatalf("stock in %s: %v", lot.LotNumber, err)
+		}
+	}
+
+	lotQuantities := func() map[string]int {
+		t.Helper()
+		quantities := map[string]int{}
+		for _, number := range []string{"L-EARLY", "L-LATE"} {
+			lot, err := svc.lotRepo.FindByNumber(1, 1, number)
+			if err != nil {
+				t.Fatalf("find lot %s: %v", number, err)
+			}
+			quantities[number] = lot.Quantity
+		}
+		return quantities
+	}
+
+	// A shortage leaves from the lot that expires first
+	session := count(t, counts, 7)
+	if _, err := counts.Finalize(session.ID, 1); err != nil {
+		t.Fatalf("finalize shortage: %v", err)
+	}
+	if got := lotQuantities(); got["L-EARLY"] != 2 || got["L-LATE"] != 5 {
+		t.Errorf("lots after shortage = %v, want L-EARLY 2 and L-LATE 5", got)
+	}
+
+	// A gain is booked outside the lots
+	session = count(t, counts, 9)
+	if _, err := counts.Finalize(session.ID, 1); err != nil {
+		t.Fatalf("finalize gain: %v", err)
+	}
+	if got := lotQuantities(); got["L-EARLY"] != 2 || got["L-LATE"] != 5 {
+		t.Errorf("lots after gain = %v, want them unchanged", got)
+	}
+	stock, err := svc.stockRepo.FindByProductAndWarehouse(1, 1)
+	if err != nil {
+		t.Fatalf("find stock: %v", err)
+	}
+	if stock.Quantity != 9 {
+		t.Errorf("stock = %d, want 9", stock.Quantity)
+	}
+}
+
+func TestFinalizeSerializedVariance(t *testing.T) {
+	counts, svc := newCountService(t)
+	dbtest.Exec(t, svc.db, "UPDATE products SET serialized = 1 WHERE id = 1")
+
+	_, err := svc.StockIn(models.StockMovementRequest{
+		ProductID: 1, WarehouseID: 1, Quantity: 2, UnitCost: cost(100), Serials: []string{"SN-1", "SN-2"},
+	}, 1)
+	if err != nil {
+		t.Fatalf("stock in: %v", err)
+	}
+
+	// A shortage has to name the missing unit
+	session := count(t, counts, 1)
+	_, err = counts.Finalize(session.ID, 1)
+	var mismatch *CountSerialsError
+	if !errors.As(err, &mismatch) || mismatch.Variance != -1 || mismatch.Serials != 0 {
+		t.Fatalf("finalize err = %v, want CountSerialsError for a variance of -1", err)
+	}
+
+	// The session is still open, so the line can be corrected
+	counted := 1
+	_, err = counts.Submit(session.ID, models.SubmitCountRequest{Lines: []models.CountEntry{
+		{ProductID: 1, WarehouseID: 1, CountedQuantity: &counted, Serials: []string{"SN-2"}},
+	}}, 1)
+	if err != nil {
+		t.Fatalf("submit serials: %v", err)
+	}
+	got, err := counts.Finalize(session.ID, 1)
+	if err != nil {
+		t.Fatalf("finalize shortage: %v", err)
+	}
+	if serials := got.Lines[0].Serials; len(serials) != 1 || serials[0] != "SN-2" {
+		t.Errorf("line serials = %v, want [SN-2]", serials)
+	}
+
+	// A gain names the units found
+	counted = 2
+	session, err = counts.Create(models.CreateCountSessionRequest{WarehouseID: 1}, 1)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	_, err = counts.Submit(session.ID, models.SubmitCountRequest{Lines: []models.CountEntry{
+		{ProductID: 1, WarehouseID: 1, CountedQuantity: &counted, Serials: []string{"SN-3"}},
+	}}, 1)
+	if err != nil {
+		t.Fatalf("submit gain: %v", err)
+	}
+	if _, err := counts.Finalize(session.ID, 1); err != nil {
+		t.Fatalf("finalize gain: %v", err)
+	}
+
+	for serial, want := range map[string]models.SerialStatus{
+		"SN-1": models.SerialStatusInStock,
+		"SN-2": models.SerialStatusShipped,
+		"SN-3": models.SerialStatusInStock,
+	} {
+		got, err := svc.serialRepo.FindByNumber(1, serial)
+		if err != nil {
+			t.Fatalf("find serial %s: %v", serial, err)
+		}
+		if got.Status != want {
+			t.Errorf("serial %s status = %s, want %s", serial, got.Status, want)
+		}
+	}
+}
+
+func TestFinalizeShortCountOnReservedProduct(t *testing.T) {
+	counts, svc := newCountService(t)
+
+	receive(t, svc, 10, cost(100))
+	_, err := NewReservationService(svc.db).Create(models.CreateReservationRequest{
+		ProductID: 1, WarehouseID: 1, Quantity: 8, OwnerRef: "SO-1",
+	}, 1)
+	if err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+
+	session := count(t, counts, 7)
+	_, err = counts.Finalize(session.ID, 1)
+	var reserved *ReservedStockError
+	if !errors.As(err, &reserved) {
+		t.Fatalf("finalize err = %v, want ReservedStockError", err)
+	}
+
+	// Nothing was posted and the session is still open
+	stock, err := svc.stockRepo.FindByProductAndWarehouse(1, 1)
+	if err != nil {
+		t.Fatalf("find stock: %v", err)
+	}
+	if stock.Quantity != 10 {
+		t.Errorf("stock = %d, want 10", stock.Quantity)
+	}
+	got, err := counts.Get(session.ID, false)
+	if err != nil {
+		t.Fatalf("get session: %v", err)
+	}
+	if got.Status != models.CountStatusOpen {
+		t.Errorf("status = %s, want open", got.Status)
+	}
+}
+
+func TestCountLocksStock(t *testing.T) {
+	counts, svc := newCountService(t)
+	receive(t, svc, 10, cost(100))
+
+	session, err := counts.Create(models.CreateCountSessionRequest{WarehouseID: 1}, 1)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	if _, err := counts.Create(models.CreateCountSessionRequest{CategoryID: 1}, 1); !errors.Is(err, ErrCountOverlap) {
+		t.Errorf("overlapping session err = %v, want ErrCountOverlap", err)
+	}
+
+	move := models.StockMovementRequest{ProductID: 1, WarehouseID: 1, Quantity: 1}
+	if _, err := svc.StockIn(move, 1); !errors.Is(err, ErrStockLocked) {
+		t.Errorf("stock in err = %v, want ErrStockLocked", err)
+	}
+	if _, err := svc.StockOut(move, 1); !errors.Is(err, ErrStockLocked) {
+		t.Errorf("stock out err = %v, want ErrStockLocked", err)
+	}
+
+	// Other warehouses are not locked
+	if _, err := svc.StockIn(models.StockMovementRequest{ProductID: 1, WarehouseID: 2, Quantity: 1}, 1); err != nil {
+		t.Errorf("stock in to another warehouse: %v", err)
+	}
+
+	if _, err := counts.Cancel(session.ID); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if _, err := svc.StockIn(move, 1); err != nil {
+		t.Errorf("stock in after cancel: %v", err)
+	}
+}
+
+func TestSubmitCount(t *testing.T) {
+	counts, svc := newCountService(t)
+	dbtest.Exec(t, svc.db, "INSERT INTO products (code, name, unit, category_id) VALUES ('P-002', 'Other product', 'pcs', 2)")
+	receive(t, svc, 10, cost(100))
+
+	session, err := counts.Create(models.CreateCountSessionRequest{WarehouseID: 1, CategoryID: 1}, 1)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	counted := 3
+	_, err = counts.Submit(session.ID, models.SubmitCountRequest{Lines: []models.CountEntry{
+		{ProductID: 1, WarehouseID: 1, CountedQuantity: &counted, Serials: []string{"SN-1"}},
+	}}, 1)
+	if !errors.Is(err, ErrSerialsNotAllowed) {
+		t.Errorf("submit serials for an unserialized product err = %v, want ErrSerialsNotAllowed", err)
+	}
+
+	for _, entry := range []models.CountEntry{
+		{ProductID: 1, WarehouseID: 2, CountedQuantity: &counted},  // other warehouse
+		{ProductID: 2, WarehouseID: 1, CountedQuantity: &counted},  // other category
+		{ProductID: 99, WarehouseID: 1, CountedQuantity: &counted}, // no such product
+	} {
+		_, err := counts.Submit(session.ID, models.SubmitCountRequest{Lines: []models.CountEntry{entry}}, 1)
+		var outOfScope *CountOutOfScopeError
+		if !errors.As(err, &outOfScope) {
+			t.Errorf("submit product %d in warehouse %d err = %v, want CountOutOfScopeError", entry.ProductID, entry.WarehouseID, err)
+		}
+	}
+
+	got, err := counts.Submit(session.ID, models.SubmitCountRequest{
+		Lines: []models.CountEntry{{ProductID: 1, WarehouseID: 1, CountedQuantity: &counted}},
+	}, 1)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if len(got.Lines) != 1 || got.Lines[0].Variance == nil || *got.Lines[0].Variance != -7 {
+		t.Errorf("lines = %+v, want one line with variance -7", got.Lines)
+	}
+
+	if _, err := counts.Cancel(session.ID); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	_, err = counts.Submit(session.ID, models.SubmitCountRequest{
+		Lines: []models.CountEntry{{ProductID: 1, WarehouseID: 1, CountedQuantity: &counted}},
+	}, 1)
+	if !errors.Is(err, ErrCountNotOpen) {
+		t.Errorf("submit to cancelled session err = %v, want ErrCountNotOpen", err)
+	}
+}
+
+func TestFinalizeCountVariance(t *testing.T) {
+	counts, svc := newCountService(t)
+	dbtest.Exec(t, svc.db, "INSERT INTO products (code, name, unit, category_id) VALUES ('P-002', 'Other product', 'pcs', 1)")
+	receive(t, svc, 10, cost(100))
+
+	session, err := counts.Create(models.CreateCountSessionRequest{WarehouseID: 1}, 1)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	if _, err := counts.Finalize(session.ID, 1); !errors.As(err, new(*CountIncompleteError)) {
+		t.Errorf("finalize uncounted err = %v, want CountIncompleteError", err)
+	}
+
+	short, found := 8, 4
+	_, err = counts.Submit(session.ID, models.SubmitCountRequest{Lines: []models.CountEntry{
+		{ProductID: 1, WarehouseID: 1, CountedQuantity: &short},
+		// Found during the count, so not in the snapshot
+		{ProductID: 2, WarehouseID: 1, CountedQuantity: &found},
+	}}, 1)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+
+	got, err := counts.Finalize(session.ID, 1)
+	if err != nil {
+		t.Fatalf("finalize: %v", err)
+	}
+	if got.Status != models.CountStatusFinalized {
+		t.Errorf("status = %s, want finalized", got.Status)
+	}
+
+	for _, tt := range []struct {
+		productID int64
+		quantity  int
+		delta     int
+		totalCost float64
+	}{
+		// The shortage leaves at the average cost
+		{1, 8, -2, -200},
+		{2, 4, 4, 0},
+	} {
+		stock, err := svc.stockRepo.FindByProductAndWarehouse(tt.productID, 1)
+		if err != nil {
+			t.Fatalf("find stock for product %d: %v", tt.productID, err)
+		}
+		if stock.Quantity != tt.quantity {
+			t.Errorf("product %d stock = %d, want %d", tt.productID, stock.Quantity, tt.quantity)
+		}
+
+		var line *models.CountLine
+		for i := range got.Lines {
+			if got.Lines[i].ProductID == tt.productID {
+				line = &got.Lines[i]
+			}
+		}
+		if line == nil || line.AdjustmentTransactionID == nil {
+			t.Fatalf("product %d line = %+v, want an adjustment", tt.productID, line)
+		}
+		adjustment, err := svc.transactionRepo.FindByID(*line.AdjustmentTransactionID)
+		if err != nil {
+			t.Fatalf("find adjustment: %v", err)
+		}
+		if adjustment.Type != models.TransactionTypeAdjustment || adjustment.Quantity != tt.delta || adjustment.TotalCost != tt.totalCost {
+			t.Errorf("product %d adjustment = %s %d costing %v, want adjustment %d costing %v",
+				tt.productID, adjustment.Type, adjustment.Quantity, adjustment.TotalCost, tt.delta, tt.totalCost)
+		}
+	}
+
+	if _, err := counts.Finalize(session.ID, 1); !errors.Is(err, ErrCountNotOpen) {
+		t.Errorf("finalize twice err = %v, want ErrCountNotOpen", err)
+	}
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"zaiko/internal/models"
+)
+
+// Ledger lists the movements of a product over the filter's date range
+// with the opening balance and the running balance after each movement.
+func (s *StockService) Ledger(filter models.LedgerFilter) (*models.StockLedger, error) {
+	if _, err := s.productRepo.FindByID(filter.ProductID); errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrProductNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	warehouseIDs := filter.WarehouseIDs
+	if filter.WarehouseID > 0 {
+		warehouseIDs = []int64{filter.WarehouseID}
+	}
+
+	var before string
+	if filter.To != "" {
+		var err error
+		if before, err = nextDay(filter.To); err != nil {
+			return nil, err
+		}
+	}
+
+	ledger := &models.StockLedger{
+		ProductID:   filter.ProductID,
+		WarehouseID: filter.WarehouseID,
+		From:        filter.From,
+		To:          filter.To,
+		Entries:     []models.LedgerEntry{},
+	}
+
+	if filter.From != "" {
+		var err error
+		ledger.OpeningQuantity, ledger.OpeningValue, err = s.transactionRepo.Balance(filter.ProductID, warehouseIDs, filter.From)
+		if err != nil {
+			return nil, err
+		}
+		ledger.OpeningValue = roundCost(ledger.OpeningValue)
+	}
+
+	transactions, err := s.transactionRepo.FindForLedger(filter.ProductID, warehouseIDs, filter.From, before)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, value := ledger.OpeningQuantity, ledger.OpeningValue
+	for _, t := range transactions {
+		balance += t.SignedQuantity()
+		value = roundCost(value + t.SignedCost())
+		ledger.Entries = append(ledger.Entries, models.LedgerEntry{
+			Transaction:  t,
+			Change:       t.SignedQuantity(),
+			ValueChange:  t.SignedCost(),
+			Balance:      balance,
+			BalanceValue: value,
+		})
+	}
+	ledger.ClosingQuantity, ledger.ClosingValue = balance, value
+
+	return ledger, nil
+}
+
+// StockAsOf rebuilds the stock at the end of the day filter.AsOf from the
+// transactions.
+func (s *StockService) StockAsOf(filter models.StockFilter) ([]models.Stock, error) {
+	before, err := nextDay(filter.AsOf)
+	if err != nil {
+		return nil, err
+	}
+
+	stocks, err := s.stockRepo.FindAsOf(filter, before)
+	if err != nil {
+		return nil, err
+	}
+	for i := range stocks {
+		stocks[i].Value = roundCost(stocks[i].Value)
+	}
+	return stocks, nil
+}
+
+// nextDay returns the day after a YYYY-MM-DD date. Transactions created
+// before it are those "as of" the date.
+func nextDay(date string) (string, error) {
+	day, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		return "", err
+	}
+	return day.AddDate(0, 0, 1).Format(time.DateOnly), nil
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"zaiko/internal/database/dbtest"
+	"zaiko/internal/models"
+)
+
+func TestLedger(t *testing.T) {
+	svc := newCostingService(t, models.CostingMovingAverage)
+
+	receive(t, svc, 10, cost(10))
+	if _, err := svc.StockOut(models.StockMovementRequest{ProductID: 1, WarehouseID: 1, Quantity: 3}, 1); err != nil {
+		t.Fatalf("stock out: %v", err)
+	}
+	receive(t, svc, 5, cost(16))
+	if _, _, err := svc.Transfer(models.StockTransferRequest{ProductID: 1, FromWarehouseID: 1, ToWarehouseID: 2, Quantity: 2}, 1); err != nil {
+		t.Fatalf("transfer: %v", err)
+	}
+
+	// The out and the receipt share a second; the id keeps them in order
+	dbtest.Exec(t, svc.db,
+		"UPDATE transactions SET created_at = '2026-03-01 09:00:00' WHERE id = 1",
+		"UPDATE transactions SET created_at = '2026-03-15 12:00:00' WHERE id IN (2, 3)",
+		"UPDATE transactions SET created_at = '2026-04-02 08:00:00' WHERE id IN (4, 5)",
+	)
+
+	ledger, err := svc.Ledger(models.LedgerFilter{ProductID: 1, WarehouseID: 1, From: "2026-03-10", To: "2026-03-31"})
+	if err != nil {
+		t.Fatalf("ledger: %v", err)
+	}
+	if ledger.OpeningQuantity != 10 || ledger.OpeningValue != 100 {
+		t.Errorf("opening = %d worth %v, want 10 worth 100", ledger.OpeningQuantity, ledger.OpeningValue)
+	}
+	var balances []int
+	for _, e := range ledger.Entries {
+		balances = append(balances, e.Balance)
+	}
+	if len(balances) != 2 || balances[0] != 7 || balances[1] != 12 {
+		t.Errorf("balances = %v, want [7 12]", balances)
+	}
+	if ledger.ClosingQuantity != 12 || ledger.ClosingValue != 150 {
+		t.Errorf("closing = %d worth %v, want 12 worth 150", ledger.ClosingQuantity, ledger.ClosingValue)
+	}
+
+	// Across all warehouses the transfer legs cancel out
+	ledger, err = svc.Ledger(models.LedgerFilter{ProductID: 1})
+	if err != nil {
+		t.Fatalf("ledger: %v", err)
+	}
+	if len(ledger.Entries) != 5 || ledger.ClosingQuantity != 12 || ledger.ClosingValue != 150 {
+		t.Errorf("ledger = %d entries closing at %d worth %v, want 5 closing at 12 worth 150",
+			len(ledger.Entries), ledger.ClosingQuantity, ledger.ClosingValue)
+	}
+
+	if _, err := svc.Ledger(models.LedgerFilter{ProductID: 99}); !errors.Is(err, ErrProductNotFound) {
+		t.Errorf("ledger of a missing product: err = %v, want ErrProductNotFound", err)
+	}
+}
+
+func TestStockAsOf(t *testing.T) {
+	svc := newCostingService(t, models.CostingMovingAverage)
+
+	receive(t, svc, 10, cost(10))
+	if _, _, err := svc.Transfer(models.StockTransferRequest{ProductID: 1, FromWarehouseID: 1, ToWarehouseID: 2, Quantity: 4}, 1); err != nil {
+		t.Fatalf("transfer: %v", err)
+	}
+	dbtest.Exec(t, svc.db,
+		"UPDATE transactions SET created_at = '2026-03-31 23:59:59' WHERE id = 1",
+		"UPDATE transactions SET created_at = '2026-04-01 00:00:00' WHERE id IN (2, 3)",
+	)
+
+	stocks, err := svc.StockAsOf(models.StockFilter{AsOf: "2026-03-31"})
+	if err != nil {
+		t.Fatalf("stock as of: %v", err)
+	}
+	if len(stocks) != 1 || stocks[0].WarehouseID != 1 || stocks[0].Quantity != 10 || stocks[0].Value != 100 {
+		t.Errorf("stock as of 2026-03-31 = %+v, want 10 worth 100 in warehouse 1", stocks)
+	}
+
+	stocks, err = svc.StockAsOf(models.StockFilter{AsOf: "2026-04-01", WarehouseID: 2})
+	if err != nil {
+		t.Fatalf("stock as of: %v", err)
+	}
+	if len(stocks) != 1 || stocks[0].Quantity != 4 || stocks[0].Value != 40 {
+		t.Errorf("stock as of 2026-04-01 in warehouse 2 = %+v, want 4 worth 40", stocks)
+	}
+}
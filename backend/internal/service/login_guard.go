@@ -0,0 +1,183 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+	"zaiko/internal/repository"
+)
+
+const (
+	// loginFailuresResetAfter forgets failures after this long without one
+	loginFailuresResetAfter = 24 * time.Hour
+	// maxLoginLockout caps the doubling lockout
+	maxLoginLockout = 24 * time.Hour
+)
+
+// LoginLockedError is returned when a username or client IP may not attempt
+// a login yet.
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("too many failed logins, retry after %s", e.RetryAfter)
+}
+
+// LoginGuard throttles password guessing. Failed logins are counted per
+// username and per client IP. Each failure makes the next attempt wait
+// twice as long, starting at one second; once the limit is reached the
+// username or IP is locked out for the lockout duration, doubling with every
+// further failure. Lockouts are recorded as events.
+type LoginGuard struct {
+	throttleRepo     *repository.LoginThrottleRepository
+	maxFailures      int
+	maxFailuresPerIP int
+	lockout          time.Duration
+}
+
+func NewLoginGuard(db *database.DB, maxFailures, maxFailuresPerIP int, lockout time.Duration) *LoginGuard {
+	return &LoginGuard{
+		throttleRepo:     repository.NewLoginThrottleRepository(db),
+		maxFailures:      maxFailures,
+		maxFailuresPerIP: maxFailuresPerIP,
+		lockout:          lockout,
+	}
+}
+
+// WithTx returns a copy of the guard that runs its queries in tx.
+func (g *LoginGuard) WithTx(tx *sql.Tx) *LoginGuard {
+	return &LoginGuard{
+		throttleRepo:     g.throttleRepo.WithTx(tx),
+		maxFailures:      g.maxFailures,
+		maxFailuresPerIP: g.maxFailuresPerIP,
+		lockout:          g.lockout,
+	}
+}
+
+// Check returns a LoginLockedError if the username or IP has to wait before
+// the next attempt.
+func (g *LoginGuard) Check(username, ipAddress string) error {
+	var wait time.Duration
+	for _, t := range []struct {
+		scope models.LoginThrottleScope
+		key   string
+	}{
+		{models.LoginThrottleUsername, username},
+		{models.LoginThrottleIP, ipAddress},
+	} {
+		throttle, err := g.throttleRepo.Find(t.scope, t.key)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if throttle.LockedUntil != nil {
+			wait = max(wait, time.Until(*throttle.LockedUntil))
+		}
+	}
+
+	if wait > 0 {
+		// Round up so that clients do not retry a moment too early
+		return &LoginLockedError{RetryAfter: wait.Truncate(time.Second) + time.Second}
+	}
+	return nil
+}
+
+// Fail records a failed login.
+func (g *LoginGuard) Fail(username, ipAddress string) error {
+	if err := g.fail(models.LoginThrottleUsername, username, ipAddress, g.maxFailures); err != nil {
+		return err
+	}
+	return g.fail(models.LoginThrottleIP, ipAddress, ipAddress, g.maxFailuresPerIP)
+}
+
+func (g *LoginGuard) fail(scope models.LoginThrottleScope, key, ipAddress string, limit int) error {
+	now := time.Now()
+
+	throttle, err := g.throttleRepo.Find(scope, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		throttle = &models.LoginThrottle{Scope: scope, Key: key}
+	} else if err != nil {
+		return err
+	}
+
+	if now.Sub(throttle.LastFailedAt) > loginFailuresResetAfter {
+		throttle.Failures = 0
+	}
+	throttle.Failures++
+	throttle.LastFailedAt = now
+
+	locked := limit > 0 && throttle.Failures >= limit
+	var wait time.Duration
+	if locked {
+		wait = doubled(g.lockout, throttle.Failures-limit)
+	} else {
+		wait = doubled(time.Second, throttle.Failures-1)
+	}
+	lockedUntil := now.Add(wait)
+	throttle.LockedUntil = &lockedUntil
+
+	if err := g.throttleRepo.Save(throttle); err != nil {
+		return err
+	}
+
+	if !locked {
+		return nil
+	}
+	return g.throttleRepo.AddEvent(&models.LockoutEvent{
+		Scope:       scope,
+		Key:         key,
+		Event:       models.LockoutEventLocked,
+		Failures:    throttle.Failures,
+		IPAddress:   ipAddress,
+		LockedUntil: &lockedUntil,
+	})
+}
+
+// Succeed clears the failures of a username after a successful login. The
+// IP keeps its count, so a valid account cannot be used to reset it.
+func (g *LoginGuard) Succeed(username string) error {
+	_, err := g.throttleRepo.Delete(models.LoginThrottleUsername, username)
+	return err
+}
+
+// Unlock lets an admin lift the lockout of a username.
+func (g *LoginGuard) Unlock(username string, adminID int64) error {
+	throttle, err := g.throttleRepo.Find(models.LoginThrottleUsername, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := g.throttleRepo.Delete(models.LoginThrottleUsername, username); err != nil {
+		return err
+	}
+
+	return g.throttleRepo.AddEvent(&models.LockoutEvent{
+		Scope:    models.LoginThrottleUsername,
+		Key:      username,
+		Event:    models.LockoutEventUnlocked,
+		Failures: throttle.Failures,
+		UserID:   &adminID,
+	})
+}
+
+func (g *LoginGuard) Events(filter models.LockoutEventFilter) ([]models.LockoutEvent, error) {
+	return g.throttleRepo.FindEvents(filter)
+}
+
+// doubled returns d doubled n times, capped at maxLoginLockout.
+func doubled(d time.Duration, n int) time.Duration {
+	for i := 0; i < n && d < maxLoginLockout; i++ {
+		d *= 2
+	}
+	return min(d, maxLoginLockout)
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"zaiko/internal/models"
+)
+
+var (
+	ErrLotQuantityMismatch = errors.New("picked lot quantities must add up to the requested quantity")
+	ErrDuplicateLotPick    = errors.New("a lot may only be picked once per movement")
+)
+
+type LotNotFoundError struct {
+	LotNumber string
+}
+
+func (e *LotNotFoundError) Error() string {
+	return fmt.Sprintf("lot %q not found", e.LotNumber)
+}
+
+// receiveLot books quantity into a lot and attaches it to the transaction.
+func (s *StockService) receiveLot(tx *sql.Tx, transactionID, productID, warehouseID int64, lot models.LotRequest, quantity int) (*models.TransactionLot, error) {
+	lotRepo := s.lotRepo.WithTx(tx)
+
+	received, err := lotRepo.Receive(productID, warehouseID, lot, quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lotRepo.AddToTransaction(transactionID, received.ID, quantity); err != nil {
+		return nil, err
+	}
+
+	return &models.TransactionLot{LotID: received.ID, LotNumber: received.LotNumber, Quantity: quantity}, nil
+}
+
+// allocateLots takes an outgoing quantity from the lots of a stock row and
+// attaches the allocation to the transaction. Explicit picks are used when
+// given; otherwise unexpired lots are consumed first-expired-first-out, and
+// any remainder comes from stock that was received without a lot.
+//
+// The stock row must already have been decremented by quantity.
+func (s *StockService) allocateLots(tx *sql.Tx, transactionID, productID, warehouseID int64, quantity int, picks []models.LotPick) ([]models.TransactionLot, error) {
+	lotRepo := s.lotRepo.WithTx(tx)
+
+	var allocations []models.TransactionLot
+	if len(picks) > 0 {
+		total := 0
+		seen := make(map[string]bool, len(picks))
+		for _, pick := range picks {
+			if seen[pick.LotNumber] {
+				return nil, ErrDuplicateLotPick
+			}
+			seen[pick.LotNumber] = true
+			total += pick.Quantity
+		}
+		if total != quantity {
+			return nil, ErrLotQuantityMismatch
+		}
+
+		for _, pick := range picks {
+			lot, err := lotRepo.FindByNumber(productID, warehouseID, pick.LotNumber)
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, &LotNotFoundError{LotNumber: pick.LotNumber}
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			ok, err := lotRepo.Decrement(lot.ID, pick.Quantity)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, &InsufficientStockError{Available: lot.Quantity, Requested: pick.Quantity, LotNumber: lot.LotNumber}
+			}
+
+			allocations = append(allocations, models.TransactionLot{LotID: lot.ID, LotNumber: lot.LotNumber, Quantity: pick.Quantity})
+		}
+	} else {
+		stock, err := s.stockRepo.WithTx(tx).FindByProductAndWarehouse(productID, warehouseID)
+		if err != nil {
+			return nil, err
+		}
+		lotTotal, err := lotRepo.TotalQuantity(productID, warehouseID)
+		if err != nil {
+			return nil, err
+		}
+		unlotted := stock.Quantity + quantity - lotTotal
+		if unlotted < 0 {
+			unlotted = 0
+		}
+
+		lots, err := lotRepo.FindAllocatable(productID, warehouseID)
+		if err != nil {
+			return nil, err
+		}
+
+		remaining := quantity
+		for _, lot := range lots {
+			if remaining == 0 {
+				break
+			}
+
+			take := min(lot.Quantity, remaining)
+			ok, err := lotRepo.Decrement(lot.ID, take)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, &InsufficientStockError{Available: lot.Quantity, Requested: take, LotNumber: lot.LotNumber}
+			}
+
+			allocations = append(allocations, models.TransactionLot{LotID: lot.ID, LotNumber: lot.LotNumber, Quantity: take})
+			remaining -= take
+		}
+
+		// Expired lots are never allocated automatically
+		if remaining > unlotted {
+			return nil, &InsufficientStockError{Available: quantity - remaining + unlotted, Requested: quantity}
+		}
+	}
+
+	for _, allocation := range allocations {
+		if err := lotRepo.AddToTransaction(transactionID, allocation.LotID, allocation.Quantity); err != nil {
+			return nil, err
+		}
+	}
+
+	return allocations, nil
+}
+
+// moveLots books lots allocated at a transfer's source into the same lots at
+// its destination.
+func (s *StockService) moveLots(tx *sql.Tx, transactionID, productID, fromWarehouseID, toWarehouseID int64, allocations []models.TransactionLot) ([]models.TransactionLot, error) {
+	lotRepo := s.lotRepo.WithTx(tx)
+
+	var received []models.TransactionLot
+	for _, allocation := range allocations {
+		source, err := lotRepo.FindByNumber(productID, fromWarehouseID, allocation.LotNumber)
+		if err != nil {
+			return nil, err
+		}
+
+		lot := models.LotRequest{LotNumber: source.LotNumber}
+		if source.ManufacturedOn != nil {
+			lot.ManufacturedOn = *source.ManufacturedOn
+		}
+		if source.ExpiresOn != nil {
+			lot.ExpiresOn = *source.ExpiresOn
+		}
+
+		entry, err := s.receiveLot(tx, transactionID, productID, toWarehouseID, lot, allocation.Quantity)
+		if err != nil {
+			return nil, err
+		}
+		received = append(received, *entry)
+	}
+
+	return received, nil
+}
@@ -0,0 +1,304 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+	"zaiko/internal/repository"
+)
+
+var (
+	ErrPurchaseOrderNotFound = errors.New("purchase order not found")
+	ErrSupplierNotFound      = errors.New("supplier not found")
+	ErrWarehouseNotFound     = errors.New("warehouse not found")
+)
+
+// PurchaseOrderStatusError is returned when an action is not allowed in the
+// order's current status.
+type PurchaseOrderStatusError struct {
+	Status models.PurchaseOrderStatus
+}
+
+func (e *PurchaseOrderStatusError) Error() string {
+	return fmt.Sprintf("purchase order is %s", e.Status)
+}
+
+// OverReceiptError is returned when a receipt exceeds the quantity still
+// outstanding on a line.
+type OverReceiptError struct {
+	LineID      int64
+	Outstanding int
+	Requested   int
+}
+
+func (e *OverReceiptError) Error() string {
+	return fmt.Sprintf("line %d: outstanding %d, received %d", e.LineID, e.Outstanding, e.Requested)
+}
+
+// PurchaseOrderLineNotFoundError is returned when a receipt names a line
+// that does not belong to the order.
+type PurchaseOrderLineNotFoundError struct {
+	LineID int64
+}
+
+func (e *PurchaseOrderLineNotFoundError) Error() string {
+	return fmt.Sprintf("line %d is not part of the purchase order", e.LineID)
+}
+
+// PurchaseOrderService manages purchase orders. Receipts are booked as stock
+// in movements in the same database transaction as the line updates.
+type PurchaseOrderService struct {
+	db                *database.DB
+	purchaseOrderRepo *repository.PurchaseOrderRepository
+	supplierRepo      *repository.SupplierRepository
+	warehouseRepo     *repository.WarehouseRepository
+	productRepo       *repository.ProductRepository
+	stockService      *StockService
+}
+
+func NewPurchaseOrderService(db *database.DB, stockService *StockService) *PurchaseOrderService {
+	return &PurchaseOrderService{
+		db:                db,
+		purchaseOrderRepo: repository.NewPurchaseOrderRepository(db),
+		supplierRepo:      repository.NewSupplierRepository(db),
+		warehouseRepo:     repository.NewWarehouseRepository(db),
+		productRepo:       repository.NewProductRepository(db),
+		stockService:      stockService,
+	}
+}
+
+func (s *PurchaseOrderService) FindAll(filter models.PurchaseOrderFilter) ([]models.PurchaseOrder, error) {
+	return s.purchaseOrderRepo.FindAll(filter)
+}
+
+// Get returns an order with its lines.
+func (s *PurchaseOrderService) Get(id int64) (*models.PurchaseOrder, error) {
+	po, err := s.purchaseOrderRepo.FindByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPurchaseOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	po.Lines, err = s.purchaseOrderRepo.FindLines(id)
+	if err != nil {
+		return nil, err
+	}
+	if po.Lines == nil {
+		po.Lines = []models.PurchaseOrderLine{}
+	}
+
+	return po, nil
+}
+
+// Create saves a new order as a draft.
+func (s *PurchaseOrderService) Create(req models.CreatePurchaseOrderRequest, userID int64) (*models.PurchaseOrder, error) {
+	if _, err := s.supplierRepo.FindByID(req.SupplierID); errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSupplierNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if _, err := s.warehouseRepo.FindByID(req.WarehouseID); errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWarehouseNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	for _, line := range req.Lines {
+		if _, err := s.productRepo.FindByID(line.ProductID); errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrProductNotFound
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	var id int64
+	err := s.db.InTx(func(tx *sql.Tx) error {
+		var err error
+		id, err = s.purchaseOrderRepo.WithTx(tx).Create(req, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Get(id)
+}
+
+// Order places a draft order with the supplier. If no expected date was
+// given it is derived from the supplier's lead time.
+func (s *PurchaseOrderService) Order(id int64) (*models.PurchaseOrder, error) {
+	err := s.db.InTx(func(tx *sql.Tx) error {
+		poRepo := s.purchaseOrderRepo.WithTx(tx)
+
+		ok, err := poRepo.UpdateStatus(id, models.PurchaseOrderStatusOrdered, models.PurchaseOrderStatusDraft)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return s.statusError(poRepo, id)
+		}
+		return poRepo.SetExpectedDateFromLeadTime(id)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Get(id)
+}
+
+// Receive books delivered quantities against the order's lines. Each line
+// becomes a stock in movement to the order's warehouse. The order is closed
+// once nothing is outstanding, otherwise it is partially received.
+func (s *PurchaseOrderService) Receive(id int64, req models.ReceivePurchaseOrderRequest, userID int64) (*models.PurchaseOrder, error) {
+	err := s.db.InStockTx(func(tx *sql.Tx) error {
+		poRepo := s.purchaseOrderRepo.WithTx(tx)
+
+		ok, err := poRepo.UpdateStatus(id, models.PurchaseOrderStatusPartiallyReceived,
+			models.PurchaseOrderStatusOrdered, models.PurchaseOrderStatusPartiallyReceived)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return s.statusError(poRepo, id)
+		}
+
+		po, err := poRepo.FindByID(id)
+		if err != nil {
+			return err
+		}
+
+		for _, receipt := range req.Lines {
+			ok, err := poRepo.Receive(id, receipt.LineID, receipt.Quantity)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return s.receiptError(poRepo, id, receipt)
+			}
+
+			line, err := s.findLine(poRepo, id, receipt.LineID)
+			if err != nil {
+				return err
+			}
+
+			note := fmt.Sprintf("Purchase order #%d", id)
+			if req.Note != "" {
+				note += " " + req.Note
+			}
+
+			// A line without a cost is received at the default unit cost
+			transaction, err := s.stockService.stockIn(tx, models.StockMovementRequest{
+				ProductID:   line.ProductID,
+				WarehouseID: po.WarehouseID,
+				Quantity:    receipt.Quantity,
+				Note:        note,
+				UnitCost:    line.UnitCost,
+				Lot:         receipt.Lot,
+				Serials:     receipt.Serials,
+			}, userID)
+			if err != nil {
+				return err
+			}
+
+			if err := poRepo.AddReceipt(receipt.LineID, transaction.ID, receipt.Quantity); err != nil {
+				return err
+			}
+		}
+
+		outstanding, err := poRepo.OutstandingQuantity(id)
+		if err != nil {
+			return err
+		}
+		if outstanding == 0 {
+			_, err = poRepo.UpdateStatus(id, models.PurchaseOrderStatusClosed, models.PurchaseOrderStatusPartiallyReceived)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Get(id)
+}
+
+// Close ends an order early; quantities still outstanding will not be
+// received.
+func (s *PurchaseOrderService) Close(id int64) (*models.PurchaseOrder, error) {
+	err := s.db.InTx(func(tx *sql.Tx) error {
+		poRepo := s.purchaseOrderRepo.WithTx(tx)
+
+		ok, err := poRepo.UpdateStatus(id, models.PurchaseOrderStatusClosed,
+			models.PurchaseOrderStatusOrdered, models.PurchaseOrderStatusPartiallyReceived)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return s.statusError(poRepo, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Get(id)
+}
+
+// Delete removes a draft order. Orders that have been placed must be closed
+// instead.
+func (s *PurchaseOrderService) Delete(id int64) error {
+	return s.db.InTx(func(tx *sql.Tx) error {
+		poRepo := s.purchaseOrderRepo.WithTx(tx)
+
+		ok, err := poRepo.DeleteDraft(id)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return s.statusError(poRepo, id)
+		}
+		return nil
+	})
+}
+
+// statusError explains why a status change matched no order.
+func (s *PurchaseOrderService) statusError(poRepo *repository.PurchaseOrderRepository, id int64) error {
+	po, err := poRepo.FindByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrPurchaseOrderNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return &PurchaseOrderStatusError{Status: po.Status}
+}
+
+// receiptError explains why a receipt could not be booked on a line.
+func (s *PurchaseOrderService) receiptError(poRepo *repository.PurchaseOrderRepository, id int64, receipt models.ReceiptLineRequest) error {
+	line, err := s.findLine(poRepo, id, receipt.LineID)
+	if err != nil {
+		return err
+	}
+	return &OverReceiptError{
+		LineID:      line.ID,
+		Outstanding: line.OutstandingQuantity,
+		Requested:   receipt.Quantity,
+	}
+}
+
+func (s *PurchaseOrderService) findLine(poRepo *repository.PurchaseOrderRepository, id, lineID int64) (*models.PurchaseOrderLine, error) {
+	lines, err := poRepo.FindLines(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range lines {
+		if line.ID == lineID {
+			return &line, nil
+		}
+	}
+	return nil, &PurchaseOrderLineNotFoundError{LineID: lineID}
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"testing"
+
+	"zaiko/internal/database/dbtest"
+	"zaiko/internal/models"
+)
+
+func TestReceiveAtLineCost(t *testing.T) {
+	svc := newCostingService(t, models.CostingMovingAverage)
+	dbtest.Exec(t, svc.db, "INSERT INTO suppliers (name) VALUES ('Supplier')")
+	orders := NewPurchaseOrderService(svc.db, svc)
+
+	// The default unit cost is the average on hand
+	receive(t, svc, 10, cost(100))
+
+	po, err := orders.Create(models.CreatePurchaseOrderRequest{
+		SupplierID:  1,
+		WarehouseID: 1,
+		Lines: []models.PurchaseOrderLineRequest{
+			{ProductID: 1, Quantity: 5},
+			{ProductID: 1, Quantity: 5, UnitCost: cost(0)},
+		},
+	}, 1)
+	if err != nil {
+		t.Fatalf("create purchase order: %v", err)
+	}
+	if _, err := orders.Order(po.ID); err != nil {
+		t.Fatalf("order: %v", err)
+	}
+	if _, err := orders.Receive(po.ID, models.ReceivePurchaseOrderRequest{Lines: []models.ReceiptLineRequest{
+		{LineID: po.Lines[0].ID, Quantity: 5},
+		{LineID: po.Lines[1].ID, Quantity: 5},
+	}}, 1); err != nil {
+		t.Fatalf("receive: %v", err)
+	}
+
+	transactions, err := svc.transactionRepo.FindAll(models.TransactionFilter{Type: string(models.TransactionTypeIn)})
+	if err != nil {
+		t.Fatalf("find transactions: %v", err)
+	}
+	costs := map[int64]float64{}
+	for _, transaction := range transactions {
+		costs[transaction.ID] = transaction.UnitCost
+	}
+	// A line without a cost comes in at the default, a free line at zero
+	if costs[2] != 100 || costs[3] != 0 {
+		t.Errorf("unit costs = %v, want 100 for the line without a cost and 0 for the free line", costs)
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+	"zaiko/internal/repository"
+)
+
+var (
+	ErrReservationNotFound = errors.New("reservation not found")
+	ErrReservationReleased = errors.New("reservation is already released")
+	ErrReservationExpiry   = errors.New("expires_at must be in the future")
+)
+
+// ReservationService holds stock for owners without moving it.
+type ReservationService struct {
+	db              *database.DB
+	reservationRepo *repository.ReservationRepository
+	stockRepo       *repository.StockRepository
+}
+
+func NewReservationService(db *database.DB) *ReservationService {
+	return &ReservationService{
+		db:              db,
+		reservationRepo: repository.NewReservationRepository(db),
+		stockRepo:       repository.NewStockRepository(db),
+	}
+}
+
+func (s *ReservationService) FindAll(filter models.ReservationFilter) ([]models.Reservation, error) {
+	return s.reservationRepo.FindAll(filter)
+}
+
+func (s *ReservationService) Get(id int64) (*models.Reservation, error) {
+	reservation, err := s.reservationRepo.FindByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrReservationNotFound
+	}
+	return reservation, err
+}
+
+// Create reserves stock that is currently available, i.e. on hand and not
+// reserved by anyone else.
+func (s *ReservationService) Create(req models.CreateReservationRequest, userID int64) (*models.Reservation, error) {
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, ErrReservationExpiry
+	}
+
+	var id int64
+	err := s.db.InStockTx(func(tx *sql.Tx) error {
+		reservationRepo := s.reservationRepo.WithTx(tx)
+
+		// Insert first so the transaction holds the write lock while the
+		// availability is checked; the insert is rolled back if it fails.
+		var err error
+		id, err = reservationRepo.Create(req, userID)
+		if err != nil {
+			return err
+		}
+
+		stock, err := s.stockRepo.WithTx(tx).FindByProductAndWarehouse(req.ProductID, req.WarehouseID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrStockNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		reserved, err := reservationRepo.ReservedQuantity(req.ProductID, req.WarehouseID, "")
+		if err != nil {
+			return err
+		}
+		if reserved > stock.Quantity {
+			return &InsufficientStockError{
+				Available: max(stock.Quantity-reserved+req.Quantity, 0),
+				Requested: req.Quantity,
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Get(id)
+}
+
+// Release ends a reservation before it expires.
+func (s *ReservationService) Release(id int64) (*models.Reservation, error) {
+	ok, err := s.reservationRepo.Release(id)
+	if err != nil {
+		return nil, err
+	}
+
+	reservation, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrReservationReleased
+	}
+	return reservation, nil
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"zaiko/internal/models"
+)
+
+var (
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrAlreadyReversed     = errors.New("transaction has already been reversed")
+)
+
+// NotReversibleError is returned for a transaction that cannot be reversed
+// on its own.
+type NotReversibleError struct {
+	Reason string
+}
+
+func (e *NotReversibleError) Error() string {
+	return "transaction cannot be reversed: " + e.Reason
+}
+
+// Reverse posts a transaction that cancels a posted one: the stock, its
+// value, lots and serial numbers return to where they were before it, and
+// reserved stock that an issue used up is held for its owner again. A
+// receipt can only be reversed while its quantity is still on hand and not
+// reserved for anyone.
+func (s *StockService) Reverse(id int64, userID int64) (*models.Transaction, error) {
+	var reversal *models.Transaction
+	err := s.db.InStockTx(func(tx *sql.Tx) error {
+		transactionRepo := s.transactionRepo.WithTx(tx)
+
+		original, err := transactionRepo.FindByID(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTransactionNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.ensureReversible(tx, original); err != nil {
+			return err
+		}
+
+		change := original.SignedQuantity()
+		quantity := max(change, -change)
+		if change > 0 {
+			err = s.decrement(tx, original.ProductID, original.WarehouseID, quantity, "")
+		} else {
+			err = s.stockRepo.WithTx(tx).UpdateQuantity(original.ProductID, original.WarehouseID, quantity)
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.ensureUnlocked(tx, original.ProductID, original.WarehouseID); err != nil {
+			return err
+		}
+
+		reversal, err = transactionRepo.Create(
+			original.ProductID,
+			original.WarehouseID,
+			original.Type,
+			-original.Quantity,
+			fmt.Sprintf("Reversal of #%d", original.ID),
+			userID,
+		)
+		if err != nil {
+			return err
+		}
+		if err := transactionRepo.SetReversal(reversal.ID, original.ID); err != nil {
+			return err
+		}
+		reversal.ReversalOfID = &original.ID
+
+		// A reversed adjustment keeps its reason, so reports by reason net out
+		if original.ReasonID != nil {
+			if err := transactionRepo.SetReason(reversal.ID, *original.ReasonID); err != nil {
+				return err
+			}
+			reversal.ReasonID = original.ReasonID
+		}
+
+		if err := s.reverseCost(tx, original, reversal, change); err != nil {
+			return err
+		}
+		if err := s.reverseLots(tx, original, reversal, change); err != nil {
+			return err
+		}
+		if err := s.reverseSerials(tx, original, reversal, change); err != nil {
+			return err
+		}
+		return s.reservationRepo.WithTx(tx).Restore(original.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return reversal, nil
+}
+
+// ensureReversible fails for transactions that are reversed elsewhere or
+// not at all: transfers move stock back with a transfer, and order
+// receipts and shipments belong to their order lines.
+func (s *StockService) ensureReversible(tx *sql.Tx, original *models.Transaction) error {
+	if original.ReversedByID != nil {
+		return ErrAlreadyReversed
+	}
+	if original.ReversalOfID != nil {
+		return &NotReversibleError{Reason: "it is a reversal"}
+	}
+	if original.Type == models.TransactionTypeTransfer {
+		return &NotReversibleError{Reason: "transfers are undone by a transfer back"}
+	}
+
+	orderMovement, err := s.transactionRepo.WithTx(tx).IsOrderMovement(original.ID)
+	if err != nil {
+		return err
+	}
+	if orderMovement {
+		return &NotReversibleError{Reason: "it belongs to a purchase or sales order"}
+	}
+	return nil
+}
+
+// reverseCost returns the value the original moved. Issued stock comes back
+// at the cost it left with. A receipt leaves from its own cost layer first,
+// at the cost it came in with under moving average; under FIFO the layers
+// consumed decide the cost.
+func (s *StockService) reverseCost(tx *sql.Tx, original, reversal *models.Transaction, change int) error {
+	quantity := max(change, -change)
+	total := max(original.TotalCost, -original.TotalCost)
+
+	if change < 0 {
+		return s.receiveCost(tx, reversal, []costPortion{{quantity: quantity, unitCost: total / float64(quantity)}})
+	}
+
+	stock, err := s.stockRepo.WithTx(tx).FindByProductAndWarehouse(original.ProductID, original.WarehouseID)
+	if err != nil {
+		return err
+	}
+
+	portions, err := s.consumeLayers(tx, original.ProductID, original.WarehouseID, quantity, total/float64(quantity), original.ID)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case stock.Quantity == 0:
+		total = stock.Value
+	case s.costingMethod == models.CostingFIFO:
+		total = 0
+		for _, p := range portions {
+			total += float64(p.quantity) * p.unitCost
+		}
+		total = roundCost(total)
+	}
+	// Stock issued at a lower average since the receipt never goes negative
+	total = min(total, stock.Value)
+
+	if err := s.stockRepo.WithTx(tx).AddValue(original.ProductID, original.WarehouseID, -total); err != nil {
+		return err
+	}
+	return s.setCost(tx, reversal, quantity, total)
+}
+
+// reverseLots takes a receipt back out of its lots or puts an issue back
+// into the lots it came from. Stock received without a lot must still be
+// on hand outside the lots.
+func (s *StockService) reverseLots(tx *sql.Tx, original, reversal *models.Transaction, change int) error {
+	lotRepo := s.lotRepo.WithTx(tx)
+
+	lots, err := lotRepo.FindByTransaction(original.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, lot := range lots {
+		if change > 0 {
+			ok, err := lotRepo.Decrement(lot.LotID, lot.Quantity)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				current, err := lotRepo.FindByNumber(original.ProductID, original.WarehouseID, lot.LotNumber)
+				if err != nil {
+					return err
+				}
+				return &InsufficientStockError{Available: current.Quantity, Requested: lot.Quantity, LotNumber: lot.LotNumber}
+			}
+		} else if err := lotRepo.Restore(lot.LotID, lot.Quantity); err != nil {
+			return err
+		}
+
+		if err := lotRepo.AddToTransaction(reversal.ID, lot.LotID, lot.Quantity); err != nil {
+			return err
+		}
+	}
+	reversal.Lots = lots
+
+	if change < 0 {
+		return nil
+	}
+	stock, err := s.stockRepo.WithTx(tx).FindByProductAndWarehouse(original.ProductID, original.WarehouseID)
+	if err != nil {
+		return err
+	}
+	lotTotal, err := lotRepo.TotalQuantity(original.ProductID, original.WarehouseID)
+	if err != nil {
+		return err
+	}
+	if lotTotal > stock.Quantity {
+		return &InsufficientStockError{Available: max(stock.Quantity+change-lotTotal, 0), Requested: change}
+	}
+	return nil
+}
+
+// reverseSerials takes received units back out of stock or returns shipped
+// units to the warehouse they left.
+func (s *StockService) reverseSerials(tx *sql.Tx, original, reversal *models.Transaction, change int) error {
+	serialRepo := s.serialRepo.WithTx(tx)
+
+	serials, err := serialRepo.FindByTransaction(original.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, serial := range serials {
+		inStock := serial.Status == models.SerialStatusInStock
+		if change > 0 {
+			if !inStock || serial.WarehouseID == nil || *serial.WarehouseID != original.WarehouseID {
+				return &UnknownSerialError{Serial: serial.SerialNumber}
+			}
+			err = serialRepo.Move(serial.ID, nil, models.SerialStatusShipped)
+		} else {
+			if inStock {
+				return &DuplicateSerialError{Serial: serial.SerialNumber}
+			}
+			warehouseID := original.WarehouseID
+			err = serialRepo.Move(serial.ID, &warehouseID, models.SerialStatusInStock)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := serialRepo.AddToTransaction(reversal.ID, serial.ID); err != nil {
+			return err
+		}
+		reversal.Serials = append(reversal.Serials, serial.SerialNumber)
+	}
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"zaiko/internal/models"
+)
+
+func TestReverseReceipt(t *testing.T) {
+	svc := newCostingService(t, models.CostingMovingAverage)
+
+	receive(t, svc, 10, cost(10))
+	wrong := receive(t, svc, 100, cost(5))
+
+	reversal, err := svc.Reverse(wrong.ID, 1)
+	if err != nil {
+		t.Fatalf("reverse: %v", err)
+	}
+	if reversal.Type != models.TransactionTypeIn || reversal.Quantity != -100 || reversal.TotalCost != -500 {
+		t.Errorf("reversal = %s %d costing %v, want in -100 costing -500", reversal.Type, reversal.Quantity, reversal.TotalCost)
+	}
+	if reversal.ReversalOfID == nil || *reversal.ReversalOfID != wrong.ID {
+		t.Errorf("reversal of = %v, want %d", reversal.ReversalOfID, wrong.ID)
+	}
+
+	// The receipt leaves at the cost it came in with, not at the average
+	stock, err := svc.stockRepo.FindByProductAndWarehouse(1, 1)
+	if err != nil {
+		t.Fatalf("find stock: %v", err)
+	}
+	if stock.Quantity != 10 || stock.Value != 100 {
+		t.Errorf("stock = %d worth %v, want 10 worth 100", stock.Quantity, stock.Value)
+	}
+
+	transactions, err := svc.transactionRepo.FindAll(models.TransactionFilter{})
+	if err != nil {
+		t.Fatalf("find transactions: %v", err)
+	}
+	for _, tr := range transactions {
+		if tr.ID == wrong.ID && (tr.ReversedByID == nil || *tr.ReversedByID != reversal.ID) {
+			t.Errorf("original reversed by = %v, want %d", tr.ReversedByID, reversal.ID)
+		}
+	}
+
+	if _, err := svc.Reverse(wrong.ID, 1); !errors.Is(err, ErrAlreadyReversed) {
+		t.Errorf("reverse twice: err = %v, want ErrAlreadyReversed", err)
+	}
+	var notReversible *NotReversibleError
+	if _, err := svc.Reverse(reversal.ID, 1); !errors.As(err, &notReversible) {
+		t.Errorf("reverse a reversal: err = %v, want NotReversibleError", err)
+	}
+	if _, err := svc.Reverse(99, 1); !errors.Is(err, ErrTransactionNotFound) {
+		t.Errorf("reverse a missing transaction: err = %v, want ErrTransactionNotFound", err)
+	}
+}
+
+func TestReverseIssue(t *testing.T) {
+	svc := newCostingService(t, models.CostingMovingAverage)
+
+	receive(t, svc, 10, cost(10))
+	out, err := svc.StockOut(models.StockMovementRequest{ProductID: 1, WarehouseID: 1, Quantity: 4}, 1)
+	if err != nil {
+		t.Fatalf("stock out: %v", err)
+	}
+	receive(t, svc, 6, cost(20))
+
+	reversal, err := svc.Reverse(out.ID, 1)
+	if err != nil {
+		t.Fatalf("reverse: %v", err)
+	}
+	if reversal.Type != models.TransactionTypeOut || reversal.Quantity != -4 || reversal.SignedCost() != 40 {
+		t.Errorf("reversal = %s %d adding %v, want out -4 adding 40", reversal.Type, reversal.Quantity, reversal.SignedCost())
+	}
+
+	stock, err := svc.stockRepo.FindByProductAndWarehouse(1, 1)
+	if err != nil {
+		t.Fatalf("find stock: %v", err)
+	}
+	if stock.Quantity != 16 || stock.Value != 220 {
+		t.Errorf("stock = %d worth %v, want 16 worth 220", stock.Quantity, stock.Value)
+	}
+}
+
+func TestReverseIssueRestoresReservations(t *testing.T) {
+	svc := newCostingService(t, models.CostingMovingAverage)
+	reservations := NewReservationService(svc.db)
+
+	receive(t, svc, 10, cost(10))
+	reserve := func(ownerRef string, quantity int) *models.Reservation {
+		t.Helper()
+		reservation, err := reservations.Create(models.CreateReservationRequest{ProductID: 1, WarehouseID: 1, Quantity: quantity, OwnerRef: ownerRef}, 1)
+		if err != nil {
+			t.Fatalf("reserve: %v", err)
+		}
+		return reservation
+	}
+	first := reserve("SO-1", 3)
+	second := reserve("SO-1", 2)
+	other := reserve("SO-2", 3)
+
+	// Uses up the first reservation and one of the second
+	out, err := svc.StockOut(models.StockMovementRequest{ProductID: 1, WarehouseID: 1, Quantity: 4, OwnerRef: "SO-1"}, 1)
+	if err != nil {
+		t.Fatalf("stock out: %v", err)
+	}
+	// Takes the other owner's reservation, which is then released by hand
+	taken, err := svc.StockOut(models.StockMovementRequest{ProductID: 1, WarehouseID: 1, Quantity: 1, OwnerRef: "SO-2"}, 1)
+	if err != nil {
+		t.Fatalf("stock out: %v", err)
+	}
+	if _, err := reservations.Release(other.ID); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+
+	for _, id := range []int64{out.ID, taken.ID} {
+		if _, err := svc.Reverse(id, 1); err != nil {
+			t.Fatalf("reverse #%d: %v", id, err)
+		}
+	}
+
+	for _, tt := range []struct {
+		id       int64
+		quantity int
+		active   bool
+	}{
+		{first.ID, 3, true},
+		{second.ID, 2, true},
+		{other.ID, 3, false},
+	} {
+		reservation, err := reservations.Get(tt.id)
+		if err != nil {
+			t.Fatalf("get reservation: %v", err)
+		}
+		if reservation.Quantity != tt.quantity || reservation.Active != tt.active {
+			t.Errorf("reservation %d = %d active %v, want %d active %v",
+				tt.id, reservation.Quantity, reservation.Active, tt.quantity, tt.active)
+		}
+	}
+
+	// The restored reservations hold the returned stock for their owner
+	_, err = svc.StockOut(models.StockMovementRequest{ProductID: 1, WarehouseID: 1, Quantity: 6}, 1)
+	var reserved *ReservedStockError
+	if !errors.As(err, &reserved) || reserved.Reserved != 5 {
+		t.Errorf("stock out of reserved stock: err = %v, want ReservedStockError with 5 reserved", err)
+	}
+}
+
+func TestReverseRefusals(t *testing.T) {
+	svc := newCostingService(t, models.CostingFIFO)
+
+	received := receive(t, svc, 5, cost(10))
+	if _, err := svc.StockOut(models.StockMovementRequest{ProductID: 1, WarehouseID: 1, Quantity: 4}, 1); err != nil {
+		t.Fatalf("stock out: %v", err)
+	}
+
+	var insufficient *InsufficientStockError
+	if _, err := svc.Reverse(received.ID, 1); !errors.As(err, &insufficient) {
+		t.Errorf("reverse into negative stock: err = %v, want InsufficientStockError", err)
+	}
+
+	from, _, err := svc.Transfer(models.StockTransferRequest{ProductID: 1, FromWarehouseID: 1, ToWarehouseID: 2, Quantity: 1}, 1)
+	if err != nil {
+		t.Fatalf("transfer: %v", err)
+	}
+	var notReversible *NotReversibleError
+	if _, err := svc.Reverse(from.ID, 1); !errors.As(err, &notReversible) {
+		t.Errorf("reverse a transfer: err = %v, want NotReversibleError", err)
+	}
+
+	// Nothing was posted by the refused reversals
+	transactions, err := svc.transactionRepo.FindAll(models.TransactionFilter{})
+	if err != nil {
+		t.Fatalf("find transactions: %v", err)
+	}
+	if len(transactions) != 4 {
+		t.Errorf("transactions = %d, want 4", len(transactions))
+	}
+}
+
+func TestReverseFIFOReceiptUsesItsLayer(t *testing.T) {
+	svc := newCostingService(t, models.CostingFIFO)
+
+	receive(t, svc, 10, cost(10))
+	second := receive(t, svc, 10, cost(20))
+
+	if _, err := svc.Reverse(second.ID, 1); err != nil {
+		t.Fatalf("reverse: %v", err)
+	}
+
+	out, err := svc.StockOut(models.StockMovementRequest{ProductID: 1, WarehouseID: 1, Quantity: 10}, 1)
+	if err != nil {
+		t.Fatalf("stock out: %v", err)
+	}
+	if out.TotalCost != 100 {
+		t.Errorf("cost of the remaining stock = %v, want 100", out.TotalCost)
+	}
+}
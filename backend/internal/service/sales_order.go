@@ -0,0 +1,373 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+	"zaiko/internal/repository"
+)
+
+var (
+	ErrSalesOrderNotFound = errors.New("sales order not found")
+	ErrCustomerNotFound   = errors.New("customer not found")
+)
+
+// SalesOrderStatusError is returned when an action is not allowed in the
+// order's current status.
+type SalesOrderStatusError struct {
+	Status models.SalesOrderStatus
+}
+
+func (e *SalesOrderStatusError) Error() string {
+	return fmt.Sprintf("sales order is %s", e.Status)
+}
+
+// OverShipmentError is returned when a shipment exceeds the quantity
+// allocated and not yet shipped on a line.
+type OverShipmentError struct {
+	LineID    int64
+	Shippable int
+	Requested int
+}
+
+func (e *OverShipmentError) Error() string {
+	return fmt.Sprintf("line %d: shippable %d, requested %d", e.LineID, e.Shippable, e.Requested)
+}
+
+// SalesOrderLineNotFoundError is returned when a shipment names a line that
+// does not belong to the order.
+type SalesOrderLineNotFoundError struct {
+	LineID int64
+}
+
+func (e *SalesOrderLineNotFoundError) Error() string {
+	return fmt.Sprintf("line %d is not part of the sales order", e.LineID)
+}
+
+// SalesOrderService manages sales orders. Confirming an order allocates
+// stock to its lines; whatever cannot be allocated stays backordered until
+// it is allocated again. Shipments are booked as stock out movements.
+type SalesOrderService struct {
+	db              *database.DB
+	salesOrderRepo  *repository.SalesOrderRepository
+	customerRepo    *repository.CustomerRepository
+	warehouseRepo   *repository.WarehouseRepository
+	productRepo     *repository.ProductRepository
+	stockRepo       *repository.StockRepository
+	reservationRepo *repository.ReservationRepository
+	stockService    *StockService
+}
+
+func NewSalesOrderService(db *database.DB, stockService *StockService) *SalesOrderService {
+	return &SalesOrderService{
+		db:              db,
+		salesOrderRepo:  repository.NewSalesOrderRepository(db),
+		customerRepo:    repository.NewCustomerRepository(db),
+		warehouseRepo:   repository.NewWarehouseRepository(db),
+		productRepo:     repository.NewProductRepository(db),
+		stockRepo:       repository.NewStockRepository(db),
+		reservationRepo: repository.NewReservationRepository(db),
+		stockService:    stockService,
+	}
+}
+
+func (s *SalesOrderService) FindAll(filter models.SalesOrderFilter) ([]models.SalesOrder, error) {
+	return s.salesOrderRepo.FindAll(filter)
+}
+
+// Get returns an order with its lines.
+func (s *SalesOrderService) Get(id int64) (*models.SalesOrder, error) {
+	so, err := s.salesOrderRepo.FindByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSalesOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	so.Lines, err = s.salesOrderRepo.FindLines(id)
+	if err != nil {
+		return nil, err
+	}
+	if so.Lines == nil {
+		so.Lines = []models.SalesOrderLine{}
+	}
+
+	return so, nil
+}
+
+// Create saves a new order as a draft.
+func (s *SalesOrderService) Create(req models.CreateSalesOrderRequest, userID int64) (*models.SalesOrder, error) {
+	if _, err := s.customerRepo.FindByID(req.CustomerID); errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCustomerNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if _, err := s.warehouseRepo.FindByID(req.WarehouseID); errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWarehouseNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	for _, line := range req.Lines {
+		if _, err := s.productRepo.FindByID(line.ProductID); errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrProductNotFound
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	var id int64
+	err := s.db.InTx(func(tx *sql.Tx) error {
+		var err error
+		id, err = s.salesOrderRepo.WithTx(tx).Create(req, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Get(id)
+}
+
+// Confirm confirms a draft order and allocates as much stock to its lines
+// as is available.
+func (s *SalesOrderService) Confirm(id int64) (*models.SalesOrder, error) {
+	err := s.db.InStockTx(func(tx *sql.Tx) error {
+		soRepo := s.salesOrderRepo.WithTx(tx)
+
+		ok, err := soRepo.UpdateStatus(id, models.SalesOrderStatusConfirmed, models.SalesOrderStatusDraft)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return s.statusError(soRepo, id)
+		}
+		return s.allocate(tx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Get(id)
+}
+
+// Allocate retries allocation of backordered quantities, for example after
+// new stock has been received.
+func (s *SalesOrderService) Allocate(id int64) (*models.SalesOrder, error) {
+	err := s.db.InStockTx(func(tx *sql.Tx) error {
+		soRepo := s.salesOrderRepo.WithTx(tx)
+
+		ok, err := soRepo.HasStatus(id, models.SalesOrderStatusConfirmed, models.SalesOrderStatusPartiallyShipped)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return s.statusError(soRepo, id)
+		}
+		return s.allocate(tx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Get(id)
+}
+
+// allocate allocates stock that is not reserved or held by other orders to
+// the backordered part of each line.
+func (s *SalesOrderService) allocate(tx *sql.Tx, id int64) error {
+	soRepo := s.salesOrderRepo.WithTx(tx)
+
+	so, err := soRepo.FindByID(id)
+	if err != nil {
+		return err
+	}
+
+	lines, err := soRepo.FindLines(id)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		if line.BackorderedQuantity == 0 {
+			continue
+		}
+
+		onHand := 0
+		stock, err := s.stockRepo.WithTx(tx).FindByProductAndWarehouse(line.ProductID, so.WarehouseID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if stock != nil {
+			onHand = stock.Quantity
+		}
+
+		reserved, err := s.reservationRepo.WithTx(tx).ReservedQuantity(line.ProductID, so.WarehouseID, "")
+		if err != nil {
+			return err
+		}
+
+		quantity := min(onHand-reserved, line.BackorderedQuantity)
+		if quantity <= 0 {
+			continue
+		}
+		if err := soRepo.Allocate(line.ID, quantity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ship ships allocated quantities of the order's lines from the order's
+// warehouse. The order is shipped once every line has shipped in full,
+// otherwise it is partially shipped.
+func (s *SalesOrderService) Ship(id int64, req models.ShipSalesOrderRequest, userID int64) (*models.SalesOrder, error) {
+	err := s.db.InStockTx(func(tx *sql.Tx) error {
+		soRepo := s.salesOrderRepo.WithTx(tx)
+
+		ok, err := soRepo.UpdateStatus(id, models.SalesOrderStatusPartiallyShipped,
+			models.SalesOrderStatusConfirmed, models.SalesOrderStatusPartiallyShipped)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return s.statusError(soRepo, id)
+		}
+
+		so, err := soRepo.FindByID(id)
+		if err != nil {
+			return err
+		}
+
+		for _, shipment := range req.Lines {
+			ok, err := soRepo.Ship(id, shipment.LineID, shipment.Quantity)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return s.shipmentError(soRepo, id, shipment)
+			}
+
+			line, err := s.findLine(soRepo, id, shipment.LineID)
+			if err != nil {
+				return err
+			}
+
+			note := fmt.Sprintf("Sales order #%d", id)
+			if req.Note != "" {
+				note += " " + req.Note
+			}
+
+			transaction, err := s.stockService.stockOut(tx, models.StockMovementRequest{
+				ProductID:   line.ProductID,
+				WarehouseID: so.WarehouseID,
+				Quantity:    shipment.Quantity,
+				Note:        note,
+				Lots:        shipment.Lots,
+				Serials:     shipment.Serials,
+				OwnerRef:    models.SalesOrderOwnerRef(id),
+			}, userID)
+			if err != nil {
+				return err
+			}
+
+			if err := soRepo.AddShipment(shipment.LineID, transaction.ID, shipment.Quantity); err != nil {
+				return err
+			}
+		}
+
+		unshipped, err := soRepo.UnshippedQuantity(id)
+		if err != nil {
+			return err
+		}
+		if unshipped == 0 {
+			_, err = soRepo.UpdateStatus(id, models.SalesOrderStatusShipped, models.SalesOrderStatusPartiallyShipped)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Get(id)
+}
+
+// Cancel cancels an order that has not shipped in full. Its allocated stock
+// is released; shipments already made are kept.
+func (s *SalesOrderService) Cancel(id int64) (*models.SalesOrder, error) {
+	err := s.db.InTx(func(tx *sql.Tx) error {
+		soRepo := s.salesOrderRepo.WithTx(tx)
+
+		ok, err := soRepo.UpdateStatus(id, models.SalesOrderStatusCancelled,
+			models.SalesOrderStatusDraft, models.SalesOrderStatusConfirmed, models.SalesOrderStatusPartiallyShipped)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return s.statusError(soRepo, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Get(id)
+}
+
+// Delete removes a draft order.
+func (s *SalesOrderService) Delete(id int64) error {
+	return s.db.InTx(func(tx *sql.Tx) error {
+		soRepo := s.salesOrderRepo.WithTx(tx)
+
+		ok, err := soRepo.DeleteDraft(id)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return s.statusError(soRepo, id)
+		}
+		return nil
+	})
+}
+
+// statusError explains why a status change matched no order.
+func (s *SalesOrderService) statusError(soRepo *repository.SalesOrderRepository, id int64) error {
+	so, err := soRepo.FindByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrSalesOrderNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return &SalesOrderStatusError{Status: so.Status}
+}
+
+// shipmentError explains why a shipment could not be booked on a line.
+func (s *SalesOrderService) shipmentError(soRepo *repository.SalesOrderRepository, id int64, shipment models.ShipmentLineRequest) error {
+	line, err := s.findLine(soRepo, id, shipment.LineID)
+	if err != nil {
+		return err
+	}
+	return &OverShipmentError{
+		LineID:    line.ID,
+		Shippable: line.AllocatedQuantity - line.ShippedQuantity,
+		Requested: shipment.Quantity,
+	}
+}
+
+func (s *SalesOrderService) findLine(soRepo *repository.SalesOrderRepository, id, lineID int64) (*models.SalesOrderLine, error) {
+	lines, err := soRepo.FindLines(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range lines {
+		if line.ID == lineID {
+			return &line, nil
+		}
+	}
+	return nil, &SalesOrderLineNotFoundError{LineID: lineID}
+}
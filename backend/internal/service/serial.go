@@ -0,0 +1,130 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"zaiko/internal/models"
+)
+
+var (
+	ErrProductNotFound     = errors.New("product not found")
+	ErrSerialCountMismatch = errors.New("the number of serials must equal the quantity")
+	ErrSerialsNotAllowed   = errors.New("serials can only be given for serialized products")
+)
+
+// DuplicateSerialError is returned when a serial number appears twice in a
+// movement or is received while it is already in stock.
+type DuplicateSerialError struct {
+	Serial string
+}
+
+func (e *DuplicateSerialError) Error() string {
+	return fmt.Sprintf("duplicate serial number %q", e.Serial)
+}
+
+// UnknownSerialError is returned when a serial number to be moved is not in
+// stock at the source warehouse.
+type UnknownSerialError struct {
+	Serial string
+}
+
+func (e *UnknownSerialError) Error() string {
+	return fmt.Sprintf("serial number %q is not in stock at this warehouse", e.Serial)
+}
+
+// checkSerials validates the serial list of a movement against the product
+// and reports whether the product is serialized.
+func (s *StockService) checkSerials(tx *sql.Tx, productID int64, quantity int, serials []string) (bool, error) {
+	product, err := s.productRepo.WithTx(tx).FindByID(productID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrProductNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if !product.Serialized {
+		if len(serials) > 0 {
+			return false, ErrSerialsNotAllowed
+		}
+		return false, nil
+	}
+
+	if len(serials) != quantity {
+		return false, ErrSerialCountMismatch
+	}
+
+	seen := make(map[string]bool, len(serials))
+	for _, serial := range serials {
+		if seen[serial] {
+			return false, &DuplicateSerialError{Serial: serial}
+		}
+		seen[serial] = true
+	}
+
+	return true, nil
+}
+
+// receiveSerials puts serial numbers into stock and attaches them to the
+// transaction. A serial that was shipped earlier may be received again.
+func (s *StockService) receiveSerials(tx *sql.Tx, transactionID, productID, warehouseID int64, serials []string) error {
+	serialRepo := s.serialRepo.WithTx(tx)
+
+	for _, serial := range serials {
+		existing, err := serialRepo.FindByNumber(productID, serial)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if existing != nil && existing.Status == models.SerialStatusInStock {
+			return &DuplicateSerialError{Serial: serial}
+		}
+
+		id, err := serialRepo.Receive(productID, warehouseID, serial)
+		if err != nil {
+			return err
+		}
+		if err := serialRepo.AddToTransaction(transactionID, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// releaseSerials takes serial numbers out of a warehouse and attaches them to
+// the given transactions. With a destination the units stay in stock there,
+// otherwise they are marked as shipped.
+func (s *StockService) releaseSerials(tx *sql.Tx, productID, warehouseID int64, destination *int64, serials []string, transactionIDs ...int64) error {
+	serialRepo := s.serialRepo.WithTx(tx)
+
+	status := models.SerialStatusShipped
+	if destination != nil {
+		status = models.SerialStatusInStock
+	}
+
+	for _, serial := range serials {
+		existing, err := serialRepo.FindByNumber(productID, serial)
+		if errors.Is(err, sql.ErrNoRows) {
+			return &UnknownSerialError{Serial: serial}
+		}
+		if err != nil {
+			return err
+		}
+		if existing.Status != models.SerialStatusInStock || existing.WarehouseID == nil || *existing.WarehouseID != warehouseID {
+			return &UnknownSerialError{Serial: serial}
+		}
+
+		if err := serialRepo.Move(existing.ID, destination, status); err != nil {
+			return err
+		}
+		for _, transactionID := range transactionIDs {
+			if err := serialRepo.AddToTransaction(transactionID, existing.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"zaiko/internal/database"
+	"zaiko/internal/middleware"
+	"zaiko/internal/models"
+	"zaiko/internal/repository"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrAccountDisabled     = errors.New("account is disabled")
+)
+
+// SessionService issues access and refresh tokens. Refresh tokens are only
+// stored as hashes and rotate on every use; presenting a used refresh token
+// again revokes the whole session, since it has probably been stolen.
+type SessionService struct {
+	sessionRepo *repository.SessionRepository
+	userRepo    *repository.UserRepository
+	accessTTL   time.Duration
+	refreshTTL  time.Duration
+}
+
+func NewSessionService(db *database.DB, accessTTL, refreshTTL time.Duration) *SessionService {
+	return &SessionService{
+		sessionRepo: repository.NewSessionRepository(db),
+		userRepo:    repository.NewUserRepository(db),
+		accessTTL:   accessTTL,
+		refreshTTL:  refreshTTL,
+	}
+}
+
+// WithTx returns a copy of the service that runs its queries in tx.
+func (s *SessionService) WithTx(tx *sql.Tx) *SessionService {
+	return &SessionService{
+		sessionRepo: s.sessionRepo.WithTx(tx),
+		userRepo:    s.userRepo.WithTx(tx),
+		accessTTL:   s.accessTTL,
+		refreshTTL:  s.refreshTTL,
+	}
+}
+
+// Start opens a new session for a user who has just authenticated.
+// twoFactor records whether a two-factor code was part of it.
+func (s *SessionService) Start(user *models.User, userAgent, ipAddress string, twoFactor bool) (*models.LoginResponse, error) {
+	refreshToken, refreshHash, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, err := s.sessionRepo.Create(user.ID, userAgent, ipAddress, twoFactor, time.Now().Add(s.refreshTTL), refreshHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.respond(user, sessionID, refreshToken)
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token.
+func (s *SessionService) Refresh(refreshToken string) (*models.LoginResponse, error) {
+	oldHash := hashToken(refreshToken)
+
+	sessionID, used, err := s.sessionRepo.FindRefreshToken(oldHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if used {
+		return nil, s.revokeReused(sessionID)
+	}
+
+	session, err := s.sessionRepo.FindByID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if !session.Active() {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.userRepo.FindByID(session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled {
+		if err := s.sessionRepo.Revoke(sessionID); err != nil {
+			return nil, err
+		}
+		return nil, ErrAccountDisabled
+	}
+
+	nextToken, nextHash, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := s.sessionRepo.Rotate(sessionID, oldHash, nextHash)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// Used by a concurrent request in the meantime
+		return nil, s.revokeReused(sessionID)
+	}
+
+	return s.respond(user, sessionID, nextToken)
+}
+
+// ConfirmTwoFactor records that a two-factor code was confirmed in the
+// session, as if it had been part of the login.
+func (s *SessionService) ConfirmTwoFactor(sessionID int64) error {
+	return s.sessionRepo.SetTwoFactor(sessionID)
+}
+
+// Revoke ends a single session.
+func (s *SessionService) Revoke(sessionID int64) error {
+	return s.sessionRepo.Revoke(sessionID)
+}
+
+// RevokeAll ends every session of a user and returns how many were ended.
+func (s *SessionService) RevokeAll(userID int64) (int64, error) {
+	return s.sessionRepo.RevokeAllForUser(userID)
+}
+
+func (s *SessionService) revokeReused(sessionID int64) error {
+	if err := s.sessionRepo.Revoke(sessionID); err != nil {
+		return err
+	}
+	return ErrInvalidRefreshToken
+}
+
+func (s *SessionService) respond(user *models.User, sessionID int64, refreshToken string) (*models.LoginResponse, error) {
+	token, err := middleware.GenerateToken(user, sessionID, s.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.LoginResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(s.accessTTL.Seconds()),
+		User:         *user,
+	}, nil
+}
+
+// newToken returns a random token and the hash under which it is stored.
+func newToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+// hashToken hashes a random token or recovery code for storage. They carry
+// at least 80 bits of entropy, so a fast hash is sufficient.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
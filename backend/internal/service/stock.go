@@ -0,0 +1,389 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+	"zaiko/internal/repository"
+)
+
+var (
+	ErrStockNotFound = errors.New("stock record not found")
+	ErrStockLocked   = errors.New("stock is locked by an open inventory count")
+)
+
+// ReservedStockError is returned when a movement would consume stock that
+// is reserved for someone else.
+type ReservedStockError struct {
+	Available int
+	Reserved  int
+	Requested int
+}
+
+func (e *ReservedStockError) Error() string {
+	return fmt.Sprintf("stock is reserved: available %d, reserved %d, requested %d", e.Available, e.Reserved, e.Requested)
+}
+
+type InsufficientStockError struct {
+	Available int
+	Requested int
+	// LotNumber is set when a single lot ran short
+	LotNumber string
+}
+
+func (e *InsufficientStockError) Error() string {
+	if e.LotNumber != "" {
+		return fmt.Sprintf("insufficient stock in lot %q: available %d, requested %d", e.LotNumber, e.Available, e.Requested)
+	}
+	return fmt.Sprintf("insufficient stock: available %d, requested %d", e.Available, e.Requested)
+}
+
+// StockService performs stock movements. Every movement updates the stock
+// table, writes its ledger rows and costs the moved quantity with the
+// costing method in one database transaction.
+type StockService struct {
+	db              *database.DB
+	costingMethod   models.CostingMethod
+	stockRepo       *repository.StockRepository
+	transactionRepo *repository.TransactionRepository
+	countRepo       *repository.CountRepository
+	lotRepo         *repository.LotRepository
+	productRepo     *repository.ProductRepository
+	serialRepo      *repository.SerialRepository
+	reservationRepo *repository.ReservationRepository
+	costRepo        *repository.CostRepository
+	reasonRepo      *repository.AdjustmentReasonRepository
+}
+
+func NewStockService(db *database.DB, costingMethod models.CostingMethod) *StockService {
+	return &StockService{
+		db:              db,
+		costingMethod:   costingMethod,
+		stockRepo:       repository.NewStockRepository(db),
+		transactionRepo: repository.NewTransactionRepository(db),
+		countRepo:       repository.NewCountRepository(db),
+		lotRepo:         repository.NewLotRepository(db),
+		productRepo:     repository.NewProductRepository(db),
+		serialRepo:      repository.NewSerialRepository(db),
+		reservationRepo: repository.NewReservationRepository(db),
+		costRepo:        repository.NewCostRepository(db),
+		reasonRepo:      repository.NewAdjustmentReasonRepository(db),
+	}
+}
+
+func (s *StockService) StockIn(req models.StockMovementRequest, userID int64) (*models.Transaction, error) {
+	var transaction *models.Transaction
+	err := s.db.InStockTx(func(tx *sql.Tx) error {
+		var err error
+		transaction, err = s.stockIn(tx, req, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
+
+func (s *StockService) StockOut(req models.StockMovementRequest, userID int64) (*models.Transaction, error) {
+	var transaction *models.Transaction
+	err := s.db.InStockTx(func(tx *sql.Tx) error {
+		var err error
+		transaction, err = s.stockOut(tx, req, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
+
+// Transfer moves stock between two warehouses and records a linked pair of
+// transfer transactions.
+func (s *StockService) Transfer(req models.StockTransferRequest, userID int64) (from, to *models.Transaction, err error) {
+	err = s.db.InStockTx(func(tx *sql.Tx) error {
+		var err error
+		from, to, err = s.transfer(tx, req, userID)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return from, to, nil
+}
+
+// stockIn receives stock as part of the caller's transaction.
+func (s *StockService) stockIn(tx *sql.Tx, req models.StockMovementRequest, userID int64) (*models.Transaction, error) {
+	return s.receive(tx, models.TransactionTypeIn, req, userID)
+}
+
+// receive adds stock and records it as a transaction of txType.
+func (s *StockService) receive(tx *sql.Tx, txType models.TransactionType, req models.StockMovementRequest, userID int64) (*models.Transaction, error) {
+	if err := s.stockRepo.WithTx(tx).UpdateQuantity(req.ProductID, req.WarehouseID, req.Quantity); err != nil {
+		return nil, err
+	}
+	if err := s.ensureUnlocked(tx, req.ProductID, req.WarehouseID); err != nil {
+		return nil, err
+	}
+
+	transaction, err := s.transactionRepo.WithTx(tx).Create(
+		req.ProductID,
+		req.WarehouseID,
+		txType,
+		req.Quantity,
+		req.Note,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var unitCost float64
+	if req.UnitCost != nil {
+		unitCost = *req.UnitCost
+	} else if unitCost, err = s.defaultUnitCost(tx, req.ProductID, req.WarehouseID, req.Quantity); err != nil {
+		return nil, err
+	}
+	if err := s.receiveCost(tx, transaction, []costPortion{{quantity: req.Quantity, unitCost: unitCost}}); err != nil {
+		return nil, err
+	}
+
+	if req.Lot != nil {
+		lot, err := s.receiveLot(tx, transaction.ID, req.ProductID, req.WarehouseID, *req.Lot, req.Quantity)
+		if err != nil {
+			return nil, err
+		}
+		transaction.Lots = []models.TransactionLot{*lot}
+	}
+
+	serialized, err := s.checkSerials(tx, req.ProductID, req.Quantity, req.Serials)
+	if err != nil {
+		return nil, err
+	}
+	if serialized {
+		if err := s.receiveSerials(tx, transaction.ID, req.ProductID, req.WarehouseID, req.Serials); err != nil {
+			return nil, err
+		}
+		transaction.Serials = req.Serials
+	}
+
+	return transaction, nil
+}
+
+// stockOut ships stock as part of the caller's transaction.
+func (s *StockService) stockOut(tx *sql.Tx, req models.StockMovementRequest, userID int64) (*models.Transaction, error) {
+	return s.issue(tx, models.TransactionTypeOut, req, userID)
+}
+
+// issue removes stock and records it as a transaction of txType. Only "out"
+// rows store the quantity as positive; the other types get it negated.
+func (s *StockService) issue(tx *sql.Tx, txType models.TransactionType, req models.StockMovementRequest, userID int64) (*models.Transaction, error) {
+	if err := s.decrement(tx, req.ProductID, req.WarehouseID, req.Quantity, req.OwnerRef); err != nil {
+		return nil, err
+	}
+	if err := s.ensureUnlocked(tx, req.ProductID, req.WarehouseID); err != nil {
+		return nil, err
+	}
+
+	quantity := req.Quantity
+	if txType != models.TransactionTypeOut {
+		quantity = -quantity
+	}
+
+	transaction, err := s.transactionRepo.WithTx(tx).Create(
+		req.ProductID,
+		req.WarehouseID,
+		txType,
+		quantity,
+		req.Note,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.issueCost(tx, transaction, req.Quantity); err != nil {
+		return nil, err
+	}
+
+	transaction.Lots, err = s.allocateLots(tx, transaction.ID, req.ProductID, req.WarehouseID, req.Quantity, req.Lots)
+	if err != nil {
+		return nil, err
+	}
+
+	serialized, err := s.checkSerials(tx, req.ProductID, req.Quantity, req.Serials)
+	if err != nil {
+		return nil, err
+	}
+	if serialized {
+		if err := s.releaseSerials(tx, req.ProductID, req.WarehouseID, nil, req.Serials, transaction.ID); err != nil {
+			return nil, err
+		}
+		transaction.Serials = req.Serials
+	}
+
+	if req.OwnerRef != "" {
+		if err := s.reservationRepo.WithTx(tx).Consume(transaction.ID, req.OwnerRef, req.ProductID, req.WarehouseID, req.Quantity); err != nil {
+			return nil, err
+		}
+	}
+
+	return transaction, nil
+}
+
+func (s *StockService) transfer(tx *sql.Tx, req models.StockTransferRequest, userID int64) (from, to *models.Transaction, err error) {
+	transactionRepo := s.transactionRepo.WithTx(tx)
+
+	if err := s.decrement(tx, req.ProductID, req.FromWarehouseID, req.Quantity, ""); err != nil {
+		return nil, nil, err
+	}
+	if err := s.stockRepo.WithTx(tx).UpdateQuantity(req.ProductID, req.ToWarehouseID, req.Quantity); err != nil {
+		return nil, nil, err
+	}
+	if err := s.ensureUnlocked(tx, req.ProductID, req.FromWarehouseID); err != nil {
+		return nil, nil, err
+	}
+	if err := s.ensureUnlocked(tx, req.ProductID, req.ToWarehouseID); err != nil {
+		return nil, nil, err
+	}
+
+	from, err = transactionRepo.Create(
+		req.ProductID,
+		req.FromWarehouseID,
+		models.TransactionTypeTransfer,
+		-req.Quantity,
+		req.Note,
+		userID,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	to, err = transactionRepo.Create(
+		req.ProductID,
+		req.ToWarehouseID,
+		models.TransactionTypeTransfer,
+		req.Quantity,
+		req.Note,
+		userID,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := transactionRepo.Link(from.ID, to.ID); err != nil {
+		return nil, nil, err
+	}
+	from.RelatedTransactionID = &to.ID
+	to.RelatedTransactionID = &from.ID
+
+	// The stock arrives at the cost it left with
+	portions, err := s.issueCost(tx, from, req.Quantity)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.receiveCost(tx, to, portions); err != nil {
+		return nil, nil, err
+	}
+
+	from.Lots, err = s.allocateLots(tx, from.ID, req.ProductID, req.FromWarehouseID, req.Quantity, req.Lots)
+	if err != nil {
+		return nil, nil, err
+	}
+	to.Lots, err = s.moveLots(tx, to.ID, req.ProductID, req.FromWarehouseID, req.ToWarehouseID, from.Lots)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serialized, err := s.checkSerials(tx, req.ProductID, req.Quantity, req.Serials)
+	if err != nil {
+		return nil, nil, err
+	}
+	if serialized {
+		destination := req.ToWarehouseID
+		if err := s.releaseSerials(tx, req.ProductID, req.FromWarehouseID, &destination, req.Serials, from.ID, to.ID); err != nil {
+			return nil, nil, err
+		}
+		from.Serials = req.Serials
+		to.Serials = req.Serials
+	}
+
+	return from, to, nil
+}
+
+// postAdjustment records a change of delta to the quantity on hand as an
+// adjustment transaction: a gain is received like a stock-in and a loss is
+// issued like a stock-out, so lots, serial numbers, reservations and count
+// locks are checked the same way. The quantity of move is ignored. It runs
+// as part of the caller's transaction.
+func (s *StockService) postAdjustment(tx *sql.Tx, move models.StockMovementRequest, delta int, userID int64) (*models.Transaction, error) {
+	if delta > 0 {
+		move.Quantity = delta
+		return s.receive(tx, models.TransactionTypeAdjustment, move, userID)
+	}
+	move.Quantity = -delta
+	return s.issue(tx, models.TransactionTypeAdjustment, move, userID)
+}
+
+// decrement removes quantity from a stock row with a conditional update, so
+// concurrent movements can never drive the quantity below zero. Stock
+// reserved for anyone but ownerRef must remain after the decrement.
+func (s *StockService) decrement(tx *sql.Tx, productID, warehouseID int64, quantity int, ownerRef string) error {
+	stockRepo := s.stockRepo.WithTx(tx)
+
+	ok, err := stockRepo.Decrement(productID, warehouseID, quantity)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return s.ensureUnreserved(tx, productID, warehouseID, quantity, ownerRef)
+	}
+
+	stock, err := stockRepo.FindByProductAndWarehouse(productID, warehouseID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrStockNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return &InsufficientStockError{Available: stock.Quantity, Requested: quantity}
+}
+
+// ensureUnreserved fails if a decrement of quantity left less stock than is
+// reserved for owners other than ownerRef.
+func (s *StockService) ensureUnreserved(tx *sql.Tx, productID, warehouseID int64, quantity int, ownerRef string) error {
+	stock, err := s.stockRepo.WithTx(tx).FindByProductAndWarehouse(productID, warehouseID)
+	if err != nil {
+		return err
+	}
+
+	reserved, err := s.reservationRepo.WithTx(tx).ReservedQuantity(productID, warehouseID, ownerRef)
+	if err != nil {
+		return err
+	}
+	if stock.Quantity >= reserved {
+		return nil
+	}
+
+	return &ReservedStockError{
+		Available: max(stock.Quantity+quantity-reserved, 0),
+		Reserved:  reserved,
+		Requested: quantity,
+	}
+}
+
+// ensureUnlocked fails if an open inventory count covers the stock row.
+// Callers write first and check afterwards: the transaction then already
+// holds SQLite's write lock, and the write is rolled back if the row is locked.
+func (s *StockService) ensureUnlocked(tx *sql.Tx, productID, warehouseID int64) error {
+	locked, err := s.countRepo.WithTx(tx).IsLocked(productID, warehouseID)
+	if err != nil {
+		return err
+	}
+	if locked {
+		return ErrStockLocked
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"zaiko/internal/database/dbtest"
+	"zaiko/internal/models"
+)
+
+// TestPostgresStockFlow runs concurrent movements against PostgreSQL, where
+// nothing serializes writers unless InStockTx does. It only runs when
+// DATABASE_URL is set.
+func TestPostgresStockFlow(t *testing.T) {
+	db := dbtest.Postgres(t)
+	dbtest.Exec(t, db,
+		"INSERT INTO products (code, name, unit) VALUES ('P-001', 'Test product', 'pcs')",
+		"INSERT INTO warehouses (name) VALUES ('Main')",
+	)
+	svc := NewStockService(db, models.CostingMovingAverage)
+
+	if _, err := svc.StockIn(models.StockMovementRequest{ProductID: 1, WarehouseID: 1, Quantity: 10, UnitCost: cost(100)}, 1); err != nil {
+		t.Fatalf("stock in: %v", err)
+	}
+	if _, err := NewReservationService(db).Create(models.CreateReservationRequest{ProductID: 1, WarehouseID: 1, Quantity: 3, OwnerRef: "SO-1"}, 1); err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+
+	const workers = 20
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var issued []*models.Transaction
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			transaction, err := svc.StockOut(models.StockMovementRequest{ProductID: 1, WarehouseID: 1, Quantity: 1}, 1)
+
+			mu.Lock()
+			defer mu.Unlock()
+			var insufficientErr *InsufficientStockError
+			var reservedErr *ReservedStockError
+			switch {
+			case err == nil:
+				issued = append(issued, transaction)
+			case errors.As(err, &insufficientErr), errors.As(err, &reservedErr):
+			default:
+				t.Errorf("stock out: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Only the unreserved 7 can leave
+	if len(issued) != 7 {
+		t.Fatalf("issued %d times, want 7", len(issued))
+	}
+
+	if _, err := svc.Reverse(issued[0].ID, 1); err != nil {
+		t.Fatalf("reverse: %v", err)
+	}
+
+	stock, err := svc.stockRepo.FindByProductAndWarehouse(1, 1)
+	if err != nil {
+		t.Fatalf("find stock: %v", err)
+	}
+	if stock.Quantity != 4 || stock.Value != 400 {
+		t.Errorf("stock = %d worth %v, want 4 worth 400", stock.Quantity, stock.Value)
+	}
+
+	report, err := svc.Verify(false, 1)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if len(report.Mismatches) != 0 {
+		t.Errorf("mismatches = %+v, want none", report.Mismatches)
+	}
+
+	ledger, err := svc.Ledger(models.LedgerFilter{ProductID: 1, WarehouseID: 1})
+	if err != nil {
+		t.Fatalf("ledger: %v", err)
+	}
+	if ledger.ClosingQuantity != 4 || ledger.ClosingValue != 400 {
+		t.Errorf("ledger = %d worth %v, want 4 worth 400", ledger.ClosingQuantity, ledger.ClosingValue)
+	}
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"zaiko/internal/database"
+	"zaiko/internal/database/dbtest"
+	"zaiko/internal/models"
+	"zaiko/internal/repository"
+)
+
+// setupDB uses a database file rather than dbtest's in-memory database:
+// the concurrency tests need SQLite's busy timeout between writers.
+func setupDB(t *testing.T) *database.DB {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "zaiko.db")
+	db, err := database.Connect(path + "?_busy_timeout=5000")
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := db.SeedDefaultData(); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	dbtest.Exec(t, db,
+		"INSERT INTO products (code, name, unit) VALUES ('P-001', 'Test product', 'pcs')",
+		"INSERT INTO warehouses (name) VALUES ('Main')",
+		"INSERT INTO warehouses (name) VALUES ('Sub')",
+	)
+	return db
+}
+
+func TestStockOutConcurrent(t *testing.T) {
+	db := setupDB(t)
+	svc := NewStockService(db, models.CostingMovingAverage)
+
+	const initial = 10
+	const workers = 50
+
+	if _, err := svc.StockIn(models.StockMovementRequest{ProductID: 1, WarehouseID: 1, Quantity: initial}, 1); err != nil {
+		t.Fatalf("stock in: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded, insufficient := 0, 0
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.StockOut(models.StockMovementRequest{ProductID: 1, WarehouseID: 1, Quantity: 1}, 1)
+
+			mu.Lock()
+			defer mu.Unlock()
+			var insufficientErr *InsufficientStockError
+			switch {
+			case err == nil:
+				succeeded++
+			case errors.As(err, &insufficientErr):
+				insufficient++
+				if insufficientErr.Available < 0 {
+					t.Errorf("negative available quantity: %d", insufficientErr.Available)
+				}
+			default:
+				t.Errorf("stock out: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != initial {
+		t.Errorf("succeeded = %d, want %d", succeeded, initial)
+	}
+	if insufficient != workers-initial {
+		t.Errorf("insufficient = %d, want %d", insufficient, workers-initial)
+	}
+
+	stock, err := repository.NewStockRepository(db).FindByProductAndWarehouse(1, 1)
+	if err != nil {
+		t.Fatalf("find stock: %v", err)
+	}
+	if stock.Quantity != 0 {
+		t.Errorf("quantity = %d, want 0", stock.Quantity)
+	}
+
+	transactions, err := repository.NewTransactionRepository(db).FindAll(models.TransactionFilter{Type: string(models.TransactionTypeOut)})
+	if err != nil {
+		t.Fatalf("find transactions: %v", err)
+	}
+	if len(transactions) != initial {
+		t.Errorf("out transactions = %d, want %d", len(transactions), initial)
+	}
+}
+
+func TestStockOutNotFound(t *testing.T) {
+	svc := NewStockService(setupDB(t), models.CostingMovingAverage)
+
+	_, err := svc.StockOut(models.StockMovementRequest{ProductID: 1, WarehouseID: 2, Quantity: 1}, 1)
+	if !errors.Is(err, ErrStockNotFound) {
+		t.Fatalf("err = %v, want ErrStockNotFound", err)
+	}
+}
+
+func TestTransferRollsBackOnInsufficientStock(t *testing.T) {
+	db := setupDB(t)
+	svc := NewStockService(db, models.CostingMovingAverage)
+
+	if _, err := svc.StockIn(models.StockMovementRequest{ProductID: 1, WarehouseID: 1, Quantity: 5}, 1); err != nil {
+		t.Fatalf("stock in: %v", err)
+	}
+
+	_, _, err := svc.Transfer(models.StockTransferRequest{ProductID: 1, FromWarehouseID: 1, ToWarehouseID: 2, Quantity: 6}, 1)
+	var insufficient *InsufficientStockError
+	if !errors.As(err, &insufficient) {
+		t.Fatalf("err = %v, want InsufficientStockError", err)
+	}
+
+	if _, err := repository.NewStockRepository(db).FindByProductAndWarehouse(1, 2); err == nil {
+		t.Error("destination stock row exists after failed transfer")
+	}
+}
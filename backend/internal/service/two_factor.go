@@ -0,0 +1,293 @@
+package service
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"zaiko/internal/database"
+	"zaiko/internal/models"
+	"zaiko/internal/repository"
+	"zaiko/internal/totp"
+)
+
+const (
+	totpIssuer = "Zaiko"
+	// totpSkew accepts codes from one step before or after the current one
+	totpSkew          = 1
+	recoveryCodeCount = 10
+	challengeTTL      = 5 * time.Minute
+	challengeAttempts = 5
+)
+
+var (
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotSetUp       = errors.New("two-factor authentication has not been set up")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+	ErrInvalidChallenge        = errors.New("invalid or expired two-factor challenge")
+)
+
+// TwoFactorService manages TOTP two-factor authentication. Users with it
+// enabled get a challenge from login, which has to be completed with a TOTP
+// code or a recovery code before a session is started. Wrong codes count as
+// failed logins.
+type TwoFactorService struct {
+	userRepo       *repository.UserRepository
+	twoFactorRepo  *repository.TwoFactorRepository
+	sessionService *SessionService
+	loginGuard     *LoginGuard
+}
+
+func NewTwoFactorService(db *database.DB, sessionService *SessionService, loginGuard *LoginGuard) *TwoFactorService {
+	return &TwoFactorService{
+		userRepo:       repository.NewUserRepository(db),
+		twoFactorRepo:  repository.NewTwoFactorRepository(db),
+		sessionService: sessionService,
+		loginGuard:     loginGuard,
+	}
+}
+
+// WithTx returns a copy of the service that runs its queries in tx.
+func (s *TwoFactorService) WithTx(tx *sql.Tx) *TwoFactorService {
+	return &TwoFactorService{
+		userRepo:       s.userRepo.WithTx(tx),
+		twoFactorRepo:  s.twoFactorRepo.WithTx(tx),
+		sessionService: s.sessionService.WithTx(tx),
+		loginGuard:     s.loginGuard.WithTx(tx),
+	}
+}
+
+// Setup generates a new secret for the user. It takes effect once it is
+// confirmed with Enable.
+func (s *TwoFactorService) Setup(user *models.User) (*models.TwoFactorSetup, error) {
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := s.twoFactorRepo.SetSecret(user.ID, secret)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	return &models.TwoFactorSetup{
+		Secret: secret,
+		URI:    totp.URI(totpIssuer, user.Username, secret),
+	}, nil
+}
+
+// Enable confirms the secret from Setup with a code from the user's
+// authenticator and returns the user's recovery codes. The code also counts
+// as a two-factor login for the session it was sent from; the user's other
+// sessions have to log in again to get one.
+func (s *TwoFactorService) Enable(user *models.User, sessionID int64, code string) ([]string, error) {
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotSetUp
+	}
+
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now(), totpSkew)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err = s.twoFactorRepo.Enable(user.ID, step, hashes)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	if err := s.sessionService.ConfirmTwoFactor(sessionID); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Disable turns two-factor authentication off after checking a code.
+func (s *TwoFactorService) Disable(user *models.User, code string) error {
+	if !user.TwoFactorEnabled {
+		return ErrTwoFactorNotEnabled
+	}
+	if err := s.verify(user, code); err != nil {
+		return err
+	}
+	return s.twoFactorRepo.Disable(user.ID)
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking
+// a code.
+func (s *TwoFactorService) RegenerateRecoveryCodes(user *models.User, code string) ([]string, error) {
+	if !user.TwoFactorEnabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if err := s.verify(user, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.twoFactorRepo.ReplaceRecoveryCodes(user.ID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Challenge starts the second step of a login whose password was correct.
+func (s *TwoFactorService) Challenge(user *models.User) (*models.TwoFactorChallengeResponse, error) {
+	token, hash, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.twoFactorRepo.CreateChallenge(user.ID, hash, time.Now().Add(challengeTTL)); err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorChallengeResponse{
+		TwoFactorRequired: true,
+		Challenge:         token,
+		ExpiresIn:         int(challengeTTL.Seconds()),
+	}, nil
+}
+
+// Verify completes a challenge with a code and starts the session. A
+// challenge allows a few attempts before it has to be started over, and the
+// login guard applies across challenges: a wrong code is a failed login and
+// a locked out user gets a LoginLockedError. The user's failures are only
+// cleared once the code is accepted.
+func (s *TwoFactorService) Verify(challenge, code, userAgent, ipAddress string) (*models.LoginResponse, error) {
+	ch, err := s.twoFactorRepo.FindChallenge(hashToken(challenge))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidChallenge
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(ch.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loginGuard.Check(user.Username, ipAddress); err != nil {
+		return nil, err
+	}
+
+	ok, err := s.twoFactorRepo.AttemptChallenge(ch.ID, challengeAttempts)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidChallenge
+	}
+
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+	if !user.TwoFactorEnabled {
+		// Reset by an admin after the challenge was issued
+		return nil, ErrInvalidChallenge
+	}
+
+	if err := s.verify(user, code); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			if err := s.loginGuard.Fail(user.Username, ipAddress); err != nil {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+
+	ok, err = s.twoFactorRepo.CompleteChallenge(ch.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidChallenge
+	}
+
+	if err := s.loginGuard.Succeed(user.Username); err != nil {
+		return nil, err
+	}
+
+	return s.sessionService.Start(user, userAgent, ipAddress, true)
+}
+
+// verify accepts a TOTP code that has not been used before, or an unused
+// recovery code.
+func (s *TwoFactorService) verify(user *models.User, code string) error {
+	code = strings.TrimSpace(code)
+
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(user.TOTPSecret, code, time.Now(), totpSkew)
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+		ok, err := s.twoFactorRepo.UseStep(user.ID, step)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	ok, err := s.twoFactorRepo.UseRecoveryCode(user.ID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// newRecoveryCodes returns recovery codes formatted as xxxx-xxxx-xxxx-xxxx
+// and the hashes under which they are stored.
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	for range recoveryCodeCount {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(encoding.EncodeToString(b))
+		code := raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16]
+
+		codes = append(codes, code)
+		hashes = append(hashes, hashToken(raw))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode accepts recovery codes regardless of case and
+// separators.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
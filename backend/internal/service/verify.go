@@ -0,0 +1,95 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+
+	"zaiko/internal/models"
+)
+
+const (
+	// repairNote is the note of the adjustments posted by Verify.
+	repairNote = "Consistency repair"
+	// repairReasonCode is the adjustment reason of those adjustments. The
+	// reason is created when it is first needed.
+	repairReasonCode = "REPAIR"
+	repairReasonName = "整合性修正"
+)
+
+// Verify compares the quantity of every stock row with the sum of its
+// transactions. With repair set it posts an adjustment transaction for each
+// mismatch so the ledger agrees with the stock table again; the stock row
+// and its cost layers are left as they are. The adjustment carries the
+// difference in value as well, so ledger and stock values agree too.
+// Orphaned rows are reported but not repaired.
+func (s *StockService) Verify(repair bool, userID int64) (*models.VerifyReport, error) {
+	report := &models.VerifyReport{Mismatches: []models.StockMismatch{}}
+
+	err := s.db.InStockTx(func(tx *sql.Tx) error {
+		mismatches, err := s.stockRepo.WithTx(tx).FindLedgerMismatches()
+		if err != nil {
+			return err
+		}
+
+		transactionRepo := s.transactionRepo.WithTx(tx)
+		var reason *models.AdjustmentReason
+		for _, m := range mismatches {
+			m.StockValue = roundCost(m.StockValue)
+			m.LedgerValue = roundCost(m.LedgerValue)
+
+			if !repair || m.Orphaned {
+				report.Unrepaired++
+				report.Mismatches = append(report.Mismatches, m)
+				continue
+			}
+
+			if reason == nil {
+				if reason, err = s.repairReason(tx); err != nil {
+					return err
+				}
+			}
+
+			transaction, err := transactionRepo.Create(
+				m.ProductID,
+				m.WarehouseID,
+				models.TransactionTypeAdjustment,
+				m.Difference,
+				repairNote,
+				userID,
+			)
+			if err != nil {
+				return err
+			}
+
+			totalCost := roundCost(m.StockValue - m.LedgerValue)
+			if err := transactionRepo.SetCost(transaction.ID, roundCost(totalCost/float64(m.Difference)), totalCost); err != nil {
+				return err
+			}
+			if err := transactionRepo.SetReason(transaction.ID, reason.ID); err != nil {
+				return err
+			}
+			m.TransactionID = &transaction.ID
+
+			report.Repaired++
+			report.Mismatches = append(report.Mismatches, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// repairReason returns the adjustment reason of repairs, creating it if it
+// does not exist yet.
+func (s *StockService) repairReason(tx *sql.Tx) (*models.AdjustmentReason, error) {
+	reasonRepo := s.reasonRepo.WithTx(tx)
+
+	reason, err := reasonRepo.FindByCode(repairReasonCode)
+	if errors.Is(err, sql.ErrNoRows) {
+		return reasonRepo.Create(models.CreateAdjustmentReasonRequest{Code: repairReasonCode, Name: repairReasonName})
+	}
+	return reason, err
+}
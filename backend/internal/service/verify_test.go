@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"zaiko/internal/database/dbtest"
+	"zaiko/internal/models"
+)
+
+func TestVerifyAndRepair(t *testing.T) {
+	svc := newCostingService(t, models.CostingMovingAverage)
+
+	receive(t, svc, 10, cost(10))
+
+	// A stock update whose transaction was lost, and legacy stock that was
+	// never backed by a transaction
+	dbtest.Exec(t, svc.db,
+		"UPDATE stock SET quantity = 13, value = 124 WHERE product_id = 1 AND warehouse_id = 1",
+		"INSERT INTO stock (product_id, warehouse_id, quantity) VALUES (1, 2, 5)",
+	)
+
+	report, err := svc.Verify(false, 1)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if len(report.Mismatches) != 2 || report.Repaired != 0 || report.Unrepaired != 2 {
+		t.Fatalf("report = %+v, want 2 unrepaired mismatches", report)
+	}
+	if m := report.Mismatches[0]; m.WarehouseID != 1 || m.StockQuantity != 13 || m.LedgerQuantity != 10 || m.Difference != 3 || m.TransactionID != nil {
+		t.Errorf("main warehouse = %+v, want 13 against 10 and no repair", m)
+	}
+	if m := report.Mismatches[1]; m.WarehouseID != 2 || m.LedgerQuantity != 0 || m.Difference != 5 {
+		t.Errorf("sub warehouse = %+v, want 5 against 0", m)
+	}
+
+	report, err = svc.Verify(true, 1)
+	if err != nil {
+		t.Fatalf("repair: %v", err)
+	}
+	if report.Repaired != 2 || report.Unrepaired != 0 {
+		t.Errorf("repaired %d, unrepaired %d, want 2 and 0", report.Repaired, report.Unrepaired)
+	}
+	for _, m := range report.Mismatches {
+		if m.TransactionID == nil {
+			t.Errorf("mismatch %+v was not repaired", m)
+		}
+	}
+
+	adjustment, err := svc.transactionRepo.FindByID(*report.Mismatches[0].TransactionID)
+	if err != nil {
+		t.Fatalf("find adjustment: %v", err)
+	}
+	if adjustment.Type != models.TransactionTypeAdjustment || adjustment.Quantity != 3 || adjustment.UnitCost != 8 || adjustment.TotalCost != 24 {
+		t.Errorf("adjustment = %s %d at %v (%v), want adjustment 3 at 8 (24)",
+			adjustment.Type, adjustment.Quantity, adjustment.UnitCost, adjustment.TotalCost)
+	}
+	reason, err := svc.reasonRepo.FindByCode("REPAIR")
+	if err != nil {
+		t.Fatalf("find repair reason: %v", err)
+	}
+	if adjustment.ReasonID == nil || *adjustment.ReasonID != reason.ID {
+		t.Errorf("adjustment reason = %v, want %d (REPAIR)", adjustment.ReasonID, reason.ID)
+	}
+
+	report, err = svc.Verify(false, 1)
+	if err != nil {
+		t.Fatalf("verify after repair: %v", err)
+	}
+	if len(report.Mismatches) != 0 {
+		t.Errorf("mismatches after repair = %+v, want none", report.Mismatches)
+	}
+
+	// The repair leaves stock alone and brings the ledger in line with it
+	stock, err := svc.stockRepo.FindByProductAndWarehouse(1, 1)
+	if err != nil {
+		t.Fatalf("find stock: %v", err)
+	}
+	ledger, err := svc.Ledger(models.LedgerFilter{ProductID: 1, WarehouseID: 1})
+	if err != nil {
+		t.Fatalf("ledger: %v", err)
+	}
+	if stock.Quantity != 13 || ledger.ClosingQuantity != 13 || ledger.ClosingValue != stock.Value {
+		t.Errorf("stock %d worth %v, ledger %d worth %v, want both 13 worth 124",
+			stock.Quantity, stock.Value, ledger.ClosingQuantity, ledger.ClosingValue)
+	}
+}
+
+func TestRepairLeavesOrphanedRows(t *testing.T) {
+	svc := newCostingService(t, models.CostingMovingAverage)
+
+	receive(t, svc, 10, cost(10))
+
+	// Stock of a warehouse that no longer exists, written past the foreign
+	// key the way legacy data was
+	ctx := context.Background()
+	c, err := svc.db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("conn: %v", err)
+	}
+	for _, stmt := range []string{
+		"PRAGMA foreign_keys = OFF",
+		"INSERT INTO stock (product_id, warehouse_id, quantity) VALUES (1, 99, 4)",
+		"PRAGMA foreign_keys = ON",
+	} {
+		if _, err := c.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("fixture %q: %v", stmt, err)
+		}
+	}
+	c.Close()
+
+	report, err := svc.Verify(true, 1)
+	if err != nil {
+		t.Fatalf("repair: %v", err)
+	}
+	if len(report.Mismatches) != 1 || !report.Mismatches[0].Orphaned || report.Mismatches[0].TransactionID != nil {
+		t.Fatalf("mismatches = %+v, want the orphaned row left alone", report.Mismatches)
+	}
+	if report.Repaired != 0 || report.Unrepaired != 1 {
+		t.Errorf("repaired %d, unrepaired %d, want 0 and 1", report.Repaired, report.Unrepaired)
+	}
+}
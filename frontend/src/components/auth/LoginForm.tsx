@@ -1,12 +1,23 @@
 import { useState } from 'react';
 import { useNavigate } from 'react-router-dom';
+import { isAxiosError } from 'axios';
 import { useAuthStore } from '../../store/authStore';
 
 export function LoginForm() {
   const [username, setUsername] = useState('');
   const [password, setPassword] = useState('');
+  const [newPassword, setNewPassword] = useState('');
+  const [confirmPassword, setConfirmPassword] = useState('');
+  const [code, setCode] = useState('');
   const [error, setError] = useState('');
-  const { login, isLoading } = useAuthStore();
+  const {
+    login,
+    verifyTwoFactor,
+    changePassword,
+    passwordChangeRequired,
+    twoFactorChallenge,
+    isLoading,
+  } = useAuthStore();
   const navigate = useNavigate();
 
   const handleSubmit = async (e: React.FormEvent) => {
@@ -15,12 +26,156 @@ export function LoginForm() {
 
     try {
       await login(username, password);
+      const state = useAuthStore.getState();
+      if (!state.passwordChangeRequired && !state.twoFactorChallenge) {
+        navigate('/');
+      }
+    } catch (err) {
+      if (isAxiosError(err) && err.response?.status === 429) {
+        setError('ログインの失敗が続いたため、しばらくログインできません');
+        return;
+      }
+      setError('ユーザー名またはパスワードが正しくありません');
+    }
+  };
+
+  const handleVerify = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError('');
+
+    try {
+      await verifyTwoFactor(code);
+      if (!useAuthStore.getState().passwordChangeRequired) {
+        navigate('/');
+      }
+    } catch (err) {
+      setCode('');
+      if (isAxiosError(err) && err.response?.data?.error === 'Invalid or expired two-factor challenge') {
+        // Too many attempts or expired; the password has to be entered again
+        useAuthStore.setState({ twoFactorChallenge: null });
+        setError('認証の有効期限が切れました。もう一度ログインしてください');
+        return;
+      }
+      setError('認証コードが正しくありません');
+    }
+  };
+
+  const handleChangePassword = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError('');
+
+    if (newPassword !== confirmPassword) {
+      setError('新しいパスワードが一致しません');
+      return;
+    }
+    if (newPassword.length < 8) {
+      setError('パスワードは8文字以上で入力してください');
+      return;
+    }
+
+    try {
+      await changePassword(password, newPassword);
       navigate('/');
     } catch {
-      setError('ユーザー名またはパスワードが正しくありません');
+      setError('パスワードを変更できませんでした');
     }
   };
 
+  if (passwordChangeRequired) {
+    return (
+      <div className="min-h-screen flex items-center justify-center bg-gray-100">
+        <div className="bg-white p-8 rounded-lg shadow-md w-full max-w-md">
+          <h1 className="text-2xl font-bold text-center mb-2">パスワード変更</h1>
+          <p className="text-center text-sm text-gray-500 mb-6">
+            続行するにはパスワードを変更してください
+          </p>
+          <form onSubmit={handleChangePassword} className="space-y-4">
+            {error && (
+              <div className="bg-red-50 text-red-600 p-3 rounded-lg text-sm">
+                {error}
+              </div>
+            )}
+            <div>
+              <label htmlFor="new-password" className="block text-sm font-medium text-gray-700 mb-1">
+                新しいパスワード
+              </label>
+              <input
+                id="new-password"
+                type="password"
+                value={newPassword}
+                onChange={(e) => setNewPassword(e.target.value)}
+                className="w-full px-3 py-2 border border-gray-300 rounded-lg focus:outline-none focus:ring-2 focus:ring-blue-500"
+                required
+              />
+            </div>
+            <div>
+              <label htmlFor="confirm-password" className="block text-sm font-medium text-gray-700 mb-1">
+                新しいパスワード (確認)
+              </label>
+              <input
+                id="confirm-password"
+                type="password"
+                value={confirmPassword}
+                onChange={(e) => setConfirmPassword(e.target.value)}
+                className="w-full px-3 py-2 border border-gray-300 rounded-lg focus:outline-none focus:ring-2 focus:ring-blue-500"
+                required
+              />
+            </div>
+            <button
+              type="submit"
+              disabled={isLoading}
+              className="w-full py-2 px-4 bg-blue-600 text-white rounded-lg hover:bg-blue-700 disabled:opacity-50 disabled:cursor-not-allowed transition-colors"
+            >
+              {isLoading ? '変更中...' : 'パスワードを変更'}
+            </button>
+          </form>
+        </div>
+      </div>
+    );
+  }
+
+  if (twoFactorChallenge) {
+    return (
+      <div className="min-h-screen flex items-center justify-center bg-gray-100">
+        <div className="bg-white p-8 rounded-lg shadow-md w-full max-w-md">
+          <h1 className="text-2xl font-bold text-center mb-2">2段階認証</h1>
+          <p className="text-center text-sm text-gray-500 mb-6">
+            認証アプリのコード、またはリカバリーコードを入力してください
+          </p>
+          <form onSubmit={handleVerify} className="space-y-4">
+            {error && (
+              <div className="bg-red-50 text-red-600 p-3 rounded-lg text-sm">
+                {error}
+              </div>
+            )}
+            <div>
+              <label htmlFor="code" className="block text-sm font-medium text-gray-700 mb-1">
+                認証コード
+              </label>
+              <input
+                id="code"
+                type="text"
+                inputMode="text"
+                autoComplete="one-time-code"
+                value={code}
+                onChange={(e) => setCode(e.target.value)}
+                className="w-full px-3 py-2 border border-gray-300 rounded-lg focus:outline-none focus:ring-2 focus:ring-blue-500"
+                required
+              />
+            </div>
+            <button
+              type="submit"
+              disabled={isLoading}
+              className="w-full py-2 px-4 bg-blue-600 text-white rounded-lg hover:bg-blue-700 disabled:opacity-50 disabled:cursor-not-allowed transition-colors"
+            >
+              {isLoading ? '確認中...' : '確認'}
+            </button>
+          </form>
+        </div>
+      </div>
+    );
+  }
+
   return (
     <div className="min-h-screen flex items-center justify-center bg-gray-100">
       <div className="bg-white p-8 rounded-lg shadow-md w-full max-w-md">
@@ -64,7 +64,7 @@ export function DashboardPage() {
       <h1 className="text-2xl font-bold">ダッシュボード</h1>
 
       {/* Summary Cards */}
-      <div className="grid grid-cols-1 md:grid-cols-2 lg:grid-cols-4 gap-4">
+      <div className="grid grid-cols-1 md:grid-cols-2 lg:grid-cols-5 gap-4">
         <div className="bg-white p-6 rounded-lg shadow">
           <h3 className="text-sm font-medium text-gray-500">登録商品数</h3>
           <p className="text-3xl font-bold text-blue-600">{summary.total_products}</p>
@@ -75,7 +75,11 @@ export function DashboardPage() {
         </div>
         <div className="bg-white p-6 rounded-lg shadow">
           <h3 className="text-sm font-medium text-gray-500">総在庫数量</h3>
-          <p className="text-3xl font-bold text-orange-600">{summary.total_stock_value.toLocaleString()}</p>
+          <p className="text-3xl font-bold text-orange-600">{summary.total_stock_quantity.toLocaleString()}</p>
+        </div>
+        <div className="bg-white p-6 rounded-lg shadow">
+          <h3 className="text-sm font-medium text-gray-500">在庫金額</h3>
+          <p className="text-3xl font-bold text-purple-600">{summary.total_stock_value.toLocaleString()}</p>
         </div>
         <div className="bg-white p-6 rounded-lg shadow">
           <h3 className="text-sm font-medium text-gray-500">在庫少商品</h3>
@@ -1,17 +1,32 @@
 import { useEffect, useState } from 'react';
-import { stockApi, productApi, warehouseApi } from '../services/api';
-import type { Stock, Product, Warehouse, Transaction, StockMovementRequest } from '../types';
+import { stockApi, productApi, warehouseApi, adjustmentReasonApi } from '../services/api';
+import type { Stock, Product, Warehouse, Transaction, StockMovementRequest, AdjustmentReason } from '../types';
 import { Table } from '../components/common/Table';
 import { Modal } from '../components/common/Modal';
 
+const transactionTypeLabels: Record<Transaction['type'], string> = {
+  in: '入庫',
+  out: '出庫',
+  transfer: '移動',
+  adjustment: '調整',
+};
+
+const modalTitles = {
+  in: '入庫登録',
+  out: '出庫登録',
+  adjust: '在庫調整',
+};
+
 export function StockPage() {
   const [stocks, setStocks] = useState<Stock[]>([]);
   const [transactions, setTransactions] = useState<Transaction[]>([]);
   const [products, setProducts] = useState<Product[]>([]);
   const [warehouses, setWarehouses] = useState<Warehouse[]>([]);
+  const [reasons, setReasons] = useState<AdjustmentReason[]>([]);
   const [loading, setLoading] = useState(true);
   const [isModalOpen, setIsModalOpen] = useState(false);
-  const [modalType, setModalType] = useState<'in' | 'out'>('in');
+  const [modalType, setModalType] = useState<'in' | 'out' | 'adjust'>('in');
+  const [reasonId, setReasonId] = useState(0);
   const [searchTerm, setSearchTerm] = useState('');
   const [selectedWarehouse, setSelectedWarehouse] = useState<number | ''>('');
   const [activeTab, setActiveTab] = useState<'stock' | 'transactions'>('stock');
@@ -25,7 +40,7 @@ export function StockPage() {
 
   const fetchData = async () => {
     try {
-      const [stocksData, transactionsData, productsData, warehousesData] = await Promise.all([
+      const [stocksData, transactionsData, productsData, warehousesData, reasonsData] = await Promise.all([
         stockApi.getAll({
           search: searchTerm || undefined,
           warehouse_id: selectedWarehouse || undefined,
@@ -33,11 +48,13 @@ export function StockPage() {
         stockApi.getTransactions({ limit: 50 }),
         productApi.getAll(),
         warehouseApi.getAll(),
+        adjustmentReasonApi.getAll(),
       ]);
       setStocks(stocksData);
       setTransactions(transactionsData);
       setProducts(productsData);
       setWarehouses(warehousesData);
+      setReasons(reasonsData);
     } catch (error) {
       console.error('Failed to fetch data:', error);
     } finally {
@@ -54,6 +71,14 @@ export function StockPage() {
     try {
       if (modalType === 'in') {
         await stockApi.stockIn(form);
+      } else if (modalType === 'adjust') {
+        await stockApi.adjust({
+          product_id: form.product_id,
+          warehouse_id: form.warehouse_id,
+          quantity: form.quantity,
+          reason_id: reasonId,
+          note: form.note,
+        });
       } else {
         await stockApi.stockOut(form);
       }
@@ -67,6 +92,19 @@ export function StockPage() {
     }
   };
 
+  const handleReverse = async (tx: Transaction) => {
+    if (!confirm(`入出庫 #${tx.id} を取り消しますか？`)) return;
+    try {
+      await stockApi.reverseTransaction(tx.id);
+      fetchData();
+    } catch (error: unknown) {
+      console.error('Failed to reverse transaction:', error);
+      const axiosError = error as { response?: { data?: { error?: string; reason?: string } } };
+      const data = axiosError.response?.data;
+      alert(data?.reason ? `${data.error}: ${data.reason}` : data?.error || '取消に失敗しました');
+    }
+  };
+
   const resetForm = () => {
     setForm({
       product_id: 0,
@@ -74,9 +112,10 @@ export function StockPage() {
       quantity: 1,
       note: '',
     });
+    setReasonId(0);
   };
 
-  const openModal = (type: 'in' | 'out') => {
+  const openModal = (type: 'in' | 'out' | 'adjust') => {
     resetForm();
     setModalType(type);
     setIsModalOpen(true);
@@ -128,10 +167,12 @@ export function StockPage() {
           className={`px-2 py-1 rounded text-sm ${
             tx.type === 'in'
               ? 'bg-green-100 text-green-800'
-              : 'bg-red-100 text-red-800'
+              : tx.type === 'out'
+                ? 'bg-red-100 text-red-800'
+                : 'bg-gray-100 text-gray-800'
           }`}
         >
-          {tx.type === 'in' ? '入庫' : '出庫'}
+          {transactionTypeLabels[tx.type]}
         </span>
       ),
     },
@@ -150,6 +191,11 @@ export function StockPage() {
       header: '数量',
       render: (tx: Transaction) => `${tx.quantity} ${tx.product?.unit || ''}`,
     },
+    {
+      key: 'reason',
+      header: '理由',
+      render: (tx: Transaction) => tx.reason?.name || '-',
+    },
     {
       key: 'note',
       header: '備考',
@@ -160,6 +206,29 @@ export function StockPage() {
       header: '担当者',
       render: (tx: Transaction) => tx.user?.username,
     },
+    {
+      key: 'reverse',
+      header: '',
+      render: (tx: Transaction) => {
+        if (tx.reversed_by) {
+          return <span className="text-sm text-gray-500">取消済</span>;
+        }
+        if (tx.reversal_of) {
+          return <span className="text-sm text-gray-500">#{tx.reversal_of} の取消</span>;
+        }
+        if (tx.type === 'transfer') {
+          return null;
+        }
+        return (
+          <button
+            onClick={() => handleReverse(tx)}
+            className="text-sm text-red-600 hover:text-red-800"
+          >
+            取消
+          </button>
+        );
+      },
+    },
   ];
 
   if (loading) {
@@ -183,6 +252,12 @@ export function StockPage() {
           >
             出庫
           </button>
+          <button
+            onClick={() => openModal('adjust')}
+            className="px-4 py-2 bg-gray-600 text-white rounded-lg hover:bg-gray-700"
+          >
+            調整
+          </button>
         </div>
       </div>
 
@@ -253,7 +328,7 @@ export function StockPage() {
       <Modal
         isOpen={isModalOpen}
         onClose={() => setIsModalOpen(false)}
-        title={modalType === 'in' ? '入庫登録' : '出庫登録'}
+        title={modalTitles[modalType]}
       >
         <form onSubmit={handleSubmit} className="space-y-4">
           <div>
@@ -298,17 +373,55 @@ export function StockPage() {
           </div>
           <div>
             <label className="block text-sm font-medium text-gray-700 mb-1">
-              数量 *
+              {modalType === 'adjust' ? '増減数 (減少はマイナス) *' : '数量 *'}
             </label>
             <input
               type="number"
-              min="1"
+              min={modalType === 'adjust' ? undefined : 1}
               value={form.quantity}
               onChange={(e) => setForm({ ...form, quantity: Number(e.target.value) })}
               className="w-full px-3 py-2 border border-gray-300 rounded-lg focus:outline-none focus:ring-2 focus:ring-blue-500"
               required
             />
           </div>
+          {modalType === 'adjust' && (
+            <div>
+              <label className="block text-sm font-medium text-gray-700 mb-1">
+                理由 *
+              </label>
+              <select
+                value={reasonId || ''}
+                onChange={(e) => setReasonId(Number(e.target.value))}
+                className="w-full px-3 py-2 border border-gray-300 rounded-lg focus:outline-none focus:ring-2 focus:ring-blue-500"
+                required
+              >
+                <option value="">選択してください</option>
+                {reasons.map((r) => (
+                  <option key={r.id} value={r.id}>
+                    {r.name}
+                  </option>
+                ))}
+              </select>
+            </div>
+          )}
+          {modalType === 'in' && (
+            <div>
+              <label className="block text-sm font-medium text-gray-700 mb-1">
+                単価
+              </label>
+              <input
+                type="number"
+                min="0"
+                step="any"
+                value={form.unit_cost ?? ''}
+                onChange={(e) =>
+                  setForm({ ...form, unit_cost: e.target.value === '' ? undefined : Number(e.target.value) })
+                }
+                placeholder="未入力の場合は現在の平均単価"
+                className="w-full px-3 py-2 border border-gray-300 rounded-lg focus:outline-none focus:ring-2 focus:ring-blue-500"
+              />
+            </div>
+          )}
           <div>
             <label className="block text-sm font-medium text-gray-700 mb-1">
               備考
@@ -333,10 +446,12 @@ export function StockPage() {
               className={`flex-1 px-4 py-2 text-white rounded-lg ${
                 modalType === 'in'
                   ? 'bg-green-600 hover:bg-green-700'
-                  : 'bg-orange-600 hover:bg-orange-700'
+                  : modalType === 'out'
+                    ? 'bg-orange-600 hover:bg-orange-700'
+                    : 'bg-gray-600 hover:bg-gray-700'
               }`}
             >
-              {modalType === 'in' ? '入庫登録' : '出庫登録'}
+              {modalTitles[modalType]}
             </button>
           </div>
         </form>
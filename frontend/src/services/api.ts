@@ -2,6 +2,9 @@ import axios from 'axios';
 import type {
   LoginRequest,
   LoginResponse,
+  TwoFactorChallengeResponse,
+  TwoFactorVerifyRequest,
+  ChangePasswordRequest,
   User,
   Category,
   Product,
@@ -11,6 +14,8 @@ import type {
   Stock,
   Transaction,
   StockMovementRequest,
+  StockAdjustmentRequest,
+  AdjustmentReason,
   DashboardSummary,
 } from '../types';
 
@@ -32,12 +37,50 @@ api.interceptors.request.use((config) => {
   return config;
 });
 
+// Refresh the access token once on a 401 and retry; concurrent requests
+// share a single refresh since each refresh token can only be used once
+let refreshing: Promise<string> | null = null;
+
+const refreshAccessToken = async (): Promise<string> => {
+  const refreshToken = localStorage.getItem('refresh_token');
+  if (!refreshToken) {
+    throw new Error('No refresh token');
+  }
+  const response = await axios.post<LoginResponse>(`${API_BASE_URL}/auth/refresh`, {
+    refresh_token: refreshToken,
+  });
+  localStorage.setItem('token', response.data.token);
+  localStorage.setItem('refresh_token', response.data.refresh_token);
+  return response.data.token;
+};
+
+const credentialEndpoints = ['/auth/login', '/auth/2fa/verify'];
+
 // Handle 401 responses
 api.interceptors.response.use(
   (response) => response,
-  (error) => {
+  async (error) => {
+    const original = error.config;
+    // A 401 from the login steps is a wrong password or code
+    if (original && credentialEndpoints.includes(original.url)) {
+      return Promise.reject(error);
+    }
+    if (error.response?.status === 401 && original && !original._retry) {
+      original._retry = true;
+      try {
+        refreshing ??= refreshAccessToken().finally(() => {
+          refreshing = null;
+        });
+        const token = await refreshing;
+        original.headers.Authorization = `Bearer ${token}`;
+        return api(original);
+      } catch {
+        // Fall through to logging out
+      }
+    }
     if (error.response?.status === 401) {
       localStorage.removeItem('token');
+      localStorage.removeItem('refresh_token');
       window.location.href = '/login';
     }
     return Promise.reject(error);
@@ -46,14 +89,25 @@ api.interceptors.response.use(
 
 // Auth
 export const authApi = {
-  login: async (data: LoginRequest): Promise<LoginResponse> => {
-    const response = await api.post<LoginResponse>('/auth/login', data);
+  login: async (data: LoginRequest): Promise<LoginResponse | TwoFactorChallengeResponse> => {
+    const response = await api.post<LoginResponse | TwoFactorChallengeResponse>('/auth/login', data);
     return response.data;
   },
+  verifyTwoFactor: async (data: TwoFactorVerifyRequest): Promise<LoginResponse> => {
+    const response = await api.post<LoginResponse>('/auth/2fa/verify', data);
+    return response.data;
+  },
+  logout: async (): Promise<void> => {
+    await api.post('/auth/logout');
+  },
   me: async (): Promise<User> => {
     const response = await api.get<User>('/auth/me');
     return response.data;
   },
+  changePassword: async (data: ChangePasswordRequest): Promise<LoginResponse> => {
+    const response = await api.put<LoginResponse>('/auth/password', data);
+    return response.data;
+  },
 };
 
 // Categories
@@ -117,6 +171,14 @@ export const warehouseApi = {
   },
 };
 
+// Adjustment reasons
+export const adjustmentReasonApi = {
+  getAll: async (): Promise<AdjustmentReason[]> => {
+    const response = await api.get<AdjustmentReason[]>('/adjustment-reasons');
+    return response.data;
+  },
+};
+
 // Stock
 export const stockApi = {
   getAll: async (params?: { product_id?: number; warehouse_id?: number; search?: string }): Promise<Stock[]> => {
@@ -131,7 +193,15 @@ export const stockApi = {
     const response = await api.post('/stock/out', data);
     return response.data;
   },
-  getTransactions: async (params?: { product_id?: number; warehouse_id?: number; type?: string; limit?: number }): Promise<Transaction[]> => {
+  adjust: async (data: StockAdjustmentRequest): Promise<{ message: string; transaction: Transaction }> => {
+    const response = await api.post('/stock/adjust', data);
+    return response.data;
+  },
+  reverseTransaction: async (id: number): Promise<{ message: string; transaction: Transaction }> => {
+    const response = await api.post(`/stock/transactions/${id}/reverse`);
+    return response.data;
+  },
+  getTransactions: async (params?: { product_id?: number; warehouse_id?: number; type?: string; reason_id?: number; limit?: number }): Promise<Transaction[]> => {
     const response = await api.get<Transaction[]>('/stock/transactions', { params });
     return response.data;
   },
@@ -1,5 +1,5 @@
 import { create } from 'zustand';
-import type { User } from '../types';
+import type { LoginResponse, User } from '../types';
 import { authApi } from '../services/api';
 
 interface AuthState {
@@ -7,28 +7,76 @@ interface AuthState {
   token: string | null;
   isAuthenticated: boolean;
   isLoading: boolean;
+  // Set after a login whose password must be changed before continuing
+  passwordChangeRequired: boolean;
+  // Set after a login that has to be completed with a two-factor code
+  twoFactorChallenge: string | null;
   login: (username: string, password: string) => Promise<void>;
+  verifyTwoFactor: (code: string) => Promise<void>;
+  changePassword: (currentPassword: string, newPassword: string) => Promise<void>;
   logout: () => void;
   checkAuth: () => Promise<void>;
 }
 
-export const useAuthStore = create<AuthState>((set) => ({
+const signedIn = (response: LoginResponse) => {
+  localStorage.setItem('token', response.token);
+  localStorage.setItem('refresh_token', response.refresh_token);
+  return {
+    user: response.user,
+    token: response.token,
+    isAuthenticated: !response.user.must_change_password,
+    passwordChangeRequired: response.user.must_change_password,
+    twoFactorChallenge: null,
+    isLoading: false,
+  };
+};
+
+export const useAuthStore = create<AuthState>((set, get) => ({
   user: null,
   token: localStorage.getItem('token'),
   isAuthenticated: !!localStorage.getItem('token'),
   isLoading: false,
+  passwordChangeRequired: false,
+  twoFactorChallenge: null,
 
   login: async (username: string, password: string) => {
     set({ isLoading: true });
     try {
       const response = await authApi.login({ username, password });
-      localStorage.setItem('token', response.token);
-      set({
-        user: response.user,
-        token: response.token,
-        isAuthenticated: true,
-        isLoading: false,
+      if ('two_factor_required' in response) {
+        set({ twoFactorChallenge: response.challenge, isLoading: false });
+        return;
+      }
+      set(signedIn(response));
+    } catch (error) {
+      set({ isLoading: false });
+      throw error;
+    }
+  },
+
+  verifyTwoFactor: async (code: string) => {
+    const challenge = get().twoFactorChallenge;
+    if (!challenge) {
+      return;
+    }
+    set({ isLoading: true });
+    try {
+      const response = await authApi.verifyTwoFactor({ challenge, code });
+      set(signedIn(response));
+    } catch (error) {
+      set({ isLoading: false });
+      throw error;
+    }
+  },
+
+  changePassword: async (currentPassword: string, newPassword: string) => {
+    set({ isLoading: true });
+    try {
+      const response = await authApi.changePassword({
+        current_password: currentPassword,
+        new_password: newPassword,
       });
+      set(signedIn(response));
     } catch (error) {
       set({ isLoading: false });
       throw error;
@@ -36,11 +84,16 @@ export const useAuthStore = create<AuthState>((set) => ({
   },
 
   logout: () => {
+    // Revoke the session on the server; the local state is cleared regardless
+    authApi.logout().catch(() => {});
     localStorage.removeItem('token');
+    localStorage.removeItem('refresh_token');
     set({
       user: null,
       token: null,
       isAuthenticated: false,
+      passwordChangeRequired: false,
+      twoFactorChallenge: null,
     });
   },
 
@@ -53,9 +106,17 @@ export const useAuthStore = create<AuthState>((set) => ({
 
     try {
       const user = await authApi.me();
+      if (user.must_change_password) {
+        // The current password is needed for the change, so log in again
+        localStorage.removeItem('token');
+        localStorage.removeItem('refresh_token');
+        set({ isAuthenticated: false, user: null, token: null });
+        return;
+      }
       set({ user, isAuthenticated: true });
     } catch {
       localStorage.removeItem('token');
+      localStorage.removeItem('refresh_token');
       set({ isAuthenticated: false, user: null, token: null });
     }
   },
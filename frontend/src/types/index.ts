@@ -1,6 +1,12 @@
+export type Role = 'admin' | 'manager' | 'operator' | 'viewer';
+
 export interface User {
   id: number;
   username: string;
+  role: Role;
+  disabled: boolean;
+  must_change_password: boolean;
+  two_factor_enabled: boolean;
   created_at: string;
 }
 
@@ -17,6 +23,7 @@ export interface Product {
   category_id: number;
   category?: Category;
   unit: string;
+  serialized: boolean;
   created_at: string;
 }
 
@@ -33,20 +40,41 @@ export interface Stock {
   warehouse_id: number;
   warehouse?: Warehouse;
   quantity: number;
+  value: number;
+  on_hand: number;
+  reserved: number;
+  available: number;
+  allocated: number;
+  backordered: number;
   updated_at: string;
 }
 
+export interface AdjustmentReason {
+  id: number;
+  code: string;
+  name: string;
+}
+
 export interface Transaction {
   id: number;
   product_id: number;
   product?: Product;
   warehouse_id: number;
   warehouse?: Warehouse;
-  type: 'in' | 'out';
+  type: 'in' | 'out' | 'transfer' | 'adjustment';
   quantity: number;
+  unit_cost: number;
+  // Signed like quantity; the cost of goods issued for 'out'
+  total_cost: number;
   note: string;
+  reason_id?: number;
+  reason?: AdjustmentReason;
   user_id: number;
   user?: User;
+  related_transaction_id?: number;
+  // Set on a reversal and on the transaction it reversed
+  reversal_of?: number;
+  reversed_by?: number;
   created_at: string;
 }
 
@@ -67,6 +95,8 @@ export interface CategoryStockSummary {
 export interface DashboardSummary {
   total_products: number;
   total_warehouses: number;
+  total_stock_quantity: number;
+  // Value of the stock on hand at cost
   total_stock_value: number;
   low_stock_items: number;
   recent_transactions: Transaction[];
@@ -81,9 +111,31 @@ export interface LoginRequest {
 
 export interface LoginResponse {
   token: string;
+  refresh_token: string;
+  // Lifetime of the access token in seconds
+  expires_in: number;
   user: User;
 }
 
+// Returned by login instead of a LoginResponse when two-factor
+// authentication is enabled
+export interface TwoFactorChallengeResponse {
+  two_factor_required: true;
+  challenge: string;
+  expires_in: number;
+}
+
+export interface TwoFactorVerifyRequest {
+  challenge: string;
+  // TOTP code or recovery code
+  code: string;
+}
+
+export interface ChangePasswordRequest {
+  current_password: string;
+  new_password: string;
+}
+
 export interface CreateProductRequest {
   code: string;
   name: string;
@@ -101,5 +153,17 @@ export interface StockMovementRequest {
   product_id: number;
   warehouse_id: number;
   quantity: number;
+  // Stock-in only; defaults to the current average cost
+  unit_cost?: number;
+  note?: string;
+}
+
+// Either quantity (signed) or target_quantity must be given
+export interface StockAdjustmentRequest {
+  product_id: number;
+  warehouse_id: number;
+  quantity?: number;
+  target_quantity?: number;
+  reason_id: number;
   note?: string;
 }